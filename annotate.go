@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// maxAnnotationValueWidth limits the width of values quoted in annotations
+const maxAnnotationValueWidth = 60
+
+// annotations holds the comment markers to insert into the new file, keyed by 1-based line number
+type annotations struct {
+	inline   map[int][]string // notes appended to the end of a line
+	before   map[int][]string // comment lines inserted before a line
+	trailing []string         // comment lines appended after the last line
+	masked   []*yaml.Node     // nodes of values to mask in the source
+}
+
+// newAnnotations creates an empty set of annotations
+func newAnnotations() *annotations {
+	return &annotations{
+		inline: make(map[int][]string),
+		before: make(map[int][]string),
+	}
+}
+
+// addChanges records markers for the changes of a document, placed next to
+// the corresponding nodes of the document in the new file
+func (a *annotations) addChanges(changes []Change, newRoot *yaml.Node, newData interface{}) {
+	root := resolveNode(newRoot)
+	for _, change := range changes {
+		switch change.Type {
+		case Modification:
+			note := "changed from " + formatSingleLine(change.OldValue, maxAnnotationValueWidth)
+			if line := entryLine(newRoot, newData, change.Path); line > 0 {
+				a.inline[line] = append(a.inline[line], note)
+				continue
+			}
+			a.addFallback(root, change.Path+" "+note)
+		case Addition:
+			note := "added"
+			if change.Path == "" {
+				note = "added document"
+			}
+			if line := entryLine(newRoot, newData, change.Path); line > 0 {
+				a.inline[line] = append(a.inline[line], note)
+				continue
+			}
+			a.addFallback(root, "added "+change.Path)
+		case Move:
+			if line := entryLine(newRoot, newData, change.Path); line > 0 {
+				a.inline[line] = append(a.inline[line], fmt.Sprintf("moved from position %v", change.OldValue))
+			}
+		case Deletion:
+			if change.Path == "" {
+				a.trailing = append(a.trailing, "removed document (was "+formatSingleLine(change.OldValue, maxAnnotationValueWidth)+")")
+				continue
+			}
+			parent, segment := splitLastSegment(change.Path)
+			note := fmt.Sprintf("removed %s (was %s)", strings.TrimPrefix(segment, "."), formatSingleLine(change.OldValue, maxAnnotationValueWidth))
+			if parent != "" {
+				if line := entryLine(newRoot, newData, parent); line > 0 {
+					a.inline[line] = append(a.inline[line], note)
+					continue
+				}
+			}
+			a.addFallback(root, "removed "+change.Path+" (was "+formatSingleLine(change.OldValue, maxAnnotationValueWidth)+")")
+		}
+	}
+}
+
+// addMasked records the values of a document in the new file that should be
+// masked, since the file is printed as it is
+func (a *annotations) addMasked(root *yaml.Node, data interface{}) {
+	a.masked = append(a.masked, maskedNodes(root, data)...)
+}
+
+// addFallback records a note that cannot be placed on a specific line before the document root
+func (a *annotations) addFallback(root *yaml.Node, note string) {
+	if root == nil {
+		a.trailing = append(a.trailing, note)
+		return
+	}
+	a.before[root.Line] = append(a.before[root.Line], note)
+}
+
+// entryLine returns the line of the entry at path: the key line for mapping
+// entries, or the line of the value itself for list items and the root
+func entryLine(root *yaml.Node, data interface{}, path string) int {
+	line, _ := entryPosition(root, data, path)
+	return line
+}
+
+// entryPosition returns the line and column of the entry at path like
+// entryLine, or zeros if it isn't in the source
+func entryPosition(root *yaml.Node, data interface{}, path string) (int, int) {
+	key, value := locateEntry(root, data, path, nil)
+	if key != nil {
+		return key.Line, key.Column
+	}
+	if value != nil {
+		return value.Line, value.Column
+	}
+	return 0, 0
+}
+
+// generateAnnotated renders the source of the new file with the annotations inserted as comments
+func generateAnnotated(source []byte, a *annotations) string {
+	yellow := color.New(color.FgYellow)
+	marker := func(notes []string) string {
+		return "# ymldiff: " + strings.Join(notes, "; ")
+	}
+
+	text := strings.TrimSuffix(string(source), "\n")
+	lines := strings.Split(text, "\n")
+	if text == "" {
+		lines = nil
+	}
+
+	// Masked values are replaced by a quoted maskText, the lines they continue
+	// on being left out along with their notes, which move to the first line
+	runes := make([][]rune, len(lines))
+	for i, line := range lines {
+		runes[i] = []rune(strings.TrimSuffix(line, "\r"))
+	}
+	spans := maskedSpans(a.masked, runes)
+	// Replacing them from the end of the file leaves the positions of the
+	// ones before valid
+	sort.Slice(spans, func(i, j int) bool {
+		if spans[i].Line != spans[j].Line {
+			return spans[i].Line > spans[j].Line
+		}
+		return spans[i].Column > spans[j].Column
+	})
+	skipped := make(map[int]bool)
+	for _, span := range spans {
+		text := runes[span.Line-1]
+		replaced := append(append([]rune{}, text[:span.Column-1]...), []rune(strconv.Quote(maskText))...)
+		runes[span.Line-1] = append(replaced, runes[span.EndLine-1][span.EndColumn-1:]...)
+		for line := span.Line + 1; line <= span.EndLine; line++ {
+			skipped[line] = true
+			a.inline[span.Line] = append(a.inline[span.Line], a.before[line]...)
+			a.inline[span.Line] = append(a.inline[span.Line], a.inline[line]...)
+		}
+	}
+
+	var result strings.Builder
+	for i, line := range lines {
+		lineNumber := i + 1
+		if skipped[lineNumber] {
+			continue
+		}
+		if notes := a.before[lineNumber]; len(notes) > 0 {
+			indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+			for _, note := range notes {
+				result.WriteString(indent)
+				result.WriteString(yellow.Sprint(marker([]string{note})))
+				result.WriteString("\n")
+			}
+		}
+		result.WriteString(string(runes[i]))
+		if notes := a.inline[lineNumber]; len(notes) > 0 {
+			result.WriteString("  ")
+			result.WriteString(yellow.Sprint(marker(notes)))
+		}
+		result.WriteString("\n")
+	}
+	for _, note := range a.trailing {
+		result.WriteString(yellow.Sprint(marker([]string{note})))
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// resolvedOperation is a patch operation bound to the source nodes it changes.
+// Operations are resolved against the unchanged target first, so that earlier
+// operations cannot shift the list items later ones refer to.
+type resolvedOperation struct {
+	Operation patchOperation
+	Change    Change
+	Document  int        // index of the target document, len(documents) and up for new documents
+	Parent    *yaml.Node // mapping or sequence holding the entry, nil for the document root
+	Key       *yaml.Node // key node of a mapping entry
+	Node      *yaml.Node // current value node, nil for additions
+}
+
+// applyOptions controls how runApply applies a patch
+type applyOptions struct {
+	DryRun  bool     // print the changes and the resulting file instead of writing it
+	Confirm bool     // confirm every change interactively
+	Only    []string // apply only operations on paths matching these patterns
+	Skip    []string // skip operations on paths matching these patterns
+}
+
+// selectOperations keeps the operations whose path matches one of the only
+// patterns (all of them when there are none) and none of the skip patterns
+func selectOperations(operations []patchOperation, only, skip []string) []patchOperation {
+	var selected []patchOperation
+	for _, operation := range operations {
+		if len(only) > 0 && !matchAnyPath(only, operation.Path) {
+			continue
+		}
+		if matchAnyPath(skip, operation.Path) {
+			continue
+		}
+		selected = append(selected, operation)
+	}
+	return selected
+}
+
+// loadPatch reads the operations of a patch file
+func loadPatch(filename string) ([]patchOperation, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var operations []patchOperation
+	if err := yaml.Unmarshal(data, &operations); err != nil {
+		return nil, err
+	}
+	for i, operation := range operations {
+		switch operation.Op {
+		case patchAdd, patchRemove, patchReplace:
+		default:
+			return nil, fmt.Errorf("operation %d: unknown op %q", i+1, operation.Op)
+		}
+		if operation.Document < 1 {
+			return nil, fmt.Errorf("operation %d: document must be at least 1", i+1)
+		}
+	}
+	return operations, nil
+}
+
+// resolveOperations binds patch operations to the nodes of the target documents
+func resolveOperations(operations []patchOperation, documents []YAMLDocument) ([]resolvedOperation, error) {
+	resolved := make([]resolvedOperation, 0, len(operations))
+	for i, operation := range operations {
+		r, err := resolveOperation(operation, documents)
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i+1, operation.Op, debugPath(operation.Path), err)
+		}
+		resolved = append(resolved, r)
+	}
+	return resolved, nil
+}
+
+// resolveOperation binds a single patch operation to the nodes of the target documents
+func resolveOperation(operation patchOperation, documents []YAMLDocument) (resolvedOperation, error) {
+	r := resolvedOperation{Operation: operation, Document: operation.Document - 1}
+	r.Change = Change{Path: operation.Path, NewValue: normalizeValue(operation.Value)}
+
+	if r.Document >= len(documents) {
+		if operation.Op != patchAdd || operation.Path != "" {
+			return r, fmt.Errorf("document %d not found", operation.Document)
+		}
+		r.Change.Type = Addition
+		return r, nil
+	}
+	document := documents[r.Document]
+
+	if operation.Path == "" {
+		r.Node = resolveNode(document.Node)
+		switch operation.Op {
+		case patchAdd:
+			if document.Data != nil {
+				return r, fmt.Errorf("document %d already exists", operation.Document)
+			}
+			r.Change.Type = Addition
+		case patchRemove:
+			r.Change.Type = Deletion
+			r.Change.OldValue, r.Change.NewValue = document.Data, nil
+		case patchReplace:
+			r.Change.Type = Modification
+			r.Change.OldValue = document.Data
+		}
+		return r, nil
+	}
+
+	parentPath, segment := splitLastSegment(operation.Path)
+	r.Parent = locateNode(document.Node, document.Data, parentPath)
+	parentData, _ := lookupPath(document.Data, parentPath)
+	if r.Parent == nil {
+		return r, fmt.Errorf("path %s not found", debugPath(parentPath))
+	}
+	r.Key, r.Node = locateEntry(r.Parent, parentData, segment, nil)
+
+	switch operation.Op {
+	case patchAdd:
+		if r.Node != nil {
+			return r, fmt.Errorf("path already exists")
+		}
+		if r.Parent.Kind == yaml.MappingNode && !strings.HasPrefix(segment, ".") ||
+			r.Parent.Kind == yaml.SequenceNode && !strings.HasPrefix(segment, "[") ||
+			r.Parent.Kind == yaml.ScalarNode {
+			return r, fmt.Errorf("cannot add %s to %s", segment, debugPath(parentPath))
+		}
+		r.Change.Type = Addition
+	case patchRemove, patchReplace:
+		if r.Node == nil {
+			return r, fmt.Errorf("path not found")
+		}
+		oldValue, _ := lookupPath(document.Data, operation.Path)
+		r.Change.OldValue = oldValue
+		r.Change.Type = Modification
+		if operation.Op == patchRemove {
+			r.Change.Type = Deletion
+			r.Change.NewValue = nil
+		}
+	}
+	return r, nil
+}
+
+// valueNode encodes a patch value as a YAML node
+func valueNode(value interface{}) (*yaml.Node, error) {
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// applyResolved applies a resolved operation to the target document nodes,
+// returning the nodes with any new document appended
+func applyResolved(r resolvedOperation, documents []*yaml.Node) ([]*yaml.Node, error) {
+	operation := r.Operation
+	var value *yaml.Node
+	if operation.Op != patchRemove {
+		var err error
+		if value, err = valueNode(operation.Value); err != nil {
+			return documents, err
+		}
+	}
+
+	if r.Document >= len(documents) {
+		return append(documents, &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{value}}), nil
+	}
+
+	if operation.Path == "" {
+		// Removed documents are left out when the documents are encoded
+		if operation.Op == patchRemove {
+			documents[r.Document] = nil
+		} else {
+			documents[r.Document].Content = []*yaml.Node{value}
+		}
+		return documents, nil
+	}
+
+	switch operation.Op {
+	case patchReplace:
+		// Keep the comments attached to the replaced value
+		value.HeadComment, value.LineComment, value.FootComment = r.Node.HeadComment, r.Node.LineComment, r.Node.FootComment
+		*r.Node = *value
+	case patchRemove:
+		r.Parent.Content = removeEntry(r.Parent.Content, r.Key, r.Node)
+	case patchAdd:
+		_, segment := splitLastSegment(operation.Path)
+		if r.Parent.Kind == yaml.MappingNode {
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: strings.TrimPrefix(segment, ".")}
+			r.Parent.Content = append(r.Parent.Content, key, value)
+		} else {
+			r.Parent.Content = append(r.Parent.Content, value)
+		}
+	}
+	return documents, nil
+}
+
+// removeEntry removes a mapping entry, or a sequence item when key is nil, from the content of a node
+func removeEntry(content []*yaml.Node, key, value *yaml.Node) []*yaml.Node {
+	for i, node := range content {
+		if key != nil && node == key && i+1 < len(content) {
+			return append(content[:i:i], content[i+2:]...)
+		}
+		if key == nil && node == value {
+			return append(content[:i:i], content[i+1:]...)
+		}
+	}
+	return content
+}
+
+// confirmChange asks a yes/no question about a change, returning false to skip
+// it and stop to skip it and all remaining ones
+func confirmChange(reader *bufio.Reader, output io.Writer, change Change, question string) (apply bool, stop bool, err error) {
+	for {
+		fmt.Fprint(output, formatChange(change, debugPath(change.Path), ""))
+		fmt.Fprintf(output, "%s [y,n,q]? ", question)
+		answer, err := reader.ReadString('\n')
+		if err != nil && answer == "" {
+			return false, true, err
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "y", "yes":
+			return true, false, nil
+		case "n", "no":
+			return false, false, nil
+		case "q", "quit":
+			return false, true, nil
+		}
+	}
+}
+
+// encodeDocuments renders document nodes as a YAML stream, skipping removed documents
+func encodeDocuments(documents []*yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	for _, document := range documents {
+		if document == nil {
+			continue
+		}
+		if err := encoder.Encode(document); err != nil {
+			return nil, err
+		}
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// runApply applies a patch file to a target file
+func runApply(patchFile, targetFile string, options applyOptions) error {
+	defer resetFormattedValues()
+	operations, err := loadPatch(patchFile)
+	if err != nil {
+		return fmt.Errorf("reading patch %s: %w", patchFile, err)
+	}
+	operations = selectOperations(operations, options.Only, options.Skip)
+
+	source, err := os.ReadFile(targetFile)
+	if err != nil {
+		return err
+	}
+	documents, err := parseYAMLData(source)
+	if err != nil {
+		return newParseError(targetFile, err)
+	}
+
+	resolved, err := resolveOperations(operations, documents)
+	if err != nil {
+		return err
+	}
+
+	nodes := make([]*yaml.Node, len(documents))
+	for i := range documents {
+		nodes[i] = documents[i].Node
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	var applied []Change
+	for _, r := range resolved {
+		if options.Confirm {
+			apply, stop, err := confirmChange(reader, os.Stderr, r.Change, "Apply this change")
+			if err != nil {
+				return err
+			}
+			if stop {
+				break
+			}
+			if !apply {
+				continue
+			}
+		}
+		if nodes, err = applyResolved(r, nodes); err != nil {
+			return err
+		}
+		applied = append(applied, r.Change)
+	}
+
+	result, err := encodeDocuments(nodes)
+	if err != nil {
+		return err
+	}
+
+	if options.DryRun {
+		if masking() {
+			applied = maskChanges(applied)
+		}
+		fmt.Print(generateColoredDiff(applied))
+		fmt.Println()
+		fmt.Print(string(result))
+		return nil
+	}
+
+	info, err := os.Stat(targetFile)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(targetFile, result, info.Mode().Perm()); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Applied %d of %d %s to %s\n", len(applied), len(resolved), pluralize(len(resolved), "change", "changes"), targetFile)
+	return nil
+}
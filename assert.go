@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// Modes of the assert command, relating the computed changes to the expected ones
+const (
+	expectExact    = "exact"    // the changes are exactly the expected ones
+	expectSubset   = "subset"   // every change is expected, not all expected changes need to happen
+	expectSuperset = "superset" // every expected change happens, others may too
+)
+
+// expectModes lists the supported --expect-mode values
+var expectModes = []string{expectExact, expectSubset, expectSuperset}
+
+// assertion holds the differences between the computed and the expected changes
+type assertion struct {
+	Unexpected []stagedChange   // computed changes missing from the expected patch
+	Missing    []patchOperation // expected operations that weren't computed
+}
+
+// passes checks if the assertion holds in a mode
+func (a assertion) passes(mode string) bool {
+	switch mode {
+	case expectSubset:
+		return len(a.Unexpected) == 0
+	case expectSuperset:
+		return len(a.Missing) == 0
+	default:
+		return len(a.Unexpected) == 0 && len(a.Missing) == 0
+	}
+}
+
+// forwardChanges pairs the changes of a comparison with their patch
+// operations against the first file, numbering its documents as a patch
+// written by --emit patch does
+func forwardChanges(result Result) []stagedChange {
+	var changes []stagedChange
+	appended := 0
+	for _, document := range result.Documents {
+		target := document.Pair.Old + 1
+		if document.Pair.Old < 0 {
+			appended++
+			target = len(result.Old) + appended
+		}
+		changes = append(changes, newStagedChanges(newChanges(document.Changes), target)...)
+	}
+	return changes
+}
+
+// checkExpected matches the computed changes with the operations of an
+// expected patch by document, operation, path and value
+func checkExpected(computed []stagedChange, expected []patchOperation) assertion {
+	var result assertion
+	matched := make([]bool, len(expected))
+	for _, change := range computed {
+		found := false
+		for i, operation := range expected {
+			if !matched[i] && sameOperation(change.Operation, operation) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if !found {
+			result.Unexpected = append(result.Unexpected, change)
+		}
+	}
+	for i, operation := range expected {
+		if !matched[i] {
+			result.Missing = append(result.Missing, operation)
+		}
+	}
+	return result
+}
+
+// sameOperation checks if a computed operation is the expected one. The
+// values of removals aren't compared.
+func sameOperation(computed, expected patchOperation) bool {
+	if computed.Document != expected.Document || computed.Op != expected.Op ||
+		normalizePathArgument(computed.Path) != normalizePathArgument(expected.Path) {
+		return false
+	}
+	return computed.Op == patchRemove || ymldiff.Equal(computed.Value, normalizeValue(expected.Value))
+}
+
+// runAssert compares two files and checks their changes against an expected
+// patch, printing the differences. It returns whether the assertion holds.
+func runAssert(file1, file2, expectFile, mode string) (bool, error) {
+	defer resetFormattedValues()
+	expected, err := loadPatch(expectFile)
+	if err != nil {
+		return false, fmt.Errorf("reading expected changes %s: %w", expectFile, err)
+	}
+	result, err := compareFiles(file1, file2)
+	if err != nil {
+		return false, err
+	}
+	computed := forwardChanges(result.Result)
+	check := checkExpected(computed, expected)
+
+	if check.passes(mode) {
+		fmt.Printf("Changes match %s (%d %s)\n", expectFile, len(computed), pluralize(len(computed), "change", "changes"))
+		return true, nil
+	}
+	if mode != expectSuperset && len(check.Unexpected) > 0 {
+		themeColor(theme.Comment).Println("# Unexpected changes")
+		changes := make([]Change, len(check.Unexpected))
+		for i, change := range check.Unexpected {
+			changes[i] = change.Change
+		}
+		if masking() {
+			changes = maskChanges(changes)
+		}
+		fmt.Print(generateColoredDiff(changes))
+		fmt.Println()
+	}
+	if mode != expectSubset && len(check.Missing) > 0 {
+		themeColor(theme.Comment).Println("# Missing expected changes")
+		for _, operation := range check.Missing {
+			fmt.Println(formatOperation(operation))
+		}
+		fmt.Println()
+	}
+	return false, nil
+}
+
+// formatOperation describes a patch operation in one line
+func formatOperation(operation patchOperation) string {
+	description := fmt.Sprintf("document %d: %s %s", operation.Document, operation.Op, operation.Path)
+	if operation.Op != patchRemove {
+		value := normalizeValue(operation.Value)
+		if masking() {
+			value = maskValue(value, operation.Path)
+		}
+		description += ": " + summarizeBlock(value, func() string {
+			return strings.TrimSpace(formatValue(value))
+		})
+	}
+	return description
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// base64Patterns are the paths whose base64-encoded strings are decoded
+// before comparing, set with --decode-base64
+var base64Patterns []string
+
+// decodeBase64Text decodes a string holding base64-encoded text. Strings that
+// aren't valid base64, or decode to binary data, are left encoded.
+func decodeBase64Text(s string) (string, bool) {
+	if len(s) < 4 || len(s)%4 != 0 {
+		return "", false
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || !utf8.Valid(data) {
+		return "", false
+	}
+	for _, r := range string(data) {
+		if unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t' {
+			return "", false
+		}
+	}
+	return string(data), true
+}
+
+// transformStrings returns a copy of a document with the strings replaced by
+// fn, given their path, where it returns a replacement
+func transformStrings(value interface{}, path string, fn func(path, s string) (interface{}, bool)) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for key, child := range v {
+			result[key] = transformStrings(child, path+"."+fmt.Sprintf("%v", key), fn)
+		}
+		return result
+	case []interface{}:
+		keyed := !rawMode && isSliceOfDictsWithIds(v)
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			if id, ok := itemIdentifier(item); keyed && ok {
+				itemPath = path + "[" + id + "]"
+			}
+			result[i] = transformStrings(item, itemPath, fn)
+		}
+		return result
+	case string:
+		if replacement, ok := fn(path, v); ok {
+			return replacement
+		}
+	}
+	return value
+}
+
+// decodeBase64Values returns a copy of a document with the base64-encoded
+// strings at paths matching base64Patterns decoded, recording their paths
+func decodeBase64Values(value interface{}, path string, decoded map[string]bool) interface{} {
+	return transformStrings(value, path, func(path, s string) (interface{}, bool) {
+		if !matchAnyPath(base64Patterns, path) {
+			return nil, false
+		}
+		text, ok := decodeBase64Text(s)
+		if ok {
+			debugf("%s: base64-decoded", debugPath(path))
+			decoded[path] = true
+		}
+		return text, ok
+	})
+}
+
+// markDecoded flags the changes to decoded values, or nested in them
+func markDecoded(changes []Change, decoded map[string]bool) {
+	for i := range changes {
+		for path := changes[i].Path; ; path, _ = splitLastSegment(path) {
+			if decoded[path] {
+				changes[i].Decoded = true
+				break
+			}
+			if path == "" {
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// blameLine is the commit that last touched a line of a file
+type blameLine struct {
+	ShortHash string
+	Author    string
+	Date      string
+}
+
+// String formats the attribution shown next to a change
+func (b blameLine) String() string {
+	if strings.Trim(b.ShortHash, "0") == "" {
+		return "not committed yet"
+	}
+	return fmt.Sprintf("%s in %s (%s)", b.Author, b.ShortHash, b.Date)
+}
+
+// fileBlame runs git blame on a file, or on a FILE@REV input as the file was
+// in that revision, and returns the commit of each 1-based line
+func fileBlame(file string) (map[int]blameLine, error) {
+	args := []string{"blame", "--line-porcelain"}
+	if path, revision, ok := splitRevision(file); ok {
+		file = path
+		args = append(args, revision)
+	}
+	output, err := runGit(filepath.Dir(file), append(args, "--", filepath.Base(file))...)
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(output), nil
+}
+
+// parseBlame parses the output of git blame --line-porcelain
+func parseBlame(output []byte) map[int]blameLine {
+	lines := make(map[int]blameLine)
+	var current blameLine
+	var lineNumber int
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			// The line content ends the entry of a line
+			lines[lineNumber] = current
+		case strings.HasPrefix(text, "author "):
+			current.Author = strings.TrimPrefix(text, "author ")
+		case strings.HasPrefix(text, "author-time "):
+			if seconds, err := strconv.ParseInt(strings.TrimPrefix(text, "author-time "), 10, 64); err == nil {
+				current.Date = time.Unix(seconds, 0).UTC().Format("2006-01-02")
+			}
+		default:
+			// Entries start with "<hash> <original line> <final line> [<group size>]"
+			fields := strings.Fields(text)
+			if len(fields) >= 3 && len(fields[0]) >= 40 {
+				if n, err := strconv.Atoi(fields[2]); err == nil {
+					current = blameLine{ShortHash: fields[0][:7]}
+					lineNumber = n
+				}
+			}
+		}
+	}
+	return lines
+}
+
+// attachBlame records who last touched the line of each added or modified
+// value in the new file. Removed values have no line left to blame.
+func attachBlame(changes []Change, newRoot *yaml.Node, newData interface{}, blame map[int]blameLine) {
+	for i := range changes {
+		if changes[i].Type == Deletion {
+			continue
+		}
+		if line, ok := blame[entryLine(newRoot, newData, changes[i].Path)]; ok {
+			changes[i].Blame = line.String()
+		}
+	}
+}
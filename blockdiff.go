@@ -0,0 +1,69 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// isMultilineString checks if a string spans several lines, apart from a
+// final line break
+func isMultilineString(s string) bool {
+	return strings.Contains(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// formatStringLinesDiff formats the lines removed from and added to a
+// multi-line string under the change, with up to unifiedContext unchanged
+// lines around them. Longer runs of unchanged lines are shown as "…".
+func formatStringLinesDiff(oldStr, newStr, indent string) string {
+	oldLines := strings.Split(strings.TrimSuffix(oldStr, "\n"), "\n")
+	newLines := strings.Split(strings.TrimSuffix(newStr, "\n"), "\n")
+	edits := diffLines(oldLines, newLines)
+
+	// Unchanged lines are shown when they are close enough to a change
+	shown := make([]bool, len(edits))
+	for i, edit := range edits {
+		if edit.Op == ' ' {
+			continue
+		}
+		for j := max(i-unifiedContext, 0); j <= min(i+unifiedContext, len(edits)-1); j++ {
+			shown[j] = true
+		}
+	}
+	red := themeColor(theme.Deletion)
+	green := themeColor(theme.Addition)
+	dim := themeColor(theme.Context)
+	keptPrefix := indent + strings.Repeat(" ", utf8.RuneCountInString(markerFor(Deletion)))
+
+	var result strings.Builder
+	skipped := false
+	for i, edit := range edits {
+		if !shown[i] {
+			if !skipped {
+				result.WriteString(keptPrefix + "   " + dim.Sprint("…") + "\n")
+				skipped = true
+			}
+			continue
+		}
+		skipped = false
+		switch edit.Op {
+		case '-':
+			result.WriteString(indent + red.Sprint(markerFor(Deletion)) + "   " + red.Sprint(edit.Line) + "\n")
+		case '+':
+			result.WriteString(indent + green.Sprint(markerFor(Addition)) + "   " + green.Sprint(edit.Line) + "\n")
+		default:
+			result.WriteString(keptPrefix + "   " + dim.Sprint(edit.Line) + "\n")
+		}
+	}
+	return result.String()
+}
+
+// multilineStrings returns the old and new values of a change if both are
+// strings and at least one spans several lines with changed lines
+func multilineStrings(change Change) (string, string, bool) {
+	oldStr, oldOK := change.OldValue.(string)
+	newStr, newOK := change.NewValue.(string)
+	if !oldOK || !newOK || !(isMultilineString(oldStr) || isMultilineString(newStr)) {
+		return "", "", false
+	}
+	return oldStr, newStr, strings.TrimSuffix(oldStr, "\n") != strings.TrimSuffix(newStr, "\n")
+}
@@ -0,0 +1,22 @@
+package main
+
+import "strings"
+
+// formatBrief lists the changed paths, one per line and each once, in the
+// order of the changes. The document root is written as ".".
+func formatBrief(changes []jsonChange) string {
+	var output strings.Builder
+	seen := make(map[string]bool)
+	for _, change := range changes {
+		path := change.Path
+		if path == "" {
+			path = "."
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		output.WriteString(path + "\n")
+	}
+	return output.String()
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseCerts compares PEM blocks by what they hold rather than as text, set
+// with --parse-certs
+var parseCerts bool
+
+// describePEM replaces a string of PEM blocks with the fields worth comparing:
+// subject, issuer, serial number, validity, subject alternative names and
+// fingerprint of certificates, and the type and fingerprint of other blocks.
+// A single block becomes a map and a chain a list of maps.
+func describePEM(s string) (interface{}, bool) {
+	if !strings.Contains(s, "-----BEGIN ") {
+		return nil, false
+	}
+
+	var blocks []interface{}
+	rest := []byte(s)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		blocks = append(blocks, describePEMBlock(block))
+	}
+	switch len(blocks) {
+	case 0:
+		return nil, false
+	case 1:
+		return normalizeValue(blocks[0]), true
+	default:
+		return normalizeValue(blocks), true
+	}
+}
+
+// describePEMBlock describes one PEM block
+func describePEMBlock(block *pem.Block) map[string]interface{} {
+	description := map[string]interface{}{
+		"type":        block.Type,
+		"fingerprint": formatFingerprint(block.Bytes),
+	}
+	if block.Type != "CERTIFICATE" {
+		return description
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return description
+	}
+
+	description["subject"] = cert.Subject.String()
+	description["issuer"] = cert.Issuer.String()
+	description["serialNumber"] = strings.ToUpper(cert.SerialNumber.Text(16))
+	description["notBefore"] = cert.NotBefore.UTC().Format(time.RFC3339)
+	description["notAfter"] = cert.NotAfter.UTC().Format(time.RFC3339)
+	var names []interface{}
+	for _, name := range cert.DNSNames {
+		names = append(names, "DNS:"+name)
+	}
+	for _, ip := range cert.IPAddresses {
+		names = append(names, "IP:"+ip.String())
+	}
+	for _, email := range cert.EmailAddresses {
+		names = append(names, "email:"+email)
+	}
+	for _, uri := range cert.URIs {
+		names = append(names, "URI:"+uri.String())
+	}
+	if len(names) > 0 {
+		description["subjectAltNames"] = names
+	}
+	return description
+}
+
+// formatFingerprint formats the SHA-256 fingerprint of DER data as colon-separated hex
+func formatFingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	parts := make([]string, len(sum))
+	for i, b := range sum {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, ":")
+}
+
+// describeCertificates returns a copy of a document with the strings holding
+// PEM blocks replaced by their descriptions
+func describeCertificates(value interface{}) interface{} {
+	return transformStrings(value, "", func(path, s string) (interface{}, bool) {
+		description, ok := describePEM(s)
+		if ok {
+			debugf("%s: PEM blocks compared by their fields", debugPath(path))
+		}
+		return description, ok
+	})
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"gopkg.in/yaml.v3"
+	"ymldiff/pkg/ymldiff"
+)
+
+// Change is a change found by the diff engine, with what the output attaches
+// to it: its source nodes and positions, whether its values were decoded and
+// who last touched its line
+type Change struct {
+	Type     ChangeType
+	Path     string
+	OldValue interface{}
+	NewValue interface{}
+	Count    int // number of equal items added or removed, with --multiset
+	// Source nodes of the values, attached when the output needs them
+	OldNode *yaml.Node
+	NewNode *yaml.Node
+	// Positions of the entries in the old and new files, with --locations
+	OldLine, OldColumn int
+	NewLine, NewColumn int
+	// Whether the values were base64-decoded before comparing
+	Decoded bool
+	// Author and commit of the changed line, with --blame
+	Blame string
+}
+
+// newChanges wraps the changes found by the diff engine
+func newChanges(changes []ymldiff.Change) []Change {
+	if changes == nil {
+		return nil
+	}
+	result := make([]Change, len(changes))
+	for i, change := range changes {
+		result[i] = Change{Type: change.Type, Path: change.Path, OldValue: change.OldValue, NewValue: change.NewValue, Count: change.Count}
+	}
+	return result
+}
+
+// engineChanges unwraps changes into those of the diff engine, as kept in a Result
+func engineChanges(changes []Change) []ymldiff.Change {
+	if changes == nil {
+		return nil
+	}
+	result := make([]ymldiff.Change, len(changes))
+	for i, change := range changes {
+		result[i] = ymldiff.Change{Type: change.Type, Path: change.Path, OldValue: change.OldValue, NewValue: change.NewValue, Count: change.Count}
+	}
+	return result
+}
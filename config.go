@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"ymldiff/pkg/ymldiff"
+)
+
+// Outcomes of a run that can be mapped to exit codes
+const (
+	outcomeIdentical           = "identical"
+	outcomeChanges             = "changes"
+	outcomeForbiddenPathChange = "forbidden-path-change"
+	outcomeTooManyChanges      = "too-many-changes"
+	outcomeFailOnChange        = "fail-on-change"
+	outcomeAssertionFailed     = "assertion-failed"
+	outcomeParseError          = "parse-error"
+	outcomeUsageError          = "usage-error"
+	outcomeFileNotFound        = "file-not-found"
+	outcomeInternalError       = "internal-error"
+)
+
+// defaultExitCodes maps each outcome to the exit code used unless configured otherwise
+var defaultExitCodes = map[string]int{
+	outcomeIdentical:           0,
+	outcomeChanges:             0,
+	outcomeForbiddenPathChange: 1,
+	outcomeTooManyChanges:      1,
+	outcomeFailOnChange:        1,
+	outcomeAssertionFailed:     1,
+	outcomeUsageError:          2,
+	outcomeFileNotFound:        3,
+	outcomeParseError:          4,
+	outcomeInternalError:       5,
+}
+
+// Config holds settings loaded from a ymldiff configuration file
+type Config struct {
+	Presets        map[string]Preset `yaml:"presets"`
+	ExitCodes      map[string]int    `yaml:"exitCodes"`
+	ForbiddenPaths []string          `yaml:"forbiddenPaths"`
+	Theme          Theme             `yaml:"theme"`
+	Markers        Markers           `yaml:"markers"`
+	Dates          []DateRule        `yaml:"dates"`
+	Equivalences   []EquivalenceRule `yaml:"equivalences"`
+	Tolerances     []ToleranceRule   `yaml:"tolerances"`
+}
+
+// loadConfig reads and validates a configuration file
+func loadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	for outcome, code := range config.ExitCodes {
+		if _, known := defaultExitCodes[outcome]; !known {
+			return nil, fmt.Errorf("invalid config file: unknown exit code outcome %q (available: %s)", outcome, strings.Join(exitCodeOutcomes(), ", "))
+		}
+		if code < 0 || code > 255 {
+			return nil, fmt.Errorf("invalid config file: exit code %d for %q is out of range 0-255", code, outcome)
+		}
+	}
+
+	if _, err := mergeTheme(defaultTheme, config.Theme); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	if err := ymldiff.ValidateDateRules(config.Dates); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := ymldiff.CompileEquivalences(config.Equivalences); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+	if err := ymldiff.ValidateTolerances(config.Tolerances); err != nil {
+		return nil, fmt.Errorf("invalid config file: %w", err)
+	}
+
+	return &config, nil
+}
+
+// exitCodeOutcomes returns the names of all outcomes in sorted order
+func exitCodeOutcomes() []string {
+	outcomes := make([]string, 0, len(defaultExitCodes))
+	for outcome := range defaultExitCodes {
+		outcomes = append(outcomes, outcome)
+	}
+	sort.Strings(outcomes)
+	return outcomes
+}
+
+// exitCodeFor returns the exit code for an outcome, honoring the config file
+// mapping. Without one, differences exit with 1 in quiet mode unless --fail-on
+// decides which of them fail.
+func exitCodeFor(outcome string) int {
+	if code, ok := exitCodes[outcome]; ok {
+		return code
+	}
+	if outcome == outcomeChanges && quietMode && failOnTypes == nil {
+		return 1
+	}
+	return defaultExitCodes[outcome]
+}
+
+// determineOutcome decides the outcome of a comparison from its change counts
+func determineOutcome(totalChanges, forbiddenChanges, failingChanges int) string {
+	switch {
+	case forbiddenChanges > 0:
+		return outcomeForbiddenPathChange
+	case maxChanges >= 0 && totalChanges > maxChanges:
+		return outcomeTooManyChanges
+	case failingChanges > 0:
+		return outcomeFailOnChange
+	case totalChanges > 0:
+		return outcomeChanges
+	default:
+		return outcomeIdentical
+	}
+}
+
+// resultOutcome decides the outcome of a comparison from its changes, telling
+// on standard error why it fails when it does
+func resultOutcome(result Result) string {
+	var forbiddenChanges []string
+	for _, document := range result.Documents {
+		for _, change := range document.Changes {
+			if matchAnyPath(forbiddenPaths, change.Path) {
+				forbiddenChanges = append(forbiddenChanges, change.Path)
+			}
+		}
+	}
+
+	failing := failingChanges(result.Counts) + failingBumps(result.Documents)
+	outcome := determineOutcome(result.Counts.Total(), len(forbiddenChanges), failing)
+	switch outcome {
+	case outcomeForbiddenPathChange:
+		for _, path := range forbiddenChanges {
+			fmt.Fprintf(os.Stderr, "Error: forbidden path changed: %s\n", path)
+		}
+	case outcomeTooManyChanges:
+		fmt.Fprintf(os.Stderr, "Error: %d changes detected, more than the %d allowed by --max-changes; this diff needs a human review\n", result.Counts.Total(), maxChanges)
+	case outcomeFailOnChange:
+		fmt.Fprintf(os.Stderr, "Error: %d changes of a type given with --fail-on (%s) detected\n", failing, failOnSpec)
+	}
+	return outcome
+}
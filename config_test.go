@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// TestExitCodeMapping tests mapping outcomes to exit codes from the config file
+func TestExitCodeMapping(t *testing.T) {
+	originalExitCodes := exitCodes
+	defer func() { exitCodes = originalExitCodes }()
+
+	configContent := `exitCodes:
+  changes: 3
+  forbidden-path-change: 4
+  parse-error: 5
+forbiddenPaths:
+  - .spec.selector
+`
+	file := createTempFile(t, "config.yaml", configContent)
+	defer os.Remove(file)
+
+	config, err := loadConfig(file)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if len(config.ForbiddenPaths) != 1 {
+		t.Errorf("Expected 1 forbidden path, got %d", len(config.ForbiddenPaths))
+	}
+
+	exitCodes = config.ExitCodes
+	tests := map[string]int{
+		outcomeIdentical:           0,
+		outcomeChanges:             3,
+		outcomeForbiddenPathChange: 4,
+		outcomeParseError:          5,
+	}
+	for outcome, expected := range tests {
+		if code := exitCodeFor(outcome); code != expected {
+			t.Errorf("Expected exit code %d for %s, got %d", expected, outcome, code)
+		}
+	}
+
+	exitCodes = nil
+	if code := exitCodeFor(outcomeChanges); code != 0 {
+		t.Errorf("Expected default exit code 0 for changes, got %d", code)
+	}
+}
+
+// TestQuietExitCodes tests that differences exit with 1 in quiet mode unless configured otherwise
+func TestQuietExitCodes(t *testing.T) {
+	defer func(codes map[string]int, quiet bool) { exitCodes, quietMode = codes, quiet }(exitCodes, quietMode)
+	quietMode = true
+
+	exitCodes = nil
+	if code := exitCodeFor(outcomeChanges); code != 1 {
+		t.Errorf("Expected exit code 1 for changes in quiet mode, got %d", code)
+	}
+	if code := exitCodeFor(outcomeIdentical); code != 0 {
+		t.Errorf("Expected exit code 0 for identical files in quiet mode, got %d", code)
+	}
+
+	exitCodes = map[string]int{outcomeChanges: 3}
+	if code := exitCodeFor(outcomeChanges); code != 3 {
+		t.Errorf("Expected the configured exit code 3 for changes, got %d", code)
+	}
+}
+
+// TestLoadConfigValidation tests that invalid config files are rejected
+func TestLoadConfigValidation(t *testing.T) {
+	tests := map[string]string{
+		"unknown field":             "presets:\n  custom:\n    idkeys: [uuid]\n",
+		"unknown outcome":           "exitCodes:\n  different: 2\n",
+		"exit code out of range":    "exitCodes:\n  changes: 300\n",
+		"date rule without path":    "dates:\n  - formats: [date]\n",
+		"date rule without formats": "dates:\n  - path: .created\n",
+		"equivalence without path":  "equivalences:\n  - pattern: abc\n",
+		"invalid equivalence":       "equivalences:\n  - path: .image\n    pattern: \"[a-\"\n",
+	}
+
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			file := createTempFile(t, "config.yaml", content)
+			defer os.Remove(file)
+
+			if _, err := loadConfig(file); err == nil {
+				t.Error("Expected error, got none")
+			}
+		})
+	}
+}
+
+// TestDetermineOutcome tests deciding the outcome from change counts
+func TestDetermineOutcome(t *testing.T) {
+	originalMaxChanges := maxChanges
+	defer func() { maxChanges = originalMaxChanges }()
+
+	tests := []struct {
+		name       string
+		maxChanges int
+		total      int
+		forbidden  int
+		failing    int
+		expected   string
+	}{
+		{"identical", -1, 0, 0, 0, outcomeIdentical},
+		{"changes", -1, 5, 0, 0, outcomeChanges},
+		{"forbidden", -1, 5, 1, 0, outcomeForbiddenPathChange},
+		{"within limit", 5, 5, 0, 0, outcomeChanges},
+		{"over limit", 5, 6, 0, 0, outcomeTooManyChanges},
+		{"zero allowed", 0, 1, 0, 0, outcomeTooManyChanges},
+		{"forbidden wins", 5, 6, 1, 0, outcomeForbiddenPathChange},
+		{"failing type", -1, 5, 0, 2, outcomeFailOnChange},
+		{"over limit wins", 5, 6, 0, 2, outcomeTooManyChanges},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxChanges = tt.maxChanges
+			if outcome := determineOutcome(tt.total, tt.forbidden, tt.failing); outcome != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, outcome)
+			}
+		})
+	}
+}
+
+// TestFailOn tests counting only the changes of the types given with --fail-on
+func TestFailOn(t *testing.T) {
+	defer func(types map[ChangeType]bool) { failOnTypes = types }(failOnTypes)
+
+	types, _, err := parseFailOn("deletion, Modification")
+	if err != nil {
+		t.Fatal(err)
+	}
+	failOnTypes = types
+	counts := changeCounts{Additions: 4, Deletions: 1, Modifications: 2, Moves: 3}
+	if failing := failingChanges(counts); failing != 3 {
+		t.Errorf("Expected 3 failing changes, got %d", failing)
+	}
+	if failing := failingChanges(changeCounts{Additions: 4}); failing != 0 {
+		t.Errorf("Expected additions not to fail, got %d", failing)
+	}
+
+	failOnTypes = nil
+	if failing := failingChanges(counts); failing != 0 {
+		t.Errorf("Expected no failing changes without --fail-on, got %d", failing)
+	}
+
+	if _, _, err := parseFailOn("deletions"); err == nil {
+		t.Error("Expected an error for an unknown change type")
+	}
+}
+
+// TestFailOnBumps tests failing on semantic version bumps of the levels given with --fail-on
+func TestFailOnBumps(t *testing.T) {
+	defer func(types map[ChangeType]bool, bumps map[string]bool) {
+		failOnTypes, failOnBumps = types, bumps
+	}(failOnTypes, failOnBumps)
+
+	types, bumps, err := parseFailOn("major-bump,Minor-Bump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 0 || !bumps["major"] || !bumps["minor"] || bumps["patch"] {
+		t.Fatalf("Unexpected --fail-on parse: %v, %v", types, bumps)
+	}
+	failOnTypes, failOnBumps = types, bumps
+
+	documents := []DocumentResult{{Changes: []ymldiff.Change{
+		{Type: Modification, Path: ".a", OldValue: "1.2.3", NewValue: "2.0.0"},
+		{Type: Modification, Path: ".b", OldValue: "v1.2.3", NewValue: "v1.1.0"},
+		{Type: Modification, Path: ".c", OldValue: "1.2.3", NewValue: "1.2.4"},
+		{Type: Modification, Path: ".d", OldValue: "1.2", NewValue: "2.0"},
+		{Type: Addition, Path: ".e", NewValue: "3.0.0"},
+	}}}
+	if failing := failingBumps(documents); failing != 2 {
+		t.Errorf("Expected 2 failing bumps, got %d", failing)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// contextSiblings is the number of unchanged sibling keys shown above and
+// below each change, set with -C
+var contextSiblings int
+
+// generateContextDiff formats changes like generateColoredDiff, surrounding
+// each change with up to contextSiblings unchanged sibling keys of the
+// document it belongs to
+func generateContextDiff(changes []Change, oldDoc, newDoc interface{}) string {
+	if len(changes) > 0 && rollupMode {
+		return formatRollup(changes)
+	}
+	if len(changes) > 0 && groupByParent {
+		sortChanges(changes)
+		return formatGroupedChanges(changes, oldDoc, newDoc)
+	}
+	if len(changes) == 0 || contextSiblings <= 0 {
+		return generateColoredDiff(changes)
+	}
+
+	sortChanges(changes)
+
+	printed := make(map[string]bool)
+	var result strings.Builder
+	for _, change := range changes {
+		doc := newDoc
+		if change.Type == Deletion {
+			doc = oldDoc
+		}
+		before, after := siblingContext(change.Path, doc, changes)
+		for _, path := range before {
+			if !printed[path] {
+				result.WriteString(formatContextLine(path, doc))
+				printed[path] = true
+			}
+		}
+		result.WriteString(formatChange(change, debugPath(change.Path), ""))
+		for _, path := range after {
+			if !printed[path] {
+				result.WriteString(formatContextLine(path, doc))
+				printed[path] = true
+			}
+		}
+	}
+	return result.String()
+}
+
+// siblingContext returns the paths of the unchanged keys next to a changed
+// key in its parent map, up to contextSiblings above and below it
+func siblingContext(path string, doc interface{}, changes []Change) ([]string, []string) {
+	parentPath, segment := splitLastSegment(path)
+	if !strings.HasPrefix(segment, ".") {
+		return nil, nil
+	}
+	parent, _ := lookupPath(doc, parentPath)
+	m, ok := parent.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, fmt.Sprintf("%v", key))
+	}
+	sort.Strings(keys)
+	position := sort.SearchStrings(keys, segment[1:])
+
+	var before, after []string
+	// Context stops at the next changed sibling, which brings its own
+	for i := position - 1; i >= 0 && len(before) < contextSiblings; i-- {
+		sibling := parentPath + "." + keys[i]
+		if touchesChange(sibling, changes) {
+			break
+		}
+		before = append([]string{sibling}, before...)
+	}
+	for i := position + 1; i < len(keys) && len(after) < contextSiblings; i++ {
+		sibling := parentPath + "." + keys[i]
+		if touchesChange(sibling, changes) {
+			break
+		}
+		after = append(after, sibling)
+	}
+	return before, after
+}
+
+// touchesChange checks if a path changed or contains a change
+func touchesChange(path string, changes []Change) bool {
+	for _, change := range changes {
+		if change.Path == path || strings.HasPrefix(change.Path, path+".") || strings.HasPrefix(change.Path, path+"[") ||
+			strings.HasPrefix(change.Path, path+ymldiff.EmbeddedSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatContextLine formats an unchanged key, aligned with the changes and
+// summarizing maps and lists in one line
+func formatContextLine(path string, doc interface{}) string {
+	value, _ := lookupPath(doc, path)
+	formatted := summarizeBlock(value, func() string {
+		return strings.TrimSpace(formatValue(value))
+	})
+	indent := strings.Repeat(" ", utf8.RuneCountInString(markerFor(Modification)))
+	return themeColor(theme.Context).Sprintf("%s%s: %s", indent, path, formatted) + "\n"
+}
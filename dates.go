@@ -0,0 +1,10 @@
+package main
+
+import "ymldiff/pkg/ymldiff"
+
+// DateRule makes the dates at the paths matching a pattern compare equal
+// whatever format of the rule they are written in
+type DateRule = ymldiff.DateRule
+
+// dateRules are the active date rules, set in the config file
+var dateRules []DateRule
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// debugMode enables the trace of normalization and matching decisions
+var debugMode bool
+
+// debugOutput receives the debug trace
+var debugOutput io.Writer = os.Stderr
+
+// debugf writes a line to the debug trace when --debug is set
+func debugf(format string, args ...interface{}) {
+	if !debugMode {
+		return
+	}
+	fmt.Fprintf(debugOutput, "debug: "+format+"\n", args...)
+}
+
+// debugPath formats a change path for the debug trace, naming the document root
+func debugPath(path string) string {
+	if path == "" {
+		return "(document)"
+	}
+	return path
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
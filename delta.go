@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// numberValue converts an integer or floating point value to float64
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, !math.IsNaN(n) && !math.IsInf(n, 0)
+	default:
+		return 0, false
+	}
+}
+
+// formatPercentChange formats the relative change from one number to another,
+// e.g. +200% or -12.5%, or nothing if either isn't a number or the old one is 0
+func formatPercentChange(oldVal, newVal interface{}) string {
+	oldNumber, okOld := numberValue(oldVal)
+	newNumber, okNew := numberValue(newVal)
+	if !okOld || !okNew || oldNumber == 0 {
+		return ""
+	}
+	percent := (newNumber - oldNumber) / math.Abs(oldNumber) * 100
+	formatted := strings.TrimSuffix(fmt.Sprintf("%+.1f", percent), ".0")
+	if formatted == "+0" || formatted == "-0" {
+		// Keep the sign and first digit of changes too small for one decimal
+		formatted = fmt.Sprintf("%+.1g", percent)
+	}
+	return formatted + "%"
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"fmt"
+
+	"ymldiff/internal/intmath"
+	"ymldiff/pkg/ymldiff"
+)
+
+// minDocumentSimilarity is the similarity from which two documents are considered the same document
+const minDocumentSimilarity = 0.5
+
+// pairDocuments matches the documents of both files using the configured rule
+func pairDocuments(documents1, documents2 []YAMLDocument) []documentPair {
+	switch documentMatch {
+	case "kubernetes":
+		return pairDocumentsByIdentity(documents1, documents2, kubernetesIdentity)
+	case "similarity":
+		return pairDocumentsBySimilarity(documents1, documents2)
+	}
+
+	maxDocs := len(documents1)
+	if len(documents2) > maxDocs {
+		maxDocs = len(documents2)
+	}
+
+	pairs := make([]documentPair, 0, maxDocs)
+	for i := 0; i < maxDocs; i++ {
+		pair := documentPair{Old: -1, New: -1}
+		if i < len(documents1) {
+			pair.Old = i
+		}
+		if i < len(documents2) {
+			pair.New = i
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
+}
+
+// pairDocumentsByIdentity matches documents sharing the same identity. Documents
+// without an identity are matched by their order among the unidentified ones.
+func pairDocumentsByIdentity(documents1, documents2 []YAMLDocument, identity func(interface{}) (string, bool)) []documentPair {
+	oldByIdentity := make(map[string][]int)
+	var oldAnonymous []int
+	for i, doc := range documents1 {
+		if id, ok := identity(doc.Data); ok {
+			oldByIdentity[id] = append(oldByIdentity[id], i)
+		} else {
+			oldAnonymous = append(oldAnonymous, i)
+		}
+	}
+
+	matched := make(map[int]bool)
+	var pairs []documentPair
+	for i, doc := range documents2 {
+		pair := documentPair{Old: -1, New: i}
+		if id, ok := identity(doc.Data); ok {
+			if candidates := oldByIdentity[id]; len(candidates) > 0 {
+				pair.Old = candidates[0]
+				oldByIdentity[id] = candidates[1:]
+				debugf("document %d of the second file identified as %s", i+1, id)
+			}
+		} else if len(oldAnonymous) > 0 {
+			pair.Old = oldAnonymous[0]
+			oldAnonymous = oldAnonymous[1:]
+		}
+		if pair.Old >= 0 {
+			matched[pair.Old] = true
+			debugf("document %d of the first file matched document %d of the second file", pair.Old+1, i+1)
+		}
+		pairs = append(pairs, pair)
+	}
+
+	// Documents only present in the first file
+	for i := range documents1 {
+		if !matched[i] {
+			pairs = append(pairs, documentPair{Old: i, New: -1})
+		}
+	}
+	return pairs
+}
+
+// kubernetesIdentity identifies a Kubernetes object by kind, namespace and name
+func kubernetesIdentity(doc interface{}) (string, bool) {
+	m, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		return "", false
+	}
+	kind, hasKind := m["kind"]
+	metadata, hasMetadata := m["metadata"].(map[interface{}]interface{})
+	if !hasKind || !hasMetadata {
+		return "", false
+	}
+	name, hasName := metadata["name"]
+	if !hasName {
+		return "", false
+	}
+	namespace := metadata["namespace"]
+	if namespace == nil {
+		namespace = ""
+	}
+	return fmt.Sprintf("%v/%v/%v", kind, namespace, name), true
+}
+
+// pairDocumentsBySimilarity matches each document with the most similar
+// document of the other file, regardless of position. Documents without a
+// counterpart at least minDocumentSimilarity similar are left unmatched.
+func pairDocumentsBySimilarity(documents1, documents2 []YAMLDocument) []documentPair {
+	oldMatch := make(map[int]int)
+	newMatch := make(map[int]int)
+
+	// Identical documents are paired through their hashes, preferring the same
+	// position, so only the remaining documents are scored against each other
+	byHash := make(map[uint64][]int)
+	for j, newDoc := range documents2 {
+		hash := ymldiff.Hash(newDoc.Data)
+		byHash[hash] = append(byHash[hash], j)
+	}
+	for i, oldDoc := range documents1 {
+		candidates := byHash[ymldiff.Hash(oldDoc.Data)]
+		best := -1
+		for _, j := range candidates {
+			if _, taken := newMatch[j]; !taken && (best < 0 || intmath.Abs(i-j) < intmath.Abs(i-best)) {
+				best = j
+			}
+		}
+		if best >= 0 {
+			oldMatch[i] = best
+			newMatch[best] = i
+			debugf("document %d of the first file matched document %d of the second file (identical)", i+1, best+1)
+		}
+	}
+
+	score := func(i, j int) float64 {
+		_, oldTaken := oldMatch[i]
+		_, newTaken := newMatch[j]
+		if oldTaken || newTaken {
+			return -1
+		}
+		return ymldiff.Similarity(documents1[i].Data, documents2[j].Data, engineOptions())
+	}
+	for _, match := range ymldiff.MatchBySimilarity(len(documents1), len(documents2), score, minDocumentSimilarity) {
+		oldMatch[match.Old] = match.New
+		newMatch[match.New] = match.Old
+		debugf("document %d of the first file matched document %d of the second file (similarity %.2f)", match.Old+1, match.New+1, match.Similarity)
+	}
+
+	var pairs []documentPair
+	for j := range documents2 {
+		pair := documentPair{Old: -1, New: j}
+		if i, ok := newMatch[j]; ok {
+			pair.Old = i
+		}
+		pairs = append(pairs, pair)
+	}
+	for i := range documents1 {
+		if _, ok := oldMatch[i]; !ok {
+			pairs = append(pairs, documentPair{Old: i, New: -1})
+		}
+	}
+	return pairs
+}
+
+// formatMoveNote describes the move of a document between positions
+func formatMoveNote(pair documentPair) string {
+	return fmt.Sprintf("# Moved from document %d to document %d", pair.Old+1, pair.New+1)
+}
@@ -0,0 +1,10 @@
+package main
+
+import "ymldiff/pkg/ymldiff"
+
+// EquivalenceRule makes the values at the paths matching a pattern compare
+// equal when both match a regular expression
+type EquivalenceRule = ymldiff.EquivalenceRule
+
+// equivalenceRules are the active equivalence rules, set in the config file
+var equivalenceRules []EquivalenceRule
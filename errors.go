@@ -0,0 +1,67 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"ymldiff/pkg/ymldiff"
+)
+
+// parseError is an error in the content of an input file
+type parseError = ymldiff.ParseError
+
+// newParseError locates an error returned by one of the parsers in a file.
+// Each of several joined errors is located on its own.
+func newParseError(filename string, err error) error {
+	var tomlErr toml.ParseError
+	if errors.As(err, &tomlErr) {
+		return &parseError{File: filename, Line: tomlErr.Position.Line, Column: tomlErr.Position.Col, Message: tomlErr.Message}
+	}
+	return ymldiff.Locate(filename, err)
+}
+
+// errorOutcome classifies an error into the outcome deciding the exit code
+func errorOutcome(err error) string {
+	var located *parseError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return outcomeFileNotFound
+	case errors.As(err, &located):
+		return outcomeParseError
+	default:
+		return outcomeInternalError
+	}
+}
+
+// exitWithError prints an error, each of several joined errors on its own
+// line, and exits with the exit code of its class
+func exitWithError(err error) {
+	for _, e := range flattenErrors(err) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", e)
+	}
+	os.Exit(exitCodeFor(errorOutcome(err)))
+}
+
+// flattenErrors lists the errors joined into an error, however deeply nested
+func flattenErrors(err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flattenErrors(e)...)
+	}
+	return errs
+}
+
+// usageError prints an error about the command line along with the help text
+// and exits with the usage error exit code
+func usageError(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "Error: "+format+"\n\n", args...)
+	printHelp()
+	os.Exit(exitCodeFor(outcomeUsageError))
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"ymldiff/pkg/ymldiff"
+)
+
+// lookupPath returns the value at a change path of a normalized document
+func lookupPath(data interface{}, path string) (interface{}, bool) {
+	return ymldiff.Lookup(data, path, engineOptions())
+}
+
+// isUnderPath checks if a change path is the given path or nested below it
+func isUnderPath(changePath, path string) bool {
+	return isPathSegment(changePath, path)
+}
+
+// explainRules describes the comparison rules that apply to the values at a path
+func explainRules(oldValue, newValue interface{}, oldDoc, newDoc interface{}, path string) []string {
+	var rules []string
+	if rawMode {
+		rules = append(rules, "raw mode: no normalization, lists compared by position")
+	} else if len(normalizations) > 0 {
+		rules = append(rules, "normalizations applied: "+strings.Join(normalizations, ", "))
+	}
+	if !rawMode && isKubernetesKind(oldDoc, "Secret") && isKubernetesKind(newDoc, "Secret") {
+		rules = append(rules, "Secret stringData compared base64-encoded against data")
+	}
+
+	oldSlice, oldIsSlice := oldValue.([]interface{})
+	newSlice, newIsSlice := newValue.([]interface{})
+	switch {
+	case oldIsSlice && newIsSlice && !rawMode && isSliceOfDictsWithIds(oldSlice) && isSliceOfDictsWithIds(newSlice):
+		used := make(map[string]bool)
+		for _, item := range append(append([]interface{}{}, oldSlice...), newSlice...) {
+			if idKey, _, ok := ymldiff.ItemIdentifier(item, idKeys); ok {
+				used[idKey] = true
+			}
+		}
+		rules = append(rules, "list items matched by identifier field "+strings.Join(sortedKeys(used), ", "))
+	case (oldIsSlice || newIsSlice) && !rawMode:
+		rules = append(rules, fmt.Sprintf("list without identifier fields (%s): items sorted, then compared by position", strings.Join(idKeys, ", ")))
+	case oldIsSlice || newIsSlice:
+		rules = append(rules, "list items compared by position")
+	}
+
+	if pattern, ok := matchingPattern(ignorePatterns, path); ok {
+		rules = append(rules, fmt.Sprintf("ignored by pattern %q", pattern))
+	}
+	return rules
+}
+
+// explainDocument explains why changes at a path of a document were or weren't
+// reported. It returns an empty string when neither side has the path.
+func explainDocument(path string, oldDoc, newDoc interface{}, changes []Change, document, totalDocs int) string {
+	defer resetFormattedValues()
+	oldValue, inOld := lookupPath(oldDoc, path)
+	newValue, inNew := lookupPath(newDoc, path)
+	if !inOld && !inNew {
+		return ""
+	}
+
+	bold := color.New(color.Bold)
+	var result strings.Builder
+	result.WriteString(bold.Sprintf("Explain %s (document %d/%d)", debugPath(path), document, totalDocs) + "\n")
+
+	for _, side := range []struct {
+		label string
+		value interface{}
+		found bool
+	}{{"Old", oldValue, inOld}, {"New", newValue, inNew}} {
+		formatted := "<absent>"
+		if side.found {
+			value := side.value
+			if masking() {
+				value = maskValue(value, path)
+			}
+			formatted = formatValue(value)
+		}
+		if strings.Contains(formatted, "\n") {
+			result.WriteString(fmt.Sprintf("  %s (normalized):\n%s\n", side.label, indentLines(4, formatted)))
+		} else {
+			result.WriteString(fmt.Sprintf("  %s (normalized): %s\n", side.label, formatted))
+		}
+	}
+
+	if rules := explainRules(oldValue, newValue, oldDoc, newDoc, path); len(rules) > 0 {
+		result.WriteString("  Rules:\n")
+		for _, rule := range rules {
+			result.WriteString("    - " + rule + "\n")
+		}
+	}
+
+	var under []Change
+	for _, change := range changes {
+		if isUnderPath(change.Path, path) {
+			under = append(under, change)
+		}
+	}
+	sort.Slice(under, func(i, j int) bool {
+		return under[i].Path < under[j].Path
+	})
+
+	if len(under) == 0 {
+		result.WriteString("  Result: not reported, both sides are equal after normalization\n")
+		return result.String()
+	}
+
+	reported := 0
+	var details []string
+	for _, change := range under {
+		if pattern, ignored := matchingPattern(ignorePatterns, change.Path); ignored {
+			details = append(details, fmt.Sprintf("%s %s (ignored by %q)", change.Type, debugPath(change.Path), pattern))
+		} else {
+			reported++
+			details = append(details, fmt.Sprintf("%s %s", change.Type, debugPath(change.Path)))
+		}
+	}
+	if reported == 0 {
+		result.WriteString("  Result: not reported, every change is ignored\n")
+	} else {
+		result.WriteString(fmt.Sprintf("  Result: reported, %d %s\n", reported, pluralize(reported, "change", "changes")))
+	}
+	for _, detail := range details {
+		result.WriteString("    " + detail + "\n")
+	}
+	return result.String()
+}
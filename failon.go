@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// changeTypeNames maps the names of change types, as in the JSON report, to the types
+var changeTypeNames = map[string]ChangeType{
+	"addition":     Addition,
+	"deletion":     Deletion,
+	"modification": Modification,
+	"move":         Move,
+}
+
+// bumpNames are the names of semantic version bumps --fail-on accepts
+var bumpNames = map[string]string{
+	"major-bump": "major",
+	"minor-bump": "minor",
+	"patch-bump": "patch",
+}
+
+// failOnTypes are the change types failing the comparison, set with --fail-on.
+// Without them any change counts as a difference.
+var failOnTypes map[ChangeType]bool
+
+// failOnBumps are the levels of semantic version bumps failing the
+// comparison, set with --fail-on
+var failOnBumps map[string]bool
+
+// failOnSpec is the list given with --fail-on, as given
+var failOnSpec string
+
+// parseFailOn parses a comma-separated list of change types and semantic
+// version bumps
+func parseFailOn(spec string) (map[ChangeType]bool, map[string]bool, error) {
+	types := make(map[ChangeType]bool)
+	bumps := make(map[string]bool)
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if level, ok := bumpNames[name]; ok {
+			bumps[level] = true
+			continue
+		}
+		changeType, ok := changeTypeNames[name]
+		if !ok {
+			return nil, nil, fmt.Errorf("unknown change type %q in --fail-on (available: addition, deletion, modification, move, major-bump, minor-bump, patch-bump)", name)
+		}
+		types[changeType] = true
+	}
+	return types, bumps, nil
+}
+
+// failingChanges counts the changes of the types given with --fail-on
+func failingChanges(counts changeCounts) int {
+	failing := 0
+	for changeType, count := range map[ChangeType]int{
+		Addition:     counts.Additions,
+		Deletion:     counts.Deletions,
+		Modification: counts.Modifications,
+		Move:         counts.Moves,
+	} {
+		if failOnTypes[changeType] {
+			failing += count
+		}
+	}
+	return failing
+}
+
+// failingBumps counts the modifications bumping a semantic version, up or
+// down, by a level given with --fail-on
+func failingBumps(documents []DocumentResult) int {
+	failing := 0
+	for _, document := range documents {
+		for _, change := range document.Changes {
+			if change.Type != Modification {
+				continue
+			}
+			if level, _, ok := semverBump(change.OldValue, change.NewValue); ok && failOnBumps[level] {
+				failing++
+			}
+		}
+	}
+	return failing
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// inputFormats lists the supported input formats
+var inputFormats = []string{"yaml", "json", "toml", "env"}
+
+// detectFormat guesses the format of a file from its name, defaulting to YAML
+func detectFormat(filename string) string {
+	filename, _, _ = splitRevision(filename)
+	base := strings.ToLower(filepath.Base(filename))
+	switch {
+	case strings.HasSuffix(base, ".json"):
+		return "json"
+	case strings.HasSuffix(base, ".toml"):
+		return "toml"
+	case base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env"):
+		return "env"
+	}
+	return "yaml"
+}
+
+// parseInput parses a file in the given format, detecting the format from the
+// file name when none is given
+func parseInput(filename, format string) ([]YAMLDocument, error) {
+	data, err := readInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(filename, format, data)
+}
+
+// parseSource parses the content read from a file, in the given format or
+// else the one detected from the file name
+func parseSource(filename, format string, data []byte) ([]YAMLDocument, error) {
+	if format == "" {
+		format = detectFormat(filename)
+	}
+	documents, err := parseData(data, format)
+	if err != nil {
+		return nil, newParseError(filename, err)
+	}
+	return documents, nil
+}
+
+// parseData parses content in the given format
+func parseData(data []byte, format string) ([]YAMLDocument, error) {
+	switch format {
+	case "yaml", "json":
+		// JSON is parsed as YAML, of which it is a subset
+		return parseYAMLData(data)
+	case "toml", "env":
+		var doc interface{}
+		var err error
+		if format == "toml" {
+			doc, err = parseTOML(data)
+		} else {
+			doc, err = parseEnv(data)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return []YAMLDocument{{Data: normalizeValue(doc)}}, nil
+	default:
+		return nil, fmt.Errorf("unknown input format %q", format)
+	}
+}
+
+// parseTOML decodes a TOML document, converting integers to int so they
+// compare equal to the same values read from YAML
+func parseTOML(data []byte) (interface{}, error) {
+	var doc map[string]interface{}
+	if _, err := toml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return convertTOMLIntegers(doc), nil
+}
+
+// convertTOMLIntegers replaces int64 values that fit into an int
+func convertTOMLIntegers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for key, child := range val {
+			val[key] = convertTOMLIntegers(child)
+		}
+	case []map[string]interface{}:
+		converted := make([]interface{}, len(val))
+		for i, child := range val {
+			converted[i] = convertTOMLIntegers(child)
+		}
+		return converted
+	case []interface{}:
+		for i, child := range val {
+			val[i] = convertTOMLIntegers(child)
+		}
+	case int64:
+		if val >= math.MinInt && val <= math.MaxInt {
+			return int(val)
+		}
+	}
+	return v
+}
+
+// parseEnv decodes a dotenv file of KEY=value lines into a map of strings.
+// Blank lines, comments and an "export " prefix are ignored, and quoted values
+// are unquoted.
+func parseEnv(data []byte) (interface{}, error) {
+	doc := make(map[string]interface{})
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, found := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			column := len(raw) - len(strings.TrimLeft(raw, " \t")) + 1
+			return nil, &parseError{Line: lineNumber, Column: column, Message: "expected KEY=value"}
+		}
+		value = strings.TrimSpace(value)
+
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				column := len([]rune(raw[:strings.LastIndex(raw, value)])) + 1
+				return nil, &parseError{Line: lineNumber, Column: column, Message: err.Error()}
+			}
+			value = unquoted
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		default:
+			// Unquoted values may carry a trailing comment
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+		doc[key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
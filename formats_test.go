@@ -0,0 +1,503 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDetectFormat tests guessing the input format from the file name
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{
+		"config.yaml":       "yaml",
+		"config.yml":        "yaml",
+		"package.JSON":      "json",
+		"Cargo.toml":        "toml",
+		".env":              "env",
+		".env.production":   "env",
+		"prod.env":          "env",
+		"-":                 "yaml",
+		"templates/app.tpl": "yaml",
+	}
+
+	for filename, expected := range tests {
+		if format := detectFormat(filename); format != expected {
+			t.Errorf("detectFormat(%q) = %q, expected %q", filename, format, expected)
+		}
+	}
+}
+
+// TestParseEnv tests decoding dotenv files
+func TestParseEnv(t *testing.T) {
+	content := "# database\nexport DB_HOST=localhost\nDB_PORT=5432 # default\n\nGREETING=\"hello\\nworld\"\nRAW='a # b'\nEMPTY=\n"
+	doc, err := parseEnv([]byte(content))
+	if err != nil {
+		t.Fatalf("parseEnv() error: %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"DB_HOST":  "localhost",
+		"DB_PORT":  "5432",
+		"GREETING": "hello\nworld",
+		"RAW":      "a # b",
+		"EMPTY":    "",
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("parseEnv() = %v, expected %v", doc, expected)
+	}
+
+	if _, err := parseEnv([]byte("NOT A PAIR\n")); err == nil {
+		t.Error("Expected an error for a line without '='")
+	}
+}
+
+// TestParseInputFormats tests that TOML and YAML with the same content compare equal
+func TestParseInputFormats(t *testing.T) {
+	dir := t.TempDir()
+	tomlFile := filepath.Join(dir, "config.toml")
+	yamlFile := filepath.Join(dir, "config.txt")
+	writeTestFile(t, tomlFile, "replicas = 3\n\n[[servers]]\nname = \"a\"\nport = 80\n")
+	writeTestFile(t, yamlFile, "replicas: 3\nservers:\n- name: a\n  port: 80\n")
+
+	docs1, err := parseInput(tomlFile, "")
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", tomlFile, err)
+	}
+	docs2, err := parseInput(yamlFile, "yaml")
+	if err != nil {
+		t.Fatalf("Failed to parse %s: %v", yamlFile, err)
+	}
+
+	if changes := diffValues(docs1[0].Data, docs2[0].Data, ""); len(changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", changes)
+	}
+
+	// Forcing the wrong format reports a parse error
+	if _, err := parseInput(yamlFile, "toml"); err == nil {
+		t.Error("Expected an error parsing YAML as TOML")
+	}
+}
+
+// TestExtractFrontMatter tests extracting the front matter block
+func TestExtractFrontMatter(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{
+			name:     "front matter",
+			content:  "---\ntitle: Hello\ntags: [a, b]\n---\n# Body\n\n---\nnot: yaml\n",
+			expected: "---\ntitle: Hello\ntags: [a, b]\n",
+		},
+		{
+			name:     "dots terminator and CRLF",
+			content:  "---\r\ntitle: Hello\r\n...\r\nBody\r\n",
+			expected: "---\r\ntitle: Hello\r\n",
+		},
+		{
+			name:     "no front matter",
+			content:  "# Title\n\ntitle: nope\n",
+			expected: "",
+		},
+		{
+			name:     "unterminated",
+			content:  "---\ntitle: Hello\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := string(extractFrontMatter([]byte(tt.content))); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+// TestMarkdownFrontMatterDiff tests that Markdown files are compared by front matter only
+func TestMarkdownFrontMatterDiff(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "post1.md")
+	file2 := filepath.Join(dir, "post2.md")
+	writeTestFile(t, file1, "---\ntitle: Hello\ndraft: true\n---\nFirst body: with colon\n")
+	writeTestFile(t, file2, "---\ntitle: Hello\ndraft: false\n---\nCompletely different body\n- list\n")
+
+	docs1, err := parseYAML(file1)
+	if err != nil {
+		t.Fatalf("Failed to parse file1: %v", err)
+	}
+	docs2, err := parseYAML(file2)
+	if err != nil {
+		t.Fatalf("Failed to parse file2: %v", err)
+	}
+
+	changes := diffValues(docs1[0].Data, docs2[0].Data, "")
+	if len(changes) != 1 || changes[0].Path != ".draft" {
+		t.Errorf("Expected a single change at .draft, got %v", changes)
+	}
+	if docs2[0].Node.Content[0].Line != 2 {
+		t.Errorf("Expected front matter to start on line 2, got %d", docs2[0].Node.Content[0].Line)
+	}
+}
+
+// TestParseErrorLocation tests that parse errors name the file, line and column
+func TestParseErrorLocation(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"bad.yaml", "a: 1\nb: [\n", "bad.yaml:2:4: did not find expected node content"},
+		{"first.yaml", "a: b: c\n", "first.yaml:1:5: mapping values are not allowed in this context"},
+		{"tab.yaml", "a:\n\tb: 1\n", "tab.yaml:2:1: found character that cannot start any token"},
+		{"duplicate.yaml", "a: 1\nb:\n  c: 1\n  c: 2\n", "duplicate.yaml:4:3: mapping key \"c\" already defined at line 3"},
+		{"key.yaml", "a: 1\n? [b]\n: 2\n", "key.yaml:2:3: invalid map key: []interface {}{\"b\"}"},
+		{"quote.env", "A=1\nB = \"a\\q\"\n", "quote.env:2:5: invalid syntax"},
+		{"bad.toml", "a = 1\nb = \n", "bad.toml:2:5: expected value but found '\\n' instead"},
+		{"bad.env", "A=1\nB\n", "bad.env:2:1: expected KEY=value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(dir, tt.name)
+			writeTestFile(t, file, tt.content)
+			_, err := parseInput(file, "")
+			if err == nil {
+				t.Fatal("Expected a parse error")
+			}
+			if expected := filepath.Join(dir, tt.expected); err.Error() != expected {
+				t.Errorf("Expected %q, got %q", expected, err.Error())
+			}
+			if outcome := errorOutcome(err); outcome != outcomeParseError {
+				t.Errorf("Expected outcome %s, got %s", outcomeParseError, outcome)
+			}
+		})
+	}
+}
+
+// TestErrorOutcome tests classifying errors into exit code outcomes
+func TestErrorOutcome(t *testing.T) {
+	_, err := parseInput(filepath.Join(t.TempDir(), "missing.yaml"), "")
+	if outcome := errorOutcome(err); outcome != outcomeFileNotFound {
+		t.Errorf("Expected %s for a missing file, got %s", outcomeFileNotFound, outcome)
+	}
+	if outcome := errorOutcome(errors.New("boom")); outcome != outcomeInternalError {
+		t.Errorf("Expected %s for other errors, got %s", outcomeInternalError, outcome)
+	}
+
+	codes := map[int]string{}
+	for outcome, code := range defaultExitCodes {
+		if outcome == outcomeIdentical || outcome == outcomeChanges || outcome == outcomeForbiddenPathChange || outcome == outcomeTooManyChanges || outcome == outcomeFailOnChange {
+			continue
+		}
+		if other, taken := codes[code]; taken {
+			t.Errorf("Outcomes %s and %s share the default exit code %d", outcome, other, code)
+		}
+		codes[code] = outcome
+	}
+}
+
+// TestAllParseErrors tests that the errors of every broken document and file are reported
+func TestAllParseErrors(t *testing.T) {
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "old.yaml")
+	file2 := filepath.Join(dir, "new.yaml")
+	writeTestFile(t, file1, "a: [\n---\nb: 1\n---\nc: {\n")
+	writeTestFile(t, file2, "a: 1\n  b: 2\n")
+
+	_, err := compareFiles(file1, file2)
+	var messages []string
+	for _, e := range flattenErrors(err) {
+		messages = append(messages, e.Error())
+	}
+	expected := []string{
+		file1 + ":1:4: did not find expected node content",
+		file1 + ":5:4: did not find expected node content",
+		file2 + ":2:4: mapping values are not allowed in this context",
+	}
+	if !reflect.DeepEqual(messages, expected) {
+		t.Errorf("Expected errors %q, got %q", expected, messages)
+	}
+	if outcome := errorOutcome(err); outcome != outcomeParseError {
+		t.Errorf("Expected outcome %s, got %s", outcomeParseError, outcome)
+	}
+}
+
+// TestStrictMode tests that strict mode fails on values it can't compare
+func TestStrictMode(t *testing.T) {
+	defer func(strict bool) { strictMode = strict }(strictMode)
+
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{"standard tags", "a: !!str 1\nb: !!binary aGVsbG8=\nc: &x {d: 1}\ne: *x\nf:\n  <<: *x\n", nil},
+		{"unknown tag", "password: !vault abc\n", []string{"1:11: unknown tag !vault would be dropped"}},
+		{"complex key", "? [a, b]\n: 1\n", []string{"1:3: map key is a map or list"}},
+		{"source order", "a: !x 1\n? {b: 1}\n: 2\n", []string{"1:4: unknown tag !x", "2:3: map key is a map or list"}},
+		{"every document", "a: !secret x\n---\nb: 1\n---\nc: !ref y\n", []string{"1:4: unknown tag !secret", "5:4: unknown tag !ref"}},
+	}
+	for _, tt := range tests {
+		// Map keys that are maps or lists fail to decode either way
+		strictMode = false
+		if _, err := parseYAMLData([]byte(tt.content)); err != nil && !strings.Contains(strings.Join(tt.expected, ""), "map key") {
+			t.Errorf("%s: unexpected error without --strict: %v", tt.name, err)
+		}
+
+		strictMode = true
+		_, err := parseYAMLData([]byte(tt.content))
+		if len(tt.expected) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+			continue
+		}
+		errs := flattenErrors(newParseError("in.yaml", err))
+		if len(errs) != len(tt.expected) {
+			t.Errorf("%s: got %d errors (%v), expected %d", tt.name, len(errs), err, len(tt.expected))
+			continue
+		}
+		for i, expected := range tt.expected {
+			if !strings.Contains(errs[i].Error(), "in.yaml:"+expected) {
+				t.Errorf("%s: error %q, expected it to contain %q", tt.name, errs[i], expected)
+			}
+		}
+	}
+}
+
+// TestReadFileMapped tests that files above the threshold are parsed from a memory mapping
+func TestReadFileMapped(t *testing.T) {
+	originalThreshold := mmapThreshold
+	defer func() { mmapThreshold = originalThreshold }()
+	mmapThreshold = 1
+
+	path := filepath.Join(t.TempDir(), "large.yaml")
+	content := "a: 1\n---\nb: [x, y]\n"
+	writeTestFile(t, path, content)
+
+	data, err := readFile(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(data) != content {
+		t.Errorf("Expected %q, got %q", content, data)
+	}
+
+	documents, err := parseYAML(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(documents) != 2 {
+		t.Errorf("Expected 2 documents, got %d", len(documents))
+	}
+
+	// Empty files can't be mapped and are read instead
+	empty := filepath.Join(t.TempDir(), "empty.yaml")
+	writeTestFile(t, empty, "")
+	if data, err := readFile(empty); err != nil || len(data) != 0 {
+		t.Errorf("Expected empty content, got %q (%v)", data, err)
+	}
+}
+
+// TestSplitRevision tests splitting inputs into a file and a git revision
+func TestSplitRevision(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "user@host.yaml")
+	writeTestFile(t, existing, "a: 1\n")
+
+	tests := []struct {
+		input, file, revision string
+		ok                    bool
+	}{
+		{"config.yaml@HEAD~3", "config.yaml", "HEAD~3", true},
+		{"deploy/app.yaml@v1.2.0", "deploy/app.yaml", "v1.2.0", true},
+		{"config.yaml", "config.yaml", "", false},
+		{"config.yaml@", "config.yaml@", "", false},
+		{"@HEAD", "@HEAD", "", false},
+		{existing, existing, "", false},
+	}
+	for _, tt := range tests {
+		file, revision, ok := splitRevision(tt.input)
+		if file != tt.file || revision != tt.revision || ok != tt.ok {
+			t.Errorf("splitRevision(%q) = %q, %q, %v, expected %q, %q, %v", tt.input, file, revision, ok, tt.file, tt.revision, tt.ok)
+		}
+	}
+}
+
+// TestReadRevision tests reading inputs from earlier git revisions
+func TestReadRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir := t.TempDir()
+	if _, err := runGit(dir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, "config.yaml", "replicas: 1\n", "alice")
+	commitFile(t, dir, "config.yaml", "replicas: 2\n", "bob")
+
+	file := filepath.Join(dir, "config.yaml")
+	documents, err := parseInput(file+"@HEAD~1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(documents) != 1 || documents[0].Data.(map[interface{}]interface{})["replicas"] != 1 {
+		t.Errorf("Expected replicas 1 from HEAD~1, got %v", documents)
+	}
+
+	for _, input := range []string{file + "@no-such-revision", file + "@HEAD~5", filepath.Join(dir, "missing.yaml") + "@HEAD"} {
+		if _, err := parseInput(input, ""); errorOutcome(err) != outcomeFileNotFound {
+			t.Errorf("Expected %s not to be found, got %v", input, err)
+		}
+	}
+}
+
+// TestParseExternalDiffArgs tests reading the arguments git passes to an external diff program
+func TestParseExternalDiffArgs(t *testing.T) {
+	args := []string{"config.yaml", "/tmp/x_config.yaml", "3f2a9c1", "100644", "config.yaml", "81d07be", "100644"}
+	t.Setenv("GIT_DIFF_PATH_COUNTER", "")
+	t.Setenv("GIT_EXTERNAL_DIFF", "")
+	if _, ok := parseExternalDiffArgs(args); ok {
+		t.Error("Expected arguments not passed by git not to be read as an external diff")
+	}
+	t.Setenv("GIT_DIFF_PATH_COUNTER", "1")
+
+	diff, ok := parseExternalDiffArgs(args)
+	if !ok || diff.Path != "config.yaml" || diff.OldFile != "/tmp/x_config.yaml" || diff.NewFile != "config.yaml" || diff.NewPath != "config.yaml" {
+		t.Errorf("Unexpected 7-argument diff %+v", diff)
+	}
+
+	diff, ok = parseExternalDiffArgs([]string{"old.yaml", "/tmp/x_old.yaml", "3f2a9c1", "100644", "new.yaml", "81d07be", "100644", "new.yaml", "similarity index 90%\n"})
+	if !ok || diff.Path != "old.yaml" || diff.NewPath != "new.yaml" {
+		t.Errorf("Unexpected 9-argument diff %+v", diff)
+	}
+
+	if _, ok := parseExternalDiffArgs([]string{"old.yaml", "new.yaml"}); ok {
+		t.Error("Expected two files not to be read as an external diff")
+	}
+	if _, ok := parseExternalDiffArgs([]string{"a.yaml", "b.yaml", "c.yaml", "d.yaml", "e.yaml", "f.yaml", "g.yaml"}); ok {
+		t.Error("Expected seven files not to be read as an external diff")
+	}
+
+	diff, ok = parseExternalDiffArgs([]string{"added.yaml", "/dev/null", ".", ".", "/tmp/x_added.yaml", "81d07be", "100644"})
+	if !ok || diff.OldFile != "/dev/null" {
+		t.Errorf("Unexpected diff of an added file %+v", diff)
+	}
+}
+
+// TestCompareAddedFile tests comparing a file added in git against /dev/null
+func TestCompareAddedFile(t *testing.T) {
+	file := createTempFile(t, "added-*.yaml", "replicas: 2\n")
+	defer os.Remove(file)
+
+	result, err := compareFiles(os.DevNull, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := result.Changes()
+	if len(changes) != 1 || changes[0].Type != Addition || changes[0].Path != "" {
+		t.Errorf("Expected the whole document to be added, got %+v", changes)
+	}
+}
+
+// TestParseInputLabel tests parsing the names given with --label
+func TestParseInputLabel(t *testing.T) {
+	side, name, err := parseInputLabel("Old=staging")
+	if err != nil || side != "old" || name != "staging" {
+		t.Errorf("parseInputLabel(Old=staging) = %q, %q, %v, expected old, staging", side, name, err)
+	}
+	for _, spec := range []string{"staging", "left=staging", "new="} {
+		if _, _, err := parseInputLabel(spec); err == nil {
+			t.Errorf("parseInputLabel(%q) expected an error", spec)
+		}
+	}
+}
+
+// TestInputNames tests that labels replace file names in headers and reports
+func TestInputNames(t *testing.T) {
+	defer func(names map[string]string) { inputNames = names }(inputNames)
+	inputNames = map[string]string{"new": "prod"}
+
+	if name := inputName("Old", "/dev/fd/63"); name != "/dev/fd/63" {
+		t.Errorf("inputName(Old) = %q, expected the file name", name)
+	}
+	if name := inputName("New", "/dev/fd/64"); name != "prod" {
+		t.Errorf("inputName(New) = %q, expected prod", name)
+	}
+
+	header := formatReportHeader([]string{"Old", "New"}, []string{"/dev/fd/63", "/dev/fd/64"}, nil, time.Now())
+	if !strings.Contains(header, "# New:       prod, /dev/fd/64") {
+		t.Errorf("Expected the label in the report header, got:\n%s", header)
+	}
+
+	inputs := newJSONInputs([]string{"Old", "New"}, []string{"/dev/fd/63", "/dev/fd/64"})
+	if inputs[0].Name != "" || inputs[1].Name != "prod" {
+		t.Errorf("newJSONInputs names = %q, %q, expected \"\", prod", inputs[0].Name, inputs[1].Name)
+	}
+}
+
+// TestRenderTemplate tests rendering templated inputs with values
+func TestRenderTemplate(t *testing.T) {
+	originalTemplateValues := templateValues
+	defer func() { templateValues = originalTemplateValues }()
+
+	valuesFile := createTempFile(t, "values.yaml", "image:\n  tag: \"1.2.3\"\nreplicas: 3\nlabels:\n  app: web\n")
+	defer os.Remove(valuesFile)
+	overrideFile := createTempFile(t, "override.yaml", "replicas: 5\n")
+	defer os.Remove(overrideFile)
+
+	var err error
+	templateValues, err = loadTemplateValues([]string{valuesFile, overrideFile})
+	if err != nil {
+		t.Fatalf("Failed to load values: %v", err)
+	}
+
+	template := `image: app:{{ .image.tag }}
+replicas: {{ .replicas }}
+env: {{ .env | default "prod" | quote }}
+metadata:
+  labels:{{ .labels | toYaml | nindent 4 }}
+`
+	rendered, err := renderTemplate("deploy.yaml", []byte(template))
+	if err != nil {
+		t.Fatalf("Failed to render template: %v", err)
+	}
+
+	expected := `image: app:1.2.3
+replicas: 5
+env: "prod"
+metadata:
+  labels:
+    app: web
+`
+	if string(rendered) != expected {
+		t.Errorf("Unexpected rendered output:\n%s\nexpected:\n%s", rendered, expected)
+	}
+}
+
+// TestRenderTemplateErrors tests that template errors are reported
+func TestRenderTemplateErrors(t *testing.T) {
+	originalTemplateValues := templateValues
+	defer func() { templateValues = originalTemplateValues }()
+	templateValues = map[string]interface{}{}
+
+	if _, err := renderTemplate("bad.yaml", []byte("value: {{ .missing")); err == nil || !strings.Contains(err.Error(), "parsing template") {
+		t.Errorf("Expected template parse error, got %v", err)
+	}
+	if _, err := renderTemplate("req.yaml", []byte(`value: {{ required "image is required" "" }}`)); err == nil || !strings.Contains(err.Error(), "image is required") {
+		t.Errorf("Expected required error, got %v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+)
+
+// isMarkdownFile checks if a file name has a Markdown extension
+func isMarkdownFile(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown":
+		return true
+	}
+	return false
+}
+
+// extractFrontMatter returns the "---" delimited YAML front matter at the start
+// of a document, or nothing when there is none. The opening delimiter is kept
+// so that line numbers still match the original file.
+func extractFrontMatter(data []byte) []byte {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) == 0 || string(bytes.TrimRight(lines[0], "\r\n")) != "---" {
+		return nil
+	}
+
+	length := len(lines[0])
+	for _, line := range lines[1:] {
+		switch string(bytes.TrimRight(line, " \t\r\n")) {
+		case "---", "...":
+			return data[:length]
+		}
+		length += len(line)
+	}
+
+	// Unterminated front matter is not front matter
+	return nil
+}
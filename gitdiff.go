@@ -0,0 +1,47 @@
+package main
+
+import (
+	"os"
+	"regexp"
+)
+
+// externalDiff holds the arguments git passes to an external diff program
+// set with GIT_EXTERNAL_DIFF or diff.external
+type externalDiff struct {
+	Path    string // path of the file in the repository
+	OldFile string // /dev/null for an added file
+	NewFile string // /dev/null for a removed file
+	NewPath string // path after a rename, or Path
+}
+
+// objectIDPattern matches the object ids git passes to an external diff
+// program, "." standing for a missing file
+var objectIDPattern = regexp.MustCompile(`^([0-9a-f]{7,64}|\.)$`)
+
+// fileModePattern matches the octal file modes git passes to an external diff
+// program, "." standing for a missing file
+var fileModePattern = regexp.MustCompile(`^([0-7]{6}|\.)$`)
+
+// parseExternalDiffArgs reads the 7 arguments git passes to an external diff
+// program (path, old-file, old-hex, old-mode, new-file, new-hex, new-mode),
+// or the 9 it passes for renames (adding the new path and a description).
+// Git sets GIT_DIFF_PATH_COUNTER, or GIT_EXTERNAL_DIFF, when it runs one, so
+// other arguments that happen to count 7 or 9 aren't taken for them.
+func parseExternalDiffArgs(args []string) (externalDiff, bool) {
+	if len(args) != 7 && len(args) != 9 {
+		return externalDiff{}, false
+	}
+	if os.Getenv("GIT_DIFF_PATH_COUNTER") == "" && os.Getenv("GIT_EXTERNAL_DIFF") == "" {
+		return externalDiff{}, false
+	}
+	for _, i := range []int{2, 5} {
+		if !objectIDPattern.MatchString(args[i]) || !fileModePattern.MatchString(args[i+1]) {
+			return externalDiff{}, false
+		}
+	}
+	diff := externalDiff{Path: args[0], OldFile: args[1], NewFile: args[4], NewPath: args[0]}
+	if len(args) == 9 {
+		diff.NewPath = args[7]
+	}
+	return diff, true
+}
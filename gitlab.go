@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// codeQualityIssue is a change as an issue of a GitLab code quality report
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+// codeQualityLocation is the file and line an issue is shown at
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+// codeQualityLines holds the first line of an issue
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+// newCodeQualityIssues converts the changes of a document into code quality
+// issues located in the new file. Removed entries are shown at the line of
+// their parent, and anything without a line at the start of the document.
+// Changes to forbidden paths are major issues, all others minor.
+func newCodeQualityIssues(changes []Change, document int, file string, newRoot *yaml.Node, newData interface{}) []codeQualityIssue {
+	path := filepath.ToSlash(filepath.Clean(file))
+	issues := make([]codeQualityIssue, 0, len(changes))
+	for _, change := range changes {
+		line := 0
+		if change.Type == Deletion {
+			if parent, _ := splitLastSegment(change.Path); parent != "" {
+				line = entryLine(newRoot, newData, parent)
+			}
+		} else {
+			line = entryLine(newRoot, newData, change.Path)
+		}
+		if line == 0 {
+			line = 1
+			if root := resolveNode(newRoot); root != nil {
+				line = root.Line
+			}
+		}
+
+		severity := "minor"
+		if matchAnyPath(forbiddenPaths, change.Path) {
+			severity = "major"
+		}
+
+		fingerprint := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s\x00%s", path, document, change.Path, change.Type)))
+		issues = append(issues, codeQualityIssue{
+			Description: codeQualityDescription(change),
+			CheckName:   "ymldiff",
+			Fingerprint: hex.EncodeToString(fingerprint[:]),
+			Severity:    severity,
+			Location:    codeQualityLocation{Path: path, Lines: codeQualityLines{Begin: line}},
+		})
+	}
+	return issues
+}
+
+// codeQualityDescription describes a change in one line
+func codeQualityDescription(change Change) string {
+	path := debugPath(change.Path)
+	switch change.Type {
+	case Addition:
+		return fmt.Sprintf("Added %s: %s", path, formatSingleLine(change.NewValue, maxAnnotationValueWidth))
+	case Deletion:
+		return fmt.Sprintf("Removed %s (was %s)", path, formatSingleLine(change.OldValue, maxAnnotationValueWidth))
+	case Move:
+		return fmt.Sprintf("Moved %s from position %v to %v", path, change.OldValue, change.NewValue)
+	default:
+		return fmt.Sprintf("Changed %s: %s → %s", path,
+			formatSingleLine(change.OldValue, maxAnnotationValueWidth), formatSingleLine(change.NewValue, maxAnnotationValueWidth))
+	}
+}
+
+// formatCodeQuality renders issues as a GitLab code quality report
+func formatCodeQuality(issues []codeQualityIssue) (string, error) {
+	if issues == nil {
+		issues = []codeQualityIssue{}
+	}
+	data, err := json.MarshalIndent(issues, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
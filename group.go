@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// formatGroupedChanges formats changes grouped under a header per parent path,
+// labelling each change with its last path segment only. Given the documents,
+// each header tells how many of the parent's keys or items changed.
+func formatGroupedChanges(changes []Change, oldDoc, newDoc interface{}) string {
+	groups := make(map[string][]Change)
+	var parents []string
+	for _, change := range changes {
+		parent, _ := splitLastSegment(change.Path)
+		if _, exists := groups[parent]; !exists {
+			parents = append(parents, parent)
+		}
+		groups[parent] = append(groups[parent], change)
+	}
+	if sortsParents() {
+		sort.Strings(parents)
+	}
+
+	var result strings.Builder
+	bold := color.New(color.Bold)
+	for _, parent := range parents {
+		// Changes at the document root are not nested under a header
+		if parent == "" {
+			for _, change := range groups[parent] {
+				result.WriteString(formatChange(change, debugPath(change.Path), ""))
+			}
+			continue
+		}
+
+		result.WriteString(bold.Sprint(parent + ":"))
+		if total, noun, ok := childCount(parent, oldDoc, newDoc); ok {
+			changed := make(map[string]bool)
+			for _, change := range groups[parent] {
+				_, segment := splitLastSegment(change.Path)
+				changed[segment] = true
+			}
+			result.WriteString(themeColor(theme.Comment).Sprintf(" (%d of %d %s changed)", len(changed), total, noun))
+		}
+		result.WriteString("\n")
+		for _, change := range groups[parent] {
+			_, segment := splitLastSegment(change.Path)
+			result.WriteString(formatChange(change, strings.TrimPrefix(segment, "."), "  "))
+		}
+	}
+
+	return result.String()
+}
+
+// childCount counts the keys of the map at a path in either document, or the
+// items of the longer of the lists there
+func childCount(path string, oldDoc, newDoc interface{}) (int, string, bool) {
+	oldValue, _ := lookupPath(oldDoc, path)
+	newValue, _ := lookupPath(newDoc, path)
+	oldMap, oldIsMap := oldValue.(map[interface{}]interface{})
+	newMap, newIsMap := newValue.(map[interface{}]interface{})
+	if oldIsMap || newIsMap {
+		keys := make(map[interface{}]bool)
+		for key := range oldMap {
+			keys[key] = true
+		}
+		for key := range newMap {
+			keys[key] = true
+		}
+		return len(keys), pluralize(len(keys), "key", "keys"), true
+	}
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := newValue.([]interface{})
+	if oldIsList || newIsList {
+		items := max(len(oldList), len(newList))
+		return items, pluralize(items, "item", "items"), true
+	}
+	return 0, "", false
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// matchPath checks if a change path matches the given path pattern
+func matchPath(pattern, path string) bool {
+	return ymldiff.MatchPath(pattern, path)
+}
+
+// matchAnyPath checks if a change path matches any of the given patterns
+func matchAnyPath(patterns []string, path string) bool {
+	_, matched := matchingPattern(patterns, path)
+	return matched
+}
+
+// matchingPattern returns the first of the given patterns matching a change path
+func matchingPattern(patterns []string, path string) (string, bool) {
+	for _, pattern := range patterns {
+		if matchPath(pattern, path) {
+			return pattern, true
+		}
+	}
+	return "", false
+}
+
+// isIgnored checks if a path matches an ignore pattern, tracing the pattern that fired
+func isIgnored(patterns []string, path string) bool {
+	pattern, matched := matchingPattern(patterns, path)
+	if matched {
+		debugf("%s: ignored by %q", debugPath(path), pattern)
+	}
+	return matched
+}
+
+// filterIgnored drops changes whose path matches one of the ignore patterns.
+// Ignored fields nested inside added or removed values are pruned as well, and
+// a change is dropped entirely when nothing but ignored fields remains.
+func filterIgnored(changes []Change, patterns []string) []Change {
+	if len(patterns) == 0 {
+		return changes
+	}
+
+	var filtered []Change
+	for _, change := range changes {
+		if isIgnored(patterns, change.Path) {
+			continue
+		}
+		if change.Type == Addition || change.Type == Deletion {
+			var emptied bool
+			change.OldValue, emptied = pruneIgnored(change.OldValue, change.Path, patterns)
+			if emptied {
+				continue
+			}
+			change.NewValue, emptied = pruneIgnored(change.NewValue, change.Path, patterns)
+			if emptied {
+				continue
+			}
+		}
+		filtered = append(filtered, change)
+	}
+	return filtered
+}
+
+// pruneIgnored removes nested entries matching the ignore patterns from a value.
+// It reports whether pruning left a collection that had content empty.
+func pruneIgnored(v interface{}, path string, patterns []string) (interface{}, bool) {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		if len(val) == 0 {
+			return val, false
+		}
+		pruned := make(map[interface{}]interface{})
+		for key, child := range val {
+			childPath := path + "." + fmt.Sprintf("%v", key)
+			if isIgnored(patterns, childPath) {
+				continue
+			}
+			if prunedChild, emptied := pruneIgnored(child, childPath, patterns); !emptied {
+				pruned[key] = prunedChild
+			}
+		}
+		return pruned, len(pruned) == 0
+	case []interface{}:
+		if len(val) == 0 {
+			return val, false
+		}
+		keyed := isSliceOfDictsWithIds(val)
+		pruned := make([]interface{}, 0, len(val))
+		for i, child := range val {
+			childPath := path + "[" + strconv.Itoa(i) + "]"
+			if id, ok := itemIdentifier(child); keyed && ok {
+				childPath = path + "[" + id + "]"
+			}
+			if isIgnored(patterns, childPath) {
+				continue
+			}
+			if prunedChild, emptied := pruneIgnored(child, childPath, patterns); !emptied {
+				pruned = append(pruned, prunedChild)
+			}
+		}
+		return pruned, len(pruned) == 0
+	default:
+		return v, false
+	}
+}
+
+// ignoredValues are the patterns left out of values before comparing them,
+// set with --ignore-value-regex
+var ignoredValues []*regexp.Regexp
+
+// compileValuePatterns compiles the patterns given with --ignore-value-regex
+func compileValuePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --ignore-value-regex %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
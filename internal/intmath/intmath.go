@@ -0,0 +1,11 @@
+// Package intmath holds integer helpers shared by the command and the diff
+// engine.
+package intmath
+
+// Abs returns the absolute value of an integer
+func Abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// jobs bounds the number of goroutines working concurrently
+var jobs = runtime.GOMAXPROCS(0)
+
+// forEachParallel calls fn for every index from 0 to n-1, running at most jobs calls at a time
+func forEachParallel(n int, fn func(i int)) {
+	workers := jobs
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > n {
+		workers = n
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
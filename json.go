@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// reportSchemaVersion is the version of the JSON report schema. Minor versions
+// only add optional fields; breaking changes bump the major version.
+const reportSchemaVersion = "1.6"
+
+// reportSchema is the JSON Schema describing the JSON and NDJSON reports
+//
+//go:embed schema/report-v1.json
+var reportSchema string
+
+// jsonChange is a change as it appears in the JSON report
+type jsonChange struct {
+	Document int         `json:"document"`
+	Path     string      `json:"path"`
+	Type     string      `json:"type"`
+	Old      interface{} `json:"old"`
+	New      interface{} `json:"new"`
+	OldYAML  *string     `json:"oldYAML,omitempty"`
+	NewYAML  *string     `json:"newYAML,omitempty"`
+	Count    int         `json:"count,omitempty"`
+	Decoded  bool        `json:"decoded,omitempty"`
+	// Positions in the old and new files, with --locations
+	OldLine   int `json:"oldLine,omitempty"`
+	OldColumn int `json:"oldColumn,omitempty"`
+	NewLine   int `json:"newLine,omitempty"`
+	NewColumn int `json:"newColumn,omitempty"`
+}
+
+// jsonMetadata holds the report metadata included with --report
+type jsonMetadata struct {
+	Version   string      `json:"version"`
+	Commit    string      `json:"commit"`
+	Generated string      `json:"generated"`
+	Inputs    []jsonInput `json:"inputs"`
+	Options   []string    `json:"options"`
+}
+
+// jsonSummary holds the change totals of the report
+type jsonSummary struct {
+	Additions        int `json:"additions"`
+	Deletions        int `json:"deletions"`
+	Modifications    int `json:"modifications"`
+	Moves            int `json:"moves,omitempty"`
+	Total            int `json:"total"`
+	ChangedDocuments int `json:"changedDocuments"`
+	TotalDocuments   int `json:"totalDocuments"`
+}
+
+// jsonReport is the document written by --output json
+type jsonReport struct {
+	SchemaVersion string        `json:"schemaVersion"`
+	Metadata      *jsonMetadata `json:"metadata,omitempty"`
+	Changes       []jsonChange  `json:"changes"`
+	Warnings      []warning     `json:"warnings,omitempty"`
+	Summary       jsonSummary   `json:"summary"`
+}
+
+// newJSONChanges converts the changes of a document to their JSON report form, sorted as in the text output
+func newJSONChanges(changes []Change, document int) []jsonChange {
+	sorted := append([]Change{}, changes...)
+	sortChanges(sorted)
+
+	result := make([]jsonChange, 0, len(sorted))
+	for _, change := range sorted {
+		entry := jsonChange{
+			Document:  document,
+			Path:      change.Path,
+			Type:      change.Type.String(),
+			Old:       toJSONValue(change.OldValue),
+			New:       toJSONValue(change.NewValue),
+			Count:     change.Count,
+			Decoded:   change.Decoded,
+			OldLine:   change.OldLine,
+			OldColumn: change.OldColumn,
+			NewLine:   change.NewLine,
+			NewColumn: change.NewColumn,
+		}
+		if yamlSnippets && change.Type != Move {
+			if change.Type != Addition {
+				entry.OldYAML = yamlSnippet(change.OldValue, collapseBlocks)
+			}
+			if change.Type != Deletion {
+				entry.NewYAML = yamlSnippet(change.NewValue, collapseBlocks && !expandNewBlocks)
+			}
+		}
+		result = append(result, entry)
+	}
+	return result
+}
+
+// yamlSnippet renders a value as it is shown in the text output, summarizing
+// blocks when collapse is set
+func yamlSnippet(v interface{}, collapse bool) *string {
+	format := func() string { return formatValue(v) }
+	if collapse {
+		snippet := summarizeBlock(v, format)
+		return &snippet
+	}
+	snippet := format()
+	return &snippet
+}
+
+// newJSONMetadata builds the report metadata for the given inputs
+func newJSONMetadata(labels, files, options []string, generated time.Time) *jsonMetadata {
+	metadata := &jsonMetadata{
+		Version:   version,
+		Commit:    commit,
+		Generated: generated.UTC().Format(time.RFC3339),
+		Inputs:    newJSONInputs(labels, files),
+		Options:   options,
+	}
+	if metadata.Options == nil {
+		metadata.Options = []string{}
+	}
+	return metadata
+}
+
+// newJSONInputs describes the input files with their modification times
+func newJSONInputs(labels, files []string) []jsonInput {
+	inputs := []jsonInput{}
+	for i, file := range files {
+		input := jsonInput{Label: labels[i], File: file}
+		if name := inputName(labels[i], file); name != file {
+			input.Name = name
+		}
+		if info, err := os.Stat(file); err == nil {
+			input.Modified = info.ModTime().UTC().Format(time.RFC3339)
+		}
+		inputs = append(inputs, input)
+	}
+	return inputs
+}
+
+// newJSONSummary builds the report summary from the change counts
+func newJSONSummary(counts changeCounts, changedDocuments, totalDocuments int) jsonSummary {
+	return jsonSummary{
+		Additions:        counts.Additions,
+		Deletions:        counts.Deletions,
+		Modifications:    counts.Modifications,
+		Moves:            counts.Moves,
+		Total:            counts.Total(),
+		ChangedDocuments: changedDocuments,
+		TotalDocuments:   totalDocuments,
+	}
+}
+
+// toJSONValue converts a YAML value into a value encoding/json can marshal
+func toJSONValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(val))
+		for key, child := range val {
+			result[fmt.Sprintf("%v", key)] = toJSONValue(child)
+		}
+		return result
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(val))
+		for key, child := range val {
+			result[key] = toJSONValue(child)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(val))
+		for i, child := range val {
+			result[i] = toJSONValue(child)
+		}
+		return result
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	default:
+		return v
+	}
+}
+
+// formatJSONReport renders the report as an indented JSON document
+func formatJSONReport(report jsonReport) (string, error) {
+	if report.Changes == nil {
+		report.Changes = []jsonChange{}
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(data) + "\n", nil
+}
+
+// formatYAMLReport renders the report as a YAML document with the fields of
+// the JSON report, in the same order
+func formatYAMLReport(report jsonReport) (string, error) {
+	if report.Changes == nil {
+		report.Changes = []jsonChange{}
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return "", err
+	}
+
+	// JSON is YAML written in flow style; parsing it into nodes keeps the
+	// field order, and clearing the styles writes it out in block style
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return "", err
+	}
+	clearNodeStyles(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// clearNodeStyles resets the style of a node and its children, letting the
+// encoder choose block style and quote only where needed
+func clearNodeStyles(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearNodeStyles(child)
+	}
+}
+
+// formatNDJSONReport renders the report as one JSON record per line: the
+// metadata (with --report), every change, and the summary
+func formatNDJSONReport(report jsonReport) (string, error) {
+	var records []interface{}
+	if report.Metadata != nil {
+		records = append(records, struct {
+			SchemaVersion string `json:"schemaVersion"`
+			Record        string `json:"record"`
+			*jsonMetadata
+		}{reportSchemaVersion, "metadata", report.Metadata})
+	}
+	for i := range report.Changes {
+		records = append(records, struct {
+			SchemaVersion string `json:"schemaVersion"`
+			Record        string `json:"record"`
+			*jsonChange
+		}{reportSchemaVersion, "change", &report.Changes[i]})
+	}
+	for i := range report.Warnings {
+		records = append(records, struct {
+			SchemaVersion string `json:"schemaVersion"`
+			Record        string `json:"record"`
+			*warning
+		}{reportSchemaVersion, "warning", &report.Warnings[i]})
+	}
+	records = append(records, struct {
+		SchemaVersion string `json:"schemaVersion"`
+		Record        string `json:"record"`
+		*jsonSummary
+	}{reportSchemaVersion, "summary", &report.Summary})
+
+	var result strings.Builder
+	for _, record := range records {
+		data, err := json.Marshal(record)
+		if err != nil {
+			return "", err
+		}
+		result.Write(data)
+		result.WriteString("\n")
+	}
+	return result.String(), nil
+}
@@ -0,0 +1,505 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	flag "github.com/spf13/pflag"
+	"ymldiff/pkg/ymldiff"
+)
+
+// TestJSONReport tests the structure of the JSON report
+func TestJSONReport(t *testing.T) {
+	changes := []Change{
+		{Type: Modification, Path: ".spec.replicas", OldValue: 2, NewValue: 3},
+		{Type: Addition, Path: ".metadata.labels", NewValue: map[interface{}]interface{}{"app": "web"}},
+	}
+
+	var counts changeCounts
+	counts.Add(engineChanges(changes))
+	report := jsonReport{
+		SchemaVersion: reportSchemaVersion,
+		Changes:       newJSONChanges(changes, 2),
+		Summary:       newJSONSummary(counts, 1, 3),
+	}
+
+	output, err := formatJSONReport(report)
+	if err != nil {
+		t.Fatalf("Failed to format report: %v", err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(output), &decoded); err != nil {
+		t.Fatalf("Report is not valid JSON: %v\n%s", err, output)
+	}
+	if decoded["schemaVersion"] != reportSchemaVersion {
+		t.Errorf("Expected schemaVersion %s, got %v", reportSchemaVersion, decoded["schemaVersion"])
+	}
+	if _, hasMetadata := decoded["metadata"]; hasMetadata {
+		t.Error("Expected no metadata without --report")
+	}
+
+	reportChanges := decoded["changes"].([]interface{})
+	if len(reportChanges) != 2 {
+		t.Fatalf("Expected 2 changes, got %d", len(reportChanges))
+	}
+	first := reportChanges[0].(map[string]interface{})
+	if first["path"] != ".metadata.labels" || first["type"] != "addition" || first["document"] != float64(2) {
+		t.Errorf("Unexpected first change: %v", first)
+	}
+	if first["old"] != nil {
+		t.Errorf("Expected null old value for an addition, got %v", first["old"])
+	}
+	if labels := first["new"].(map[string]interface{}); labels["app"] != "web" {
+		t.Errorf("Expected nested map value, got %v", first["new"])
+	}
+
+	summary := decoded["summary"].(map[string]interface{})
+	if summary["total"] != float64(2) || summary["totalDocuments"] != float64(3) {
+		t.Errorf("Unexpected summary: %v", summary)
+	}
+}
+
+// TestJSONReportWithoutChanges tests that an empty report still lists changes as an array
+func TestJSONReportWithoutChanges(t *testing.T) {
+	output, err := formatJSONReport(jsonReport{SchemaVersion: reportSchemaVersion})
+	if err != nil {
+		t.Fatalf("Failed to format report: %v", err)
+	}
+	if !strings.Contains(output, `"changes": []`) {
+		t.Errorf("Expected empty changes array, got:\n%s", output)
+	}
+}
+
+// TestNDJSONReport tests that every NDJSON line is a versioned record
+func TestNDJSONReport(t *testing.T) {
+	changes := []Change{
+		{Type: Deletion, Path: ".a", OldValue: "x"},
+		{Type: Deletion, Path: ".b", OldValue: "y"},
+	}
+	report := jsonReport{
+		SchemaVersion: reportSchemaVersion,
+		Metadata:      newJSONMetadata([]string{"Old", "New"}, []string{"a.yaml", "b.yaml"}, nil, fixedTime),
+		Changes:       newJSONChanges(changes, 1),
+	}
+
+	output, err := formatNDJSONReport(report)
+	if err != nil {
+		t.Fatalf("Failed to format report: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	expectedRecords := []string{"metadata", "change", "change", "summary"}
+	if len(lines) != len(expectedRecords) {
+		t.Fatalf("Expected %d lines, got %d:\n%s", len(expectedRecords), len(lines), output)
+	}
+	for i, line := range lines {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %v", i+1, err)
+		}
+		if record["schemaVersion"] != reportSchemaVersion || record["record"] != expectedRecords[i] {
+			t.Errorf("Line %d: unexpected record %v", i+1, record)
+		}
+	}
+}
+
+// TestYAMLReport tests that the YAML report has the fields of the JSON report, quoting where needed
+func TestYAMLReport(t *testing.T) {
+	changes := []Change{
+		{Type: Modification, Path: ".enabled", OldValue: "true", NewValue: "false"},
+		{Type: Addition, Path: ".ports", NewValue: []interface{}{80, 443}},
+	}
+	report := jsonReport{SchemaVersion: reportSchemaVersion, Changes: newJSONChanges(changes, 1)}
+
+	output, err := formatYAMLReport(report)
+	if err != nil {
+		t.Fatalf("Failed to format report: %v", err)
+	}
+
+	expected := `schemaVersion: "` + reportSchemaVersion + `"
+changes:
+  - document: 1
+    path: .enabled
+    type: modification
+    old: "true"
+    new: "false"
+  - document: 1
+    path: .ports
+    type: addition
+    old: null
+    new:
+      - 80
+      - 443
+summary:
+  additions: 0
+  deletions: 0
+  modifications: 0
+  total: 0
+  changedDocuments: 0
+  totalDocuments: 0
+`
+	if output != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, output)
+	}
+}
+
+// TestReportSchema tests that the embedded JSON Schema is valid JSON matching the report version
+func TestReportSchema(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal([]byte(reportSchema), &schema); err != nil {
+		t.Fatalf("Report schema is not valid JSON: %v", err)
+	}
+
+	major := strings.Split(reportSchemaVersion, ".")[0]
+	if !strings.Contains(reportSchema, "report-v"+major+".json") {
+		t.Errorf("Expected schema id to reference major version %s", major)
+	}
+}
+
+// TestJSONYAMLSnippets tests rendering old and new values as YAML with --yaml-snippets
+func TestJSONYAMLSnippets(t *testing.T) {
+	originalSnippets, originalCollapse := yamlSnippets, collapseBlocks
+	defer func() { yamlSnippets, collapseBlocks = originalSnippets, originalCollapse }()
+	yamlSnippets = true
+
+	changes := []Change{
+		{Type: Addition, Path: ".labels", NewValue: map[interface{}]interface{}{"app": "web", "tier": "front"}},
+		{Type: Modification, Path: ".replicas", OldValue: 2, NewValue: 3},
+	}
+	result := newJSONChanges(changes, 1)
+	if result[0].OldYAML != nil || result[0].NewYAML == nil || *result[0].NewYAML != "app: web\ntier: front" {
+		t.Errorf("Unexpected snippets for an addition: %v, %v", result[0].OldYAML, result[0].NewYAML)
+	}
+	if *result[1].OldYAML != "2" || *result[1].NewYAML != "3" {
+		t.Errorf("Unexpected snippets for a modification: %q, %q", *result[1].OldYAML, *result[1].NewYAML)
+	}
+
+	// Collapsed blocks are summarized as in the text output
+	collapseBlocks = true
+	if snippet := newJSONChanges(changes, 1)[0].NewYAML; *snippet != "<map, 2 keys>" {
+		t.Errorf("Expected a summarized snippet, got %q", *snippet)
+	}
+
+	// Snippets are left out by default
+	yamlSnippets = false
+	data, _ := json.Marshal(newJSONChanges(changes, 1))
+	if strings.Contains(string(data), "YAML") {
+		t.Errorf("Expected no snippets without --yaml-snippets, got %s", data)
+	}
+}
+
+// TestFormatTAP tests a TAP test point per document, skipping those not compared
+func TestFormatTAP(t *testing.T) {
+	result := comparison{Result: Result{
+		TotalDocuments: 3,
+		Documents: []DocumentResult{
+			{Index: 0},
+			{Index: 2, Changes: []ymldiff.Change{
+				{Type: Modification, Path: ".spec.replicas", OldValue: 2, NewValue: 3},
+				{Type: Addition, Path: ".spec.paused", NewValue: true},
+			}},
+		},
+	}}
+
+	expected := `TAP version 13
+1..3
+ok 1 - document 1
+ok 2 - document 2 # SKIP not compared
+not ok 3 - document 3: 2 changes
+  ---
+  changes:
+    - '+ .spec.paused: true'
+    - '~ .spec.replicas: 2 → 3'
+  ...
+`
+	if got := formatTAP(result); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+// TestFormatChangeTemplate tests printing changes with a --format template
+func TestFormatChangeTemplate(t *testing.T) {
+	changes := newJSONChanges([]Change{
+		{Type: Modification, Path: ".spec.replicas", OldValue: 2, NewValue: 3},
+		{Type: Deletion, Path: ".spec.paused", OldValue: true},
+		{Type: Addition, Path: ".spec.ports", NewValue: []interface{}{80}},
+	}, 1)
+
+	tests := []struct {
+		template string
+		expected string
+	}{
+		{`{{.Type}} {{.Path}} {{.New | default "-"}}`, "deletion .spec.paused -\naddition .spec.ports [80]\nmodification .spec.replicas 3\n"},
+		{"{{.Document}}:{{.Path}}={{toJson .New}}\n", "1:.spec.paused=\"\"\n1:.spec.ports=[80]\n1:.spec.replicas=3\n"},
+		{"{{.Type}} {{.Path}} {{.Old}} -> {{.New}}", "deletion .spec.paused true -> \naddition .spec.ports  -> [80]\nmodification .spec.replicas 2 -> 3\n"},
+	}
+	for _, tt := range tests {
+		tmpl, err := parseChangeTemplate(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := formatChangeTemplate(tmpl, changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if output != tt.expected {
+			t.Errorf("Template %q: expected %q, got %q", tt.template, tt.expected, output)
+		}
+	}
+
+	if _, err := parseChangeTemplate("{{.Path"); err == nil {
+		t.Error("Expected an error for an unterminated action")
+	}
+	tmpl, err := parseChangeTemplate("{{.Unknown}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := formatChangeTemplate(tmpl, changes); err == nil {
+		t.Error("Expected an error for an unknown field")
+	}
+}
+
+// TestCodeQualityIssues tests locating changes as GitLab code quality issues
+func TestCodeQualityIssues(t *testing.T) {
+	defer func(paths []string) { forbiddenPaths = paths }(forbiddenPaths)
+	forbiddenPaths = []string{".spec.image"}
+
+	documents, err := parseYAMLData([]byte("kind: Deployment\nspec:\n  replicas: 3\n  image: web:2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := []Change{
+		{Type: Modification, Path: ".spec.replicas", OldValue: 2, NewValue: 3},
+		{Type: Modification, Path: ".spec.image", OldValue: "web:1", NewValue: "web:2"},
+		{Type: Deletion, Path: ".spec.paused", OldValue: true},
+	}
+	issues := newCodeQualityIssues(changes, 1, "./deploy/app.yaml", documents[0].Node, documents[0].Data)
+
+	expected := []struct {
+		description string
+		severity    string
+		line        int
+	}{
+		{"Changed .spec.replicas: 2 → 3", "minor", 3},
+		{"Changed .spec.image: web:1 → web:2", "major", 4},
+		{"Removed .spec.paused (was true)", "minor", 2},
+	}
+	if len(issues) != len(expected) {
+		t.Fatalf("Expected %d issues, got %+v", len(expected), issues)
+	}
+	for i, issue := range issues {
+		if issue.Description != expected[i].description || issue.Severity != expected[i].severity || issue.Location.Lines.Begin != expected[i].line {
+			t.Errorf("Issue %d: expected %+v, got %+v", i, expected[i], issue)
+		}
+		if issue.Location.Path != "deploy/app.yaml" || issue.CheckName != "ymldiff" || len(issue.Fingerprint) != 64 {
+			t.Errorf("Issue %d: unexpected location or fingerprint %+v", i, issue)
+		}
+	}
+	if issues[0].Fingerprint == issues[1].Fingerprint {
+		t.Error("Expected distinct fingerprints for distinct changes")
+	}
+
+	output, err := formatCodeQuality(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var empty []codeQualityIssue
+	if err := json.Unmarshal([]byte(output), &empty); err != nil || empty == nil {
+		t.Errorf("Expected an empty JSON array without changes, got %q", output)
+	}
+}
+
+// TestReportHeader tests that the report header describes the tool, inputs and options
+func TestReportHeader(t *testing.T) {
+	file := createTempFile(t, "report.yaml", "name: John\n")
+	defer os.Remove(file)
+
+	header := formatReportHeader([]string{"Old", "New"}, []string{file, "missing.yaml"}, []string{"--no-color"}, fixedTime)
+
+	expectedLines := []string{
+		"# ymldiff report",
+		"# Version:   " + version,
+		"# Generated: 2024-05-01T12:00:00Z",
+		"# Old:       " + file + " (modified ",
+		"# New:       missing.yaml\n",
+		"# Options:   --no-color",
+	}
+	for _, line := range expectedLines {
+		if !strings.Contains(header, line) {
+			t.Errorf("Expected header to contain %q, got:\n%s", line, header)
+		}
+	}
+}
+
+// TestReportFooter tests the totals in the report footer
+func TestReportFooter(t *testing.T) {
+	var counts changeCounts
+	counts.Add([]ymldiff.Change{
+		{Type: Addition, Path: ".a"},
+		{Type: Addition, Path: ".b"},
+		{Type: Modification, Path: ".c"},
+	})
+
+	footer := formatReportFooter(counts, 1, 2)
+	expected := "# Total: 3 changes (2 additions, 0 deletions, 1 modification) in 1 of 2 documents\n"
+	if footer != expected {
+		t.Errorf("Expected %q, got %q", expected, footer)
+	}
+}
+
+// TestUsedOptions tests listing the explicitly set command line options
+func TestUsedOptions(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.BoolP("no-color", "n", false, "")
+	flags.Bool("raw", false, "")
+	flags.StringArray("preset", nil, "")
+	flags.Int("max-changes", -1, "")
+
+	if err := flags.Parse([]string{"-n", "--preset", "kubernetes", "--preset", "compose", "--max-changes", "5"}); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+
+	options := strings.Join(usedOptions(flags), " ")
+	expected := "--max-changes=5 --no-color --preset=kubernetes --preset=compose"
+	if options != expected {
+		t.Errorf("Expected %q, got %q", expected, options)
+	}
+}
+
+// fixedTime is a fixed timestamp for reproducible report tests
+var fixedTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+// TestDiffLines tests that the edit script turns the old lines into the new ones
+func TestDiffLines(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, random.Intn(12))
+		for i := range lines {
+			lines[i] = string(rune('a' + random.Intn(4)))
+		}
+		return lines
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomLines(), randomLines()
+		var old, new []string
+		for _, edit := range diffLines(a, b) {
+			if edit.Op != '+' {
+				old = append(old, edit.Line)
+			}
+			if edit.Op != '-' {
+				new = append(new, edit.Line)
+			}
+		}
+		if strings.Join(old, "") != strings.Join(a, "") || strings.Join(new, "") != strings.Join(b, "") {
+			t.Fatalf("Edit script of %v → %v reproduces %v → %v", a, b, old, new)
+		}
+	}
+}
+
+// TestDiffLinesMemory tests that diffing long files with many changes keeps
+// the trace of the search small
+func TestDiffLinesMemory(t *testing.T) {
+	a := make([]string, 20000)
+	for i := range a {
+		a[i] = "line " + strconv.Itoa(i)
+	}
+	b := append([]string{}, a...)
+	for i := 0; i < len(b); i += 40 {
+		b[i] = "changed " + strconv.Itoa(i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	edits := diffLines(a, b)
+	runtime.ReadMemStats(&after)
+
+	if changed := len(edits) - len(a); changed != len(b)/40 {
+		t.Errorf("Expected %d added lines, got %d", len(b)/40, changed)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("Expected diffing to allocate less than 64 MiB, got %d MiB", allocated>>20)
+	}
+}
+
+// TestFormatChangeLines tests the formats listing changes one per line
+func TestFormatChangeLines(t *testing.T) {
+	changes := append(newJSONChanges([]Change{
+		{Type: Modification, Path: ".spec.replicas", OldValue: 1, NewValue: 3},
+		{Type: Deletion, Path: ".debug", OldValue: true},
+		{Type: Addition, Path: ".labels", NewValue: map[interface{}]interface{}{"app": "web", "tier": "<front>"}},
+	}, 1), newJSONChanges([]Change{
+		{Type: Modification, Path: ".data.motd\tfile", OldValue: "hello\nworld", NewValue: "hi"},
+		{Type: Modification, Path: ".spec.replicas", OldValue: 1, NewValue: 2},
+		{Type: Deletion, Path: "", OldValue: map[interface{}]interface{}{"kind": "Service"}},
+	}, 2)...)
+
+	tests := []struct {
+		name     string
+		format   func([]jsonChange) (string, error)
+		expected string
+	}{
+		{"porcelain", formatPorcelain, "deletion\t1\t.debug\ttrue\t\n" +
+			"addition\t1\t.labels\t\t{\"app\":\"web\",\"tier\":\"<front>\"}\n" +
+			"modification\t1\t.spec.replicas\t1\t3\n" +
+			"deletion\t2\t\t{\"kind\":\"Service\"}\t\n" +
+			"modification\t2\t.data.motd\\tfile\t\"hello\\nworld\"\t\"hi\"\n" +
+			"modification\t2\t.spec.replicas\t1\t2\n"},
+		{"brief", func(changes []jsonChange) (string, error) { return formatBrief(changes), nil },
+			".debug\n.labels\n.spec.replicas\n.\n.data.motd\tfile\n"},
+	}
+	for _, tt := range tests {
+		got, err := tt.format(changes)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s =\n%q\nexpected\n%q", tt.name, got, tt.expected)
+		}
+	}
+}
+
+// TestFormatUnifiedDiff tests hunk headers and context of unified diffs
+func TestFormatUnifiedDiff(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	lines := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
+	tests := []struct {
+		name     string
+		old, new []string
+		expected string
+	}{
+		{"two hunks", lines, []string{"a", "B", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m"}, `--- old.yaml
++++ new.yaml
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -10,3 +10,4 @@
+ j
+ k
+ l
++m
+`},
+		{"empty old file", nil, []string{"a"}, "--- old.yaml\n+++ new.yaml\n@@ -0,0 +1 @@\n+a\n"},
+		{"equal lines", lines, lines, ""},
+	}
+	for _, tt := range tests {
+		if got := formatUnifiedDiff("old.yaml", "new.yaml", tt.old, tt.new); got != tt.expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", tt.name, tt.expected, got)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// isKubernetesKind checks if a document is a Kubernetes object of the given kind
+func isKubernetesKind(doc interface{}, kind string) bool {
+	m, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		return false
+	}
+	if _, hasAPIVersion := m["apiVersion"]; !hasAPIVersion {
+		return false
+	}
+	return m["kind"] == kind
+}
+
+// reconcileSecrets aligns stringData and data entries of two Secret documents.
+// The API server merges stringData into data (base64-encoded), so a key kept in
+// stringData on one side and in data on the other is moved into data on the
+// stringData side, letting equal values compare equal.
+func reconcileSecrets(oldDoc, newDoc interface{}) (interface{}, interface{}) {
+	if !isKubernetesKind(oldDoc, "Secret") || !isKubernetesKind(newDoc, "Secret") {
+		return oldDoc, newDoc
+	}
+
+	oldMap := oldDoc.(map[interface{}]interface{})
+	newMap := newDoc.(map[interface{}]interface{})
+
+	return foldStringData(oldMap, newMap), foldStringData(newMap, oldMap)
+}
+
+// foldStringData returns a copy of secret where every stringData key that the
+// other secret only carries in data is base64-encoded into data
+func foldStringData(secret, other map[interface{}]interface{}) interface{} {
+	stringData, ok := secret["stringData"].(map[interface{}]interface{})
+	if !ok {
+		return secret
+	}
+	otherData, _ := other["data"].(map[interface{}]interface{})
+	otherStringData, _ := other["stringData"].(map[interface{}]interface{})
+
+	var moved []interface{}
+	for key := range stringData {
+		if _, inOtherStringData := otherStringData[key]; inOtherStringData {
+			continue
+		}
+		if _, inOtherData := otherData[key]; inOtherData {
+			moved = append(moved, key)
+		}
+	}
+	if len(moved) == 0 {
+		return secret
+	}
+	for _, key := range moved {
+		debugf(".stringData.%v: compared base64-encoded against .data.%v", key, key)
+	}
+
+	// Copy the maps we are about to change so the parsed documents stay intact
+	result := make(map[interface{}]interface{}, len(secret))
+	for k, v := range secret {
+		result[k] = v
+	}
+	data := make(map[interface{}]interface{})
+	if existing, ok := secret["data"].(map[interface{}]interface{}); ok {
+		for k, v := range existing {
+			data[k] = v
+		}
+	}
+	remaining := make(map[interface{}]interface{})
+	for k, v := range stringData {
+		remaining[k] = v
+	}
+
+	for _, key := range moved {
+		// stringData takes precedence over data, just like on the API server
+		data[key] = base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("%v", stringData[key])))
+		delete(remaining, key)
+	}
+
+	result["data"] = data
+	if len(remaining) > 0 {
+		result["stringData"] = remaining
+	} else {
+		delete(result, "stringData")
+	}
+	return result
+}
+
+// k8sNoiseIgnores lists fields maintained by the API server or kubectl that
+// make live objects differ from the manifests they were created from
+var k8sNoiseIgnores = []string{
+	".metadata.managedFields",
+	".metadata.resourceVersion",
+	".metadata.uid",
+	".metadata.creationTimestamp",
+	".metadata.generation",
+	".metadata.selfLink",
+	".metadata.annotations.kubectl.kubernetes.io/*",
+	".status",
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inputNames holds the names given to the old and new inputs with --label,
+// keyed by "old" and "new"
+var inputNames = map[string]string{}
+
+// parseInputLabel parses a label written as old=NAME or new=NAME
+func parseInputLabel(spec string) (string, string, error) {
+	side, name, found := strings.Cut(spec, "=")
+	side = strings.ToLower(strings.TrimSpace(side))
+	if !found || (side != "old" && side != "new") || name == "" {
+		return "", "", fmt.Errorf("invalid --label %q, expected old=NAME or new=NAME", spec)
+	}
+	return side, name, nil
+}
+
+// inputName returns the name of an input: its label, if one was given for the
+// side (e.g. "Old"), or else its file name
+func inputName(side, file string) string {
+	if name, ok := inputNames[strings.ToLower(side)]; ok {
+		return name
+	}
+	return file
+}
+
+// hasInputNames checks if any input was given a label
+func hasInputNames() bool {
+	return len(inputNames) > 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// changeLimit is the number of changes shown in the text output, set with
+// --limit; -1 shows all of them
+var changeLimit = -1
+
+// limitChanges sorts the changes of a document as they are listed and keeps those that
+// fit within --limit after the given number of changes already shown. It
+// returns how many were left out.
+func limitChanges(changes []Change, shown int) ([]Change, int) {
+	if changeLimit < 0 {
+		return changes, 0
+	}
+	remaining := max(changeLimit-shown, 0)
+	if len(changes) <= remaining {
+		return changes, 0
+	}
+	sorted := append([]Change{}, changes...)
+	sortChanges(sorted)
+	return sorted[:remaining], len(sorted) - remaining
+}
+
+// formatOmitted formats the note on the changes left out by --limit
+func formatOmitted(omitted int) string {
+	return fmt.Sprintf("… and %s more %s", groupThousands(omitted), pluralize(omitted, "change", "changes"))
+}
+
+// groupThousands formats a number with commas between groups of three digits
+func groupThousands(n int) string {
+	if n < 0 {
+		return "-" + groupThousands(-n)
+	}
+	digits := strconv.Itoa(n)
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return digits
+}
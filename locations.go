@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// locationsMode shows where each change is in the compared files, set with --locations
+var locationsMode bool
+
+// locationFiles names the old and new files in the locations of changes
+var locationFiles [2]string
+
+// attachLocations records the line and column of each change in the old and
+// new sources. Added entries only have a position in the new file and removed
+// ones only in the old.
+func attachLocations(changes []Change, oldRoot, newRoot *yaml.Node, oldData, newData interface{}) {
+	for i := range changes {
+		if changes[i].Type != Addition && oldRoot != nil {
+			changes[i].OldLine, changes[i].OldColumn = entryPosition(oldRoot, oldData, changes[i].Path)
+		}
+		if changes[i].Type != Deletion && newRoot != nil {
+			changes[i].NewLine, changes[i].NewColumn = entryPosition(newRoot, newData, changes[i].Path)
+		}
+	}
+}
+
+// formatLocation formats the positions of a change as file:line:column, old
+// before new, or nothing if it has none
+func formatLocation(change Change) string {
+	var locations []string
+	if change.OldLine > 0 {
+		locations = append(locations, fmt.Sprintf("%s:%d:%d", locationFiles[0], change.OldLine, change.OldColumn))
+	}
+	if change.NewLine > 0 {
+		locations = append(locations, fmt.Sprintf("%s:%d:%d", locationFiles[1], change.NewLine, change.NewColumn))
+	}
+	return strings.Join(locations, ", ")
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// maxChangelogValueWidth limits the width of values shown in the changelog
+const maxChangelogValueWidth = 60
+
+// gitRevision is a commit that touched a file
+type gitRevision struct {
+	Hash      string
+	ShortHash string
+	Author    string
+	Date      string
+	Path      string // path of the file in this commit, relative to the repository root
+}
+
+// changelogEntry is a change made to a path by a commit
+type changelogEntry struct {
+	Revision gitRevision
+	Change   Change
+}
+
+// runGit runs a git command in dir and returns its standard output
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], message)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return output, nil
+}
+
+// fileRevisions lists the commits touching a file after the since revision
+// (or all of them when since is empty), oldest first. Renames are followed.
+func fileRevisions(file, since string) ([]gitRevision, error) {
+	args := []string{"log", "--follow", "--date=short", "--name-only", "--format=%x1e%H%x1f%h%x1f%an%x1f%ad"}
+	if since != "" {
+		args = append(args, since+"..HEAD")
+	}
+	args = append(args, "--", filepath.Base(file))
+
+	output, err := runGit(filepath.Dir(file), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []gitRevision
+	for _, record := range strings.Split(string(output), "\x1e") {
+		lines := strings.Split(strings.TrimSpace(record), "\n")
+		fields := strings.Split(lines[0], "\x1f")
+		if len(fields) != 4 || len(lines) < 2 {
+			continue
+		}
+		revisions = append(revisions, gitRevision{
+			Hash:      fields[0],
+			ShortHash: fields[1],
+			Author:    fields[2],
+			Date:      fields[3],
+			Path:      strings.TrimSpace(lines[len(lines)-1]),
+		})
+	}
+
+	// git log lists the newest commits first
+	for i, j := 0, len(revisions)-1; i < j; i, j = i+1, j-1 {
+		revisions[i], revisions[j] = revisions[j], revisions[i]
+	}
+	return revisions, nil
+}
+
+// fileAtRevision parses a file as it was in a revision. A file missing from
+// the revision has no documents.
+func fileAtRevision(file, revision, path string) ([]YAMLDocument, error) {
+	content, err := runGit(filepath.Dir(file), "show", revision+":"+path)
+	if err != nil {
+		return nil, nil
+	}
+	data, err := prepareInput(file, content)
+	if err != nil {
+		return nil, err
+	}
+	return parseData(data, detectFormat(file))
+}
+
+// buildChangelog diffs each revision of a file against the previous one and
+// collects the changes by path
+func buildChangelog(file, since string) (map[string][]changelogEntry, error) {
+	revisions, err := fileRevisions(file, since)
+	if err != nil {
+		return nil, err
+	}
+
+	changelog := make(map[string][]changelogEntry)
+	if len(revisions) == 0 {
+		return changelog, nil
+	}
+
+	var previous []YAMLDocument
+	if since != "" {
+		if previous, err = fileAtRevision(file, since, revisions[0].Path); err != nil {
+			return nil, fmt.Errorf("%s: %w", since, err)
+		}
+	}
+
+	for _, revision := range revisions {
+		current, err := fileAtRevision(file, revision.Hash, revision.Path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", revision.ShortHash, err)
+		}
+		if !rawMode {
+			for i := range current {
+				current[i].Data = applyNormalizations(current[i].Data)
+			}
+		}
+
+		multiple := len(previous) > 1 || len(current) > 1
+		for _, pair := range pairDocuments(previous, current) {
+			var oldData, newData interface{}
+			prefix := ""
+			if pair.Old >= 0 {
+				oldData = previous[pair.Old].Data
+			}
+			if pair.New >= 0 {
+				newData = current[pair.New].Data
+				if multiple {
+					prefix = fmt.Sprintf("#%d ", pair.New+1)
+				}
+			} else if multiple {
+				prefix = fmt.Sprintf("#%d ", pair.Old+1)
+			}
+			if !rawMode {
+				oldData, newData = reconcileSecrets(oldData, newData)
+			}
+
+			changes := filterIgnored(diffDocuments(oldData, newData), ignorePatterns)
+			if masking() {
+				changes = maskChanges(changes)
+			}
+			for _, change := range changes {
+				key := prefix + change.Path
+				changelog[key] = append(changelog[key], changelogEntry{Revision: revision, Change: change})
+			}
+		}
+		previous = current
+	}
+	return changelog, nil
+}
+
+// generateChangelog renders the changelog with one section per path, listing
+// the commits that changed it in chronological order
+func generateChangelog(changelog map[string][]changelogEntry) string {
+	if len(changelog) == 0 {
+		return "No changes found.\n"
+	}
+
+	paths := make([]string, 0, len(changelog))
+	for path := range changelog {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	bold := color.New(color.Bold)
+	blue := color.New(color.FgBlue)
+	green := color.New(color.FgGreen)
+	red := color.New(color.FgRed)
+	yellow := color.New(color.FgYellow)
+
+	var result strings.Builder
+	for _, path := range paths {
+		label := path
+		if label == "" {
+			label = "(document)"
+		}
+		result.WriteString(bold.Sprint(label) + "\n")
+
+		for _, entry := range changelog[path] {
+			revision := entry.Revision
+			result.WriteString("  " + blue.Sprintf("%s %s %s:", revision.ShortHash, revision.Date, revision.Author) + " ")
+
+			change := entry.Change
+			switch change.Type {
+			case Addition:
+				result.WriteString(green.Sprint(markerFor(Addition) + formatSingleLine(change.NewValue, maxChangelogValueWidth)))
+			case Deletion:
+				result.WriteString(red.Sprint(markerFor(Deletion) + formatSingleLine(change.OldValue, maxChangelogValueWidth)))
+			case Move:
+				result.WriteString(fmt.Sprintf("%sposition %v%s%v", markerFor(Move), change.OldValue, arrow(), change.NewValue))
+			case Modification:
+				result.WriteString(yellow.Sprint(markerFor(Modification) + formatSingleLine(change.OldValue, maxChangelogValueWidth) +
+					arrow() + formatSingleLine(change.NewValue, maxChangelogValueWidth)))
+			}
+			result.WriteString("\n")
+		}
+	}
+	return result.String()
+}
+
+// runLog prints the changelog of a file across its git history
+func runLog(file, since string) error {
+	defer resetFormattedValues()
+	changelog, err := buildChangelog(file, since)
+	if err != nil {
+		return err
+	}
+	fmt.Print(generateChangelog(changelog))
+	return nil
+}
@@ -1,192 +1,208 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"reflect"
-	"sort"
-	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/fatih/color"
 	flag "github.com/spf13/pflag"
 	"gopkg.in/yaml.v3"
+	"ymldiff/pkg/ymldiff"
 )
 
-// ChangeType represents the type of change
-type ChangeType int
+// Types of the diff engine, shared with the ymldiff package
+type (
+	ChangeType     = ymldiff.ChangeType
+	YAMLDocument   = ymldiff.Document
+	Result         = ymldiff.Result
+	DocumentResult = ymldiff.DocumentResult
+	documentPair   = ymldiff.DocumentPair
+	changeCounts   = ymldiff.Counts
+	warning        = ymldiff.Warning
+	jsonInput      = ymldiff.Input
+)
 
+// Change types
 const (
-	Addition ChangeType = iota
-	Deletion
-	Modification
+	Addition     = ymldiff.Addition
+	Deletion     = ymldiff.Deletion
+	Modification = ymldiff.Modification
+	Move         = ymldiff.Move
 )
 
-// Change represents a single change in the diff
-type Change struct {
-	Type     ChangeType
-	Path     string
-	OldValue interface{}
-	NewValue interface{}
+// engineOptions returns the options of the diff engine set on the command
+// line and in the config file
+func engineOptions() ymldiff.Options {
+	return ymldiff.Options{
+		Path:                subtreePath,
+		IDKeys:              idKeys,
+		Raw:                 rawMode,
+		ItemSimilarity:      itemSimilarity,
+		Multiset:            multisetMode,
+		DetectReorders:      detectReorders,
+		Strict:              strictMode,
+		MaxDepth:            maxDepth,
+		ParseEmbedded:       parseEmbedded,
+		DateRules:           dateRules,
+		Equivalences:        equivalenceRules,
+		Epsilon:             epsilon,
+		Tolerances:          toleranceRules,
+		NormalizeQuantities: normalizeQuantities,
+		NormalizeDurations:  normalizeDurations,
+		YAML11Booleans:      booleanMode == "yaml1.1",
+		LooseNumbers:        looseNumbers,
+		NullIsAbsent:        nullIsAbsent,
+		EmptyIsAbsent:       emptyIsAbsent,
+		TrimStrings:         trimStrings,
+		CollapseWhitespace:  collapseWhitespace,
+		IgnoreValues:        ignoredValues,
+		Warn: func(path, message string) {
+			warnAt(path, "%s", message)
+		},
+		Debug: func(message string) {
+			debugf("%s", message)
+		},
+	}
 }
 
 // isSliceOfDictsWithIds checks if a slice contains dictionaries with identifier fields
 func isSliceOfDictsWithIds(slice []interface{}) bool {
-	if len(slice) == 0 {
-		return false
-	}
-
-	for _, item := range slice {
-		if reflect.TypeOf(item).Kind() != reflect.Map {
-			return false
-		}
-		m := item.(map[interface{}]interface{})
-		// Check for common identifier fields
-		if _, hasName := m["name"]; hasName {
-			return true
-		}
-		if _, hasKey := m["key"]; hasKey {
-			return true
-		}
-		if _, hasId := m["id"]; hasId {
-			return true
-		}
-	}
-	return false
+	return ymldiff.IsKeyedList(slice, idKeys)
 }
 
-// diffSliceOfDicts compares slices of dictionaries by matching on identifier fields
-func diffSliceOfDicts(oldSlice, newSlice []interface{}, path string) []Change {
-	var changes []Change
-
-	// Group by identifier
-	oldMap := make(map[string]interface{})
-	newMap := make(map[string]interface{})
-
-	for _, item := range oldSlice {
-		if m, ok := item.(map[interface{}]interface{}); ok {
-			if name, hasName := m["name"]; hasName {
-				oldMap[fmt.Sprintf("%v", name)] = item
-			} else if key, hasKey := m["key"]; hasKey {
-				oldMap[fmt.Sprintf("%v", key)] = item
-			} else if id, hasId := m["id"]; hasId {
-				oldMap[fmt.Sprintf("%v", id)] = item
-			}
-		}
-	}
+// itemIdentifier returns the identifier field value of a list item, if it has one
+func itemIdentifier(item interface{}) (string, bool) {
+	_, id, ok := ymldiff.ItemIdentifier(item, idKeys)
+	return id, ok
+}
 
-	for _, item := range newSlice {
-		if m, ok := item.(map[interface{}]interface{}); ok {
-			if name, hasName := m["name"]; hasName {
-				newMap[fmt.Sprintf("%v", name)] = item
-			} else if key, hasKey := m["key"]; hasKey {
-				newMap[fmt.Sprintf("%v", key)] = item
-			} else if id, hasId := m["id"]; hasId {
-				newMap[fmt.Sprintf("%v", id)] = item
-			}
-		}
+// generateColoredDiff generates a colored diff showing only changed items
+func generateColoredDiff(changes []Change) string {
+	if len(changes) == 0 {
+		return "No changes found.\n"
 	}
 
-	// Find matches and differences
-	for key, oldItem := range oldMap {
-		if newItem, exists := newMap[key]; exists {
-			// Both exist, diff them
-			subChanges := diffValues(oldItem, newItem, path+"["+key+"]")
-			changes = append(changes, subChanges...)
-		} else {
-			// Only in old, it's a deletion
-			changes = append(changes, Change{
-				Type:     Deletion,
-				Path:     path + "[" + key + "]",
-				OldValue: oldItem,
-				NewValue: nil,
-			})
-		}
-	}
+	sortChanges(changes)
 
-	for key, newItem := range newMap {
-		if _, exists := oldMap[key]; !exists {
-			// Only in new, it's an addition
-			changes = append(changes, Change{
-				Type:     Addition,
-				Path:     path + "[" + key + "]",
-				OldValue: nil,
-				NewValue: newItem,
-			})
+	var result strings.Builder
+	if groupByParent {
+		result.WriteString(formatGroupedChanges(changes, nil, nil))
+	} else {
+		for _, change := range changes {
+			result.WriteString(formatChange(change, debugPath(change.Path), ""))
 		}
 	}
 
-	return changes
+	return result.String()
 }
 
-// generateColoredDiff generates a colored diff showing only changed items
-func generateColoredDiff(changes []Change) string {
-	if len(changes) == 0 {
-		return "No changes found.\n"
-	}
+// formatChange formats a single change labelled with the given path, indenting all of its lines
+func formatChange(change Change, label, indent string) string {
+	var result strings.Builder
+	red := themeColor(theme.Deletion)
+	green := themeColor(theme.Addition)
+	yellow := themeColor(theme.Modification)
 
-	// Sort changes alphabetically by path for consistency
-	sort.Slice(changes, func(i, j int) bool {
-		return changes[i].Path < changes[j].Path
-	})
+	if change.Decoded {
+		label += " " + themeColor(theme.Comment).Sprint("(base64-decoded)")
+	}
+	if location := formatLocation(change); locationsMode && location != "" {
+		label += " " + themeColor(theme.Comment).Sprintf("(%s)", location)
+	}
 
-	var result strings.Builder
-	red := color.New(color.FgRed)
-	green := color.New(color.FgGreen)
-	yellow := color.New(color.FgYellow)
-
-	for _, change := range changes {
-		switch change.Type {
-		case Addition:
-			coloredPrefix := green.Sprint("+ ")
-			result.WriteString(coloredPrefix)
-			result.WriteString(change.Path)
-			result.WriteString(": ")
-			formattedValue := formatValue(change.NewValue)
-			if strings.Contains(formattedValue, "\n") {
-				// Complex value - add newline and prefix subsequent lines
-				result.WriteString("\n")
-				result.WriteString(prefixLinesComplex(formattedValue, coloredPrefix))
-			} else {
-				// Simple value - show on same line
-				result.WriteString(formattedValue)
-				result.WriteString("\n")
-			}
-		case Deletion:
-			coloredPrefix := red.Sprint("- ")
-			result.WriteString(coloredPrefix)
-			result.WriteString(change.Path)
-			result.WriteString(": ")
-			formattedValue := formatValue(change.OldValue)
-			if strings.Contains(formattedValue, "\n") {
-				// Complex value - add newline and prefix subsequent lines
-				result.WriteString("\n")
-				result.WriteString(prefixLinesComplex(formattedValue, coloredPrefix))
-			} else {
-				// Simple value - show on same line
-				result.WriteString(formattedValue)
-				result.WriteString("\n")
-			}
-		case Modification:
-			result.WriteString(yellow.Sprint("~ "))
-			result.WriteString(change.Path)
-			result.WriteString(": ")
-			oldStr := formatValue(change.OldValue)
-			newStr := formatValue(change.NewValue)
-
-			// For string values, show character-level differences
-			if isStringValue(change.OldValue) && isStringValue(change.NewValue) {
-				oldStrColored, newStrColored := colorStringDiff(change.OldValue.(string), change.NewValue.(string))
-				result.WriteString(fmt.Sprintf("%s → %s\n", oldStrColored, newStrColored))
-			} else {
-				result.WriteString(fmt.Sprintf("%s → %s\n", oldStr, newStr))
+	switch change.Type {
+	case Addition:
+		coloredPrefix := indent + green.Sprint(markerFor(Addition))
+		result.WriteString(coloredPrefix)
+		result.WriteString(label)
+		result.WriteString(": ")
+		format := func() string { return formatSourceValue(change.NewValue, change.NewNode) }
+		formattedValue := ""
+		if collapseBlocks && !expandNewBlocks {
+			formattedValue = summarizeBlock(change.NewValue, format)
+		} else {
+			formattedValue = format()
+		}
+		if strings.Contains(formattedValue, "\n") {
+			// Complex value - add newline and prefix subsequent lines
+			result.WriteString("\n")
+			result.WriteString(prefixLinesComplex(colorizeBlock(formattedValue), coloredPrefix))
+		} else {
+			// Simple value - show on same line
+			result.WriteString(formatCount(change, formattedValue))
+			result.WriteString("\n")
+		}
+	case Deletion:
+		coloredPrefix := indent + red.Sprint(markerFor(Deletion))
+		result.WriteString(coloredPrefix)
+		result.WriteString(label)
+		result.WriteString(": ")
+		format := func() string { return formatSourceValue(change.OldValue, change.OldNode) }
+		formattedValue := ""
+		if collapseBlocks {
+			formattedValue = summarizeBlock(change.OldValue, format)
+		} else {
+			formattedValue = format()
+		}
+		if strings.Contains(formattedValue, "\n") {
+			// Complex value - add newline and prefix subsequent lines
+			result.WriteString("\n")
+			result.WriteString(prefixLinesComplex(colorizeBlock(formattedValue), coloredPrefix))
+		} else {
+			// Simple value - show on same line
+			result.WriteString(formatCount(change, formattedValue))
+			result.WriteString("\n")
+		}
+	case Move:
+		result.WriteString(indent)
+		result.WriteString(themeColor(theme.Move).Sprint(markerFor(Move)))
+		result.WriteString(label)
+		result.WriteString(fmt.Sprintf(": position %v%s%v\n", change.OldValue, arrow(), change.NewValue))
+	case Modification:
+		result.WriteString(indent)
+		result.WriteString(yellow.Sprint(markerFor(Modification)))
+		result.WriteString(label)
+		result.WriteString(": ")
+
+		// Multi-line strings are shown as a diff of their lines, other
+		// strings with the words that changed highlighted
+		if oldStr, newStr, ok := multilineStrings(change); ok {
+			result.WriteString("\n")
+			result.WriteString(formatStringLinesDiff(oldStr, newStr, indent))
+		} else if isStringValue(change.OldValue) && isStringValue(change.NewValue) {
+			oldStrColored, newStrColored := colorStringDiff(change.OldValue.(string), change.NewValue.(string))
+			result.WriteString(oldStrColored + arrow() + newStrColored)
+			if bump := formatSemverBump(change.OldValue, change.NewValue); bump != "" {
+				result.WriteString(" " + themeColor(theme.Comment).Sprintf("(%s)", bump))
 			}
+			result.WriteString("\n")
+		} else if oldValue, newValue := formatValue(change.OldValue), formatValue(change.NewValue); strings.Contains(oldValue, "\n") || strings.Contains(newValue, "\n") {
+			// Maps and lists are shown as blocks, the old one above the new one
+			result.WriteString("\n")
+			result.WriteString(prefixLinesComplex(colorizeBlock(oldValue), indent+red.Sprint(markerFor(Deletion))))
+			result.WriteString(prefixLinesComplex(colorizeBlock(newValue), indent+green.Sprint(markerFor(Addition))))
+		} else if percent := formatPercentChange(change.OldValue, change.NewValue); percent != "" {
+			// Numbers are followed by how much they changed relative to the old one
+			result.WriteString(oldValue + arrow() + newValue + " " + themeColor(theme.Comment).Sprintf("(%s)", percent) + "\n")
+		} else {
+			result.WriteString(oldValue + arrow() + newValue + "\n")
 		}
 	}
 
+	if change.Blame != "" {
+		result.WriteString(indent + "  " + themeColor(theme.Comment).Sprintf("# %s", change.Blame) + "\n")
+	}
+	if showSource {
+		result.WriteString(formatSourceSnippet(change, indent+"  "))
+	}
+
 	return result.String()
 }
 
@@ -213,18 +229,62 @@ func prefixLinesComplex(s, prefix string) string {
 	return result.String()
 }
 
+// summarizeBlock replaces the formatted form of a non-empty map or list with a
+// one-line summary, only formatting values that are not summarized
+func summarizeBlock(v interface{}, format func() string) string {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		if len(val) > 0 {
+			return fmt.Sprintf("<map, %d %s>", len(val), pluralize(len(val), "key", "keys"))
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			return fmt.Sprintf("<list, %d %s>", len(val), pluralize(len(val), "item", "items"))
+		}
+	}
+	return format()
+}
+
+// pluralize picks the singular or plural form of a word for a count
+func pluralize(count int, singular, plural string) string {
+	if count == 1 {
+		return singular
+	}
+	return plural
+}
+
 // isStringValue checks if a value is a string
 func isStringValue(v interface{}) bool {
 	_, ok := v.(string)
 	return ok
 }
 
-// colorStringDiff colors entire strings for better readability
-func colorStringDiff(oldStr, newStr string) (string, string) {
-	red := color.New(color.FgRed)
-	green := color.New(color.FgGreen)
+// valueIdentity identifies a map or list by its address
+type valueIdentity struct {
+	kind    reflect.Kind
+	pointer uintptr
+	length  int
+}
+
+// formattedValues memoizes formatValue for the maps and lists of the document
+// being shown, which are often shown more than once (e.g. the base value of
+// every matrix row). It is cleared for each document with resetFormattedValues,
+// and by each command once done.
+var formattedValues = make(map[valueIdentity]formattedValue)
+var formattedValuesMu sync.Mutex
+
+// formattedValue is a memoized form of a value. The value is kept along with
+// it, so that its address isn't reused by another value while memoized.
+type formattedValue struct {
+	value interface{}
+	text  string
+}
 
-	return red.Sprint(oldStr), green.Sprint(newStr)
+// resetFormattedValues forgets the memoized forms of the values shown so far
+func resetFormattedValues() {
+	formattedValuesMu.Lock()
+	defer formattedValuesMu.Unlock()
+	formattedValues = make(map[valueIdentity]formattedValue)
 }
 
 // formatValue formats a value for display, using YAML formatting for complex values
@@ -236,213 +296,101 @@ func formatValue(v interface{}) string {
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Map, reflect.Slice:
-		// Format complex values as YAML with 3-space indentation
-		var buf bytes.Buffer
-		encoder := yaml.NewEncoder(&buf)
-		encoder.SetIndent(3) // 3-space indentation
-		if err := encoder.Encode(v); err != nil {
-			return fmt.Sprintf("%v", v) // fallback to default formatting
+		identity := valueIdentity{val.Kind(), val.Pointer(), val.Len()}
+		formattedValuesMu.Lock()
+		cached, ok := formattedValues[identity]
+		formattedValuesMu.Unlock()
+		if ok {
+			return cached.text
 		}
-		encoder.Close()
-
-		// Return the YAML string as-is
-		return strings.TrimSuffix(buf.String(), "\n")
+		formatted := encodeValue(v)
+		formattedValuesMu.Lock()
+		formattedValues[identity] = formattedValue{value: v, text: formatted}
+		formattedValuesMu.Unlock()
+		return formatted
 	default:
 		return fmt.Sprintf("%v", v)
 	}
 }
 
-// diffValues compares two normalized values and returns a list of changes
-func diffValues(oldVal, newVal interface{}, path string) []Change {
-	var changes []Change
-
-	if reflect.DeepEqual(oldVal, newVal) {
-		return changes
-	}
-
-	oldType := reflect.TypeOf(oldVal)
-	newType := reflect.TypeOf(newVal)
-
-	// If types are different, it's a modification
-	if oldType != newType && oldVal != nil && newVal != nil {
-		changes = append(changes, Change{
-			Type:     Modification,
-			Path:     path,
-			OldValue: oldVal,
-			NewValue: newVal,
-		})
-		return changes
-	}
-
-	// Handle nil values
-	if oldVal == nil && newVal != nil {
-		changes = append(changes, Change{
-			Type:     Addition,
-			Path:     path,
-			OldValue: nil,
-			NewValue: newVal,
-		})
-		return changes
-	}
-	if oldVal != nil && newVal == nil {
-		changes = append(changes, Change{
-			Type:     Deletion,
-			Path:     path,
-			OldValue: oldVal,
-			NewValue: nil,
-		})
-		return changes
-	}
-
-	switch oldType.Kind() {
-	case reflect.Map:
-		oldMap := oldVal.(map[interface{}]interface{})
-		newMap := newVal.(map[interface{}]interface{})
-
-		// Check for deletions and modifications
-		for key, oldValue := range oldMap {
-			keyStr := fmt.Sprintf("%v", key)
-			newValue, exists := newMap[key]
-			if !exists {
-				changes = append(changes, Change{
-					Type:     Deletion,
-					Path:     path + "." + keyStr,
-					OldValue: oldValue,
-					NewValue: nil,
-				})
-			} else {
-				subChanges := diffValues(oldValue, newValue, path+"."+keyStr)
-				changes = append(changes, subChanges...)
-			}
-		}
-
-		// Check for additions
-		for key, newValue := range newMap {
-			keyStr := fmt.Sprintf("%v", key)
-			if _, exists := oldMap[key]; !exists {
-				changes = append(changes, Change{
-					Type:     Addition,
-					Path:     path + "." + keyStr,
-					OldValue: nil,
-					NewValue: newValue,
-				})
-			}
-		}
-
-	case reflect.Slice:
-		oldSlice := oldVal.([]interface{})
-		newSlice := newVal.([]interface{})
-
-		// Check if this is a slice of dictionaries with identifier fields
-		if isSliceOfDictsWithIds(oldSlice) && isSliceOfDictsWithIds(newSlice) {
-			changes = append(changes, diffSliceOfDicts(oldSlice, newSlice, path)...)
-		} else {
-			// For slices, we compare element by element since they're sorted
-			minLen := len(oldSlice)
-			if len(newSlice) < minLen {
-				minLen = len(newSlice)
-			}
-
-			for i := 0; i < minLen; i++ {
-				subChanges := diffValues(oldSlice[i], newSlice[i], path+"["+strconv.Itoa(i)+"]")
-				changes = append(changes, subChanges...)
-			}
-
-			// Handle extra elements
-			if len(oldSlice) > len(newSlice) {
-				for i := len(newSlice); i < len(oldSlice); i++ {
-					changes = append(changes, Change{
-						Type:     Deletion,
-						Path:     path + "[" + strconv.Itoa(i) + "]",
-						OldValue: oldSlice[i],
-						NewValue: nil,
-					})
-				}
-			} else if len(newSlice) > len(oldSlice) {
-				for i := len(oldSlice); i < len(newSlice); i++ {
-					changes = append(changes, Change{
-						Type:     Addition,
-						Path:     path + "[" + strconv.Itoa(i) + "]",
-						OldValue: nil,
-						NewValue: newSlice[i],
-					})
-				}
-			}
-		}
-
-	default:
-		// Primitive values - if they're different, it's a modification
-		if !reflect.DeepEqual(oldVal, newVal) {
-			changes = append(changes, Change{
-				Type:     Modification,
-				Path:     path,
-				OldValue: oldVal,
-				NewValue: newVal,
-			})
-		}
+// encodeValue formats a map or list as YAML
+func encodeValue(v interface{}) string {
+	// Format complex values as YAML with 3-space indentation
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(3) // 3-space indentation
+	if err := encoder.Encode(v); err != nil {
+		return fmt.Sprintf("%v", v) // fallback to default formatting
 	}
+	encoder.Close()
 
-	return changes
+	// Return the YAML string as-is
+	return strings.TrimSuffix(buf.String(), "\n")
 }
 
-// normalizeValue recursively normalizes a YAML value by sorting maps and slices
-func normalizeValue(v interface{}) interface{} {
-	if v == nil {
-		return v
-	}
-
-	val := reflect.ValueOf(v)
-	switch val.Kind() {
-	case reflect.Map:
-		// Sort map keys
-		keys := make([]reflect.Value, 0, val.Len())
-		for _, key := range val.MapKeys() {
-			keys = append(keys, key)
-		}
-
-		// Sort keys by their string representation
-		sort.Slice(keys, func(i, j int) bool {
-			return fmt.Sprintf("%v", keys[i].Interface()) < fmt.Sprintf("%v", keys[j].Interface())
-		})
-
-		// Create normalized map
-		normalized := make(map[interface{}]interface{})
-		for _, key := range keys {
-			normalized[key.Interface()] = normalizeValue(val.MapIndex(key).Interface())
-		}
-		return normalized
-
-	case reflect.Slice:
-		// Sort slice elements
-		elements := make([]interface{}, val.Len())
-		for i := 0; i < val.Len(); i++ {
-			elements[i] = normalizeValue(val.Index(i).Interface())
-		}
-
-		// Only sort slices that are not lists of dictionaries with identifiers
-		if !isSliceOfDictsWithIds(elements) {
-			// Sort by string representation for consistency
-			sort.Slice(elements, func(i, j int) bool {
-				return fmt.Sprintf("%v", elements[i]) < fmt.Sprintf("%v", elements[j])
-			})
-		}
-		return elements
+// diffDocuments compares two normalized documents, restricted to the --path
+// subtree when one is set. Change paths are always relative to the document root.
+func diffDocuments(oldDoc, newDoc interface{}) []Change {
+	return newChanges(ymldiff.Diff(oldDoc, newDoc, engineOptions()))
+}
 
-	default:
-		return v
-	}
+// diffValues compares two normalized values at path and returns a list of changes
+func diffValues(oldVal, newVal interface{}, path string) []Change {
+	return newChanges(ymldiff.DiffAt(oldVal, newVal, path, engineOptions()))
 }
 
-// YAMLDocument holds a document with its comments
-type YAMLDocument struct {
-	Data     interface{}
-	Comments []string
+// normalizeValue recursively normalizes a YAML value by sorting maps and slices
+func normalizeValue(v interface{}) interface{} {
+	return ymldiff.Normalize(v, engineOptions())
 }
 
 // Global configuration flags
 var disableComments bool
 var noDocComment bool
 var noColor bool
+var ignorePatterns []string
+var idKeys = ymldiff.DefaultIDKeys
+var normalizations []string
+var documentMatch = "index"
+var preserveKeyOrder bool
+var rawMode bool
+var strictMode bool
+var maxDepth int
+var parseEmbedded bool
+var normalizeQuantities bool
+var normalizeDurations bool
+var booleanMode = "yaml1.2"
+var looseNumbers bool
+var nullIsAbsent bool
+var emptyIsAbsent bool
+var trimStrings bool
+var collapseWhitespace bool
+var groupByParent bool
+var collapseBlocks bool
+var expandNewBlocks bool
+var exitCodes map[string]int
+var quietMode bool
+var forbiddenPaths []string
+var maxChanges = -1
+var reportMode bool
+var reportOptions []string
+var outputFormat = "text"
+var frontMatter bool
+var renderEngine string
+var templateValues map[string]interface{}
+var annotateMode bool
+var reversePatchFile string
+var blameMode bool
+var explainMode bool
+var interactiveMode bool
+var subtreePath string
+var detectReorders bool
+var itemSimilarity = ymldiff.DefaultItemSimilarity
+var yamlSnippets bool
+var emitFormat = "merged"
+var explainPath string
+var leftFormat string
+var rightFormat string
 
 // printHelp displays the help message
 func printHelp() {
@@ -450,17 +398,234 @@ func printHelp() {
 
 USAGE:
     ymldiff [OPTIONS] <file1.yaml> <file2.yaml>
+    ymldiff [OPTIONS] matrix <base.yaml> <env1.yaml> [env2.yaml...]
+    ymldiff [OPTIONS] self <file.yaml> <path1> <path2>
+    ymldiff [OPTIONS] --docs N:M <file.yaml>
+    ymldiff [OPTIONS] log [--since REV] <file.yaml>
+    ymldiff [OPTIONS] assert --expect <patch.yaml> [--expect-mode MODE]
+                    <file1.yaml> <file2.yaml>
+    ymldiff [OPTIONS] apply [--dry-run] [--confirm] [--only PATH] [--skip PATH]
+                    <patch.yaml> <target.yaml>
 
 DESCRIPTION:
     ymldiff is an intelligent YAML comparison tool that goes beyond simple text
     diffs. It understands YAML structure and provides meaningful, colored output
-    showing additions, deletions, and modifications.
+    showing additions, deletions, and modifications. JSON, TOML and .env files
+    are compared the same way, "-" reads a file from standard input and
+    FILE@REV reads a file as it was in a git revision, e.g. config.yaml@HEAD~3.
 
 OPTIONS:
     -h, --help              Show this help message and exit
     -c, --disable-comments  Disable display of YAML comments in output
     -d, --no-doc-comment    Disable document separator comments (--- # YAML Document: X/Y)
     -n, --no-color          Disable colored output
+    -q, --quiet             Print nothing and tell the result through the exit
+                            code: 0 when the files are identical, 1 when they
+                            differ (or the exitCodes of the config file)
+        --preset NAME       Apply a named preset profile (can be repeated)
+                            Built-in presets: kubernetes, k8s-noise, compose,
+                            ansible, github-actions
+        --config FILE       Load settings and user-defined presets from FILE
+        --markers STYLE     Mark changes with symbols (+ - ~ →, default), ascii
+                            (+ - ~ ->) or words (added, removed, changed);
+                            single markers can be set in the config file
+        --preserve-key-order
+                            Show added/removed values with keys in source order
+        --raw               Disable all normalization: lists are compared by
+                            position and list order changes count as differences
+        --multiset          Compare lists of scalars as bags: each value whose
+                            number of occurrences changed is reported once with
+                            the count added or removed (e.g. + .tags[]: 2× foo)
+        --strict            Fail with their location on values with unknown tags
+                            (e.g. !vault), whose tags would be dropped, and on
+                            map keys that are maps or lists
+    -C, --context N         Show up to N unchanged sibling keys above and below
+                            each change, dimmed
+        --group-by-parent   Group changes sharing a parent path under one header
+                            telling how many of its keys changed, e.g.
+                            .spec: (3 of 41 keys changed)
+        --locations         Show where each change is as file:line:column, e.g.
+                            ~ .spec.replicas (old.yaml:7:3, new.yaml:7:3): 2 → 3
+        --sort ORDER        Order of the changes: path (default), type for
+                            deletions, additions, then modifications, source
+                            for the order of their lines in the new file, or
+                            none for the order they were found in
+        --show-source       Print the lines of each change in both files as
+                            written, numbered as in the files
+        --rollup            Instead of the changes, list the paths above them
+                            with the number of changes nested below each, e.g.
+                            .spec: + 2 - 1 ~ 5
+        --collapse-blocks   Summarize added/removed maps and lists in one line
+                            (e.g. <map, 37 keys>) instead of printing them
+        --expand-new-blocks With --collapse-blocks, still print added blocks in full
+        --max-changes N     Fail when more than N changes are detected
+        --limit N           Only show the first N changes, followed by a note on
+                            how many more there are, to keep logs bounded
+        --fail-on TYPES     Only fail on changes of the given types (addition,
+                            deletion, modification, move; comma-separated),
+                            e.g. deletion,modification to let additive changes
+                            pass; other changes exit as when the files differ.
+                            major-bump, minor-bump and patch-bump fail on
+                            semantic versions changing at that level, either way
+        --report            Add a header (version, inputs, timestamps, options)
+                            and a footer with total change counts
+    -o, --output FORMAT     Output format: text (default), json, ndjson, yaml
+                            (the json report as YAML), porcelain or unified (a
+                            classic unified diff of both files rendered with
+                            sorted keys and 2-space indentation), gitlab (a
+                            GitLab code quality report) or tap (a Test Anything
+                            Protocol test point per document)
+        --porcelain         Same as --output porcelain: one change per line as
+                            tab-separated type, document, path, old and new
+                            values, kept stable across releases
+        --brief             Only list the changed paths, one per line, e.g. for
+                            xargs or yq
+        --count             Only print the number of changes, e.g. to alert on
+                            the size of a drift
+        --format TEMPLATE   Print each change with a Go template instead of the
+                            diff; changes have the fields of the json report
+                            (.Document, .Path, .Type, .Old, .New, .Count) and
+                            toJson, toYaml, default, upper and lower are available
+        --format-file FILE  Same as --format, with the template read from FILE
+        --yaml-snippets     Include the old and new values rendered as YAML
+                            (oldYAML, newYAML) in json/ndjson reports
+        --report-schema     Print the JSON Schema of the json/ndjson report and exit
+        --front-matter      Compare only the "---" delimited YAML front matter,
+                            ignoring the body (automatic for .md/.markdown files)
+        --render ENGINE     Render inputs before parsing; ENGINE is go-template
+        --values FILE       Values available as template data (can be repeated)
+        --annotate          Print the second file as written, with "# ymldiff:"
+                            comments marking changed, added and removed entries
+        --reverse-patch FILE
+                            Also write the patch turning the second file back
+                            into the first (a roll-back patch) to FILE
+        --reverse           Print only the roll-back patch, instead of the diff
+        --blame             Show who last touched each added or changed line of
+                            the second file (requires it to be tracked by git)
+        --id-key FIELD      Match list items by FIELD (can be repeated). Fields
+                            are tried in the given order, before the preset
+                            ones and the defaults (name, key, id)
+        --detect-reorders   Report keyed list items (matched by name, key or id)
+                            that changed position, e.g. reordered init containers
+        --item-similarity RATIO
+                            Pair items of lists of maps without identifier
+                            fields that are at least RATIO (0-1, default 0.5)
+                            alike; 0 compares them by position instead
+        --match-documents RULE
+                            Match the documents of both files by position
+                            (index, default), by kind/namespace/name
+                            (kubernetes) or by content (similarity); moved
+                            documents are reported as moves
+        --docs N:M          Compare document N against document M of a single file
+        --docs LIST         With two files, only compare the listed documents:
+                            numbers, ranges and Kubernetes object names
+                            (kind/namespace/name, kind/name or name),
+                            e.g. 2,4-6,Deployment/web
+        --label old=NAME, --label new=NAME
+                            Name the inputs in document separators, report
+                            headers, JSON reports and patches, e.g. when they
+                            are read from stdin or process substitution
+        --map OLD=>NEW      Compare the value at OLD in the first file against the
+                            value at NEW in the second, e.g. across a renaming
+                            migration; changes are reported under NEW (can be
+                            repeated)
+        --path PATH         Only compare the subtree at PATH (e.g. .spec.template)
+        --max-depth N       Report a changed map or list N path segments deep
+                            (e.g. 2 for .spec.template) as one modification
+                            instead of every change nested in it
+        --parse-embedded    Compare strings that hold YAML or JSON maps or lists
+                            (e.g. files in a ConfigMap) key by key, reporting
+                            changes inside them as .data.app.yaml|.server.port
+        --decode-base64[=PATTERN]
+                            Decode base64-encoded text (e.g. Secret data) before
+                            comparing, only at paths matching PATTERN if given
+                            (can be repeated); such changes are marked
+                            (base64-decoded)
+        --parse-certs       Compare PEM certificates by subject, issuer, serial
+                            number, validity, subject alternative names and
+                            fingerprint instead of as text, and other PEM
+                            blocks (e.g. keys) by fingerprint
+        --epsilon N, --epsilon PATTERN=N
+                            Treat numbers differing by at most N as equal,
+                            everywhere or only at paths matching PATTERN (can
+                            be repeated; the first matching PATTERN wins)
+        --ignore-timestamps Treat any two dates and times (ISO 8601, RFC 3339 and
+                            RFC 1123), such as creationTimestamp or lastUpdated,
+                            as equal, so regenerated ones aren't reported
+        --timestamp-pattern REGEX
+                            Also treat values matching REGEX as timestamps (can
+                            be repeated, implies --ignore-timestamps)
+        --null-is-absent    Don't report keys set to null in one file and missing
+                            in the other, as many config loaders treat them alike
+        --empty-is-absent   Don't report keys set to an empty map or list ({} or [])
+                            in one file and missing in the other, as generated
+                            manifests often differ that way
+        --trim-strings      Treat strings differing only in leading and trailing
+                            whitespace (e.g. a final line break) as equal
+        --collapse-whitespace
+                            Treat strings differing only in the length of runs
+                            of spaces, tabs and line breaks as equal
+        --loose-numbers     Treat numbers and strings holding them as equal, such
+                            as "8080" and 8080, as consumers coercing them do
+        --booleans MODE     How to read booleans: yaml1.2 (default) treats only
+                            true and false as booleans, so yes and true differ;
+                            yaml1.1 treats yes/no, on/off and y/n in any case as
+                            equal to true and false, as YAML 1.1 consumers do
+        --normalize-quantities
+                            Treat quantities of the same amount as equal, such
+                            as Kubernetes resources (1Gi and 1024Mi, 0.5 and
+                            500m) and byte sizes (1GiB and 1024MiB)
+        --normalize-durations
+                            Treat durations of the same length as equal, written
+                            as in Go (60s, 1h30m, 2d) or ISO 8601 (PT1M)
+        --ignore PATTERN    Drop changes at paths matching PATTERN (can be
+                            repeated): * matches within one key, ** matches
+                            anything, e.g. '.status.**'; '.**.name' and
+                            '*.name' match name at any depth
+        --mask PATTERN      Show the values at paths matching PATTERN as ***** in
+                            every output while still comparing them (can be
+                            repeated), e.g. '.**.password', so diffs can be
+                            shared in tickets and chat
+        --redact-secrets    Show values that look like secrets as *****: those of
+                            keys named like password, token or apiKey, and long
+                            random-looking strings. On by default when the CI
+                            environment variable is set, except for assert and
+                            the json, ndjson, yaml, porcelain, gitlab and
+                            template outputs; --redact-secrets=false turns it off
+        --ignore-value-regex REGEX
+                            Leave the parts of values matching REGEX out when
+                            comparing them (can be repeated), so values that only
+                            differ in e.g. digests, UUIDs or build numbers aren't
+                            reported: 'sha256:[0-9a-f]+'
+        --explain PATH      Instead of the diff, explain why changes at PATH were
+                            or weren't reported: the normalized values of both
+                            sides, the rules applied and the matching used
+        --debug             Trace to stderr which normalizations were applied, how
+                            documents and list items were matched, and which
+                            ignore patterns fired
+        --jobs N            Process at most N files concurrently in matrix mode
+                            (default: the number of CPUs available)
+        --since REV         With log, only include commits after REV
+    -i, --interactive       Step through the changes, accepting or rejecting each
+                            one, then print the first file with the accepted
+                            changes merged in
+        --emit FORMAT       With --interactive, print the merged YAML (merged,
+                            default) or a patch of the accepted changes (patch)
+        --dry-run           With apply, print the changes and the resulting file
+                            instead of writing the target file
+        --confirm           With apply, ask before applying each change
+        --only PATTERN      With apply, only apply changes to matching paths
+                            (can be repeated)
+        --skip PATTERN      With apply, skip changes to matching paths (can be
+                            repeated)
+        --expect FILE       With assert, the patch file of the expected changes
+        --expect-mode MODE  With assert, require the changes to be exactly the
+                            expected ones (exact, default), only expected ones
+                            (subset) or to include all expected ones (superset)
+        --left-format FORMAT
+        --right-format FORMAT
+                            Format of the first/second file: yaml, json, toml
+                            or env (default: detected from the file extension)
 
 EXAMPLES:
     # Basic comparison
@@ -476,10 +641,107 @@ EXAMPLES:
     # Compare without colors (for piping to files or logs)
     ymldiff -n config1.yaml config2.yaml
 
+    # Compare live Kubernetes objects against manifests without server-managed noise
+    ymldiff --preset k8s-noise manifest.yaml live.yaml
+
+    # Compare Kubernetes manifests, matching documents by kind/namespace/name
+    ymldiff --preset kubernetes old.yaml new.yaml
+
+    # Show which settings drift across environments (rows = paths, columns = files)
+    ymldiff matrix base.yaml envs/*.yaml
+
+    # How do the staging and prod blocks of one file differ?
+    ymldiff self config.yaml '.environments.staging' '.environments.prod'
+
+    # How does the third document (e.g. the prod variant) differ from the first?
+    ymldiff --docs 1:3 variants.yaml
+
+    # Changelog of every setting changed since a release, grouped by path
+    ymldiff log config.yaml --since v1.0.0
+
+    # Reordered documents are reported as moves, not as unrelated changes
+    ymldiff --match-documents similarity old.yaml new.yaml
+
+    # Compare a file against how it was three commits ago
+    ymldiff config.yaml@HEAD~3 config.yaml
+
+    # Focus on the pod template of two Deployments
+    ymldiff --path '.spec.template' old.yaml new.yaml
+
+    # Drop changes to machine-managed fields
+    ymldiff --ignore '.metadata.resourceVersion' --ignore '.status.**' old.yaml new.yaml
+
+    # Use as git's diff program for YAML files
+    git config diff.ymldiff.command ymldiff
+    echo '*.yaml diff=ymldiff' >> .gitattributes
+
+    # Find out why a list change is (not) reported
+    ymldiff --explain '.spec.containers' old.yaml new.yaml
+
+    # Include who changed each setting in a drift report
+    ymldiff --blame deployed.yaml config.yaml
+
+    # Require a human review when a change touches more than 20 keys
+    ymldiff --max-changes 20 old.yaml new.yaml
+
+    # One line per change in a format of your own
+    ymldiff --format '{{.Type}} {{.Path}} {{.New | toJson}}' old.yaml new.yaml
+
+    # Machine-readable report for CI scripts and jq
+    ymldiff -o json old.yaml new.yaml | jq '.changes[].path'
+
+    # Compare the front matter of two Markdown pages
+    ymldiff content/post-v1.md content/post-v2.md
+
+    # Compare Go-templated manifests in their rendered form
+    ymldiff --render go-template --values prod.yaml deploy-v1.tpl.yaml deploy-v2.tpl.yaml
+
+    # Reviewer-friendly copy of the new file with the changes marked inline
+    ymldiff --annotate old.yaml new.yaml > new.annotated.yaml
+
+    # Compare a TOML file against YAML read from standard input
+    helm get values app | ymldiff --right-format yaml config.toml -
+
+    # Show the diff and keep a roll-back patch next to it
+    ymldiff --reverse-patch rollback.yaml old.yaml new.yaml
+
+    # Only generate the roll-back patch
+    ymldiff --reverse old.yaml new.yaml > rollback.yaml
+
+    # Preview, then apply the roll-back patch one change at a time
+    ymldiff apply --dry-run rollback.yaml new.yaml
+    ymldiff apply --confirm rollback.yaml new.yaml
+
+    # Pick which changes to take over, git add -p style
+    ymldiff -i old.yaml new.yaml > merged.yaml
+    ymldiff -i --emit patch old.yaml new.yaml > picked.yaml
+
+    # Cherry-pick part of a larger change
+    ymldiff apply patch.yaml target.yaml --only '.spec.replicas' --skip '.data.*'
+
+    # Let additive config changes pass a CI gate, but block deletions
+    ymldiff -q --fail-on deletion,modification old.yaml new.yaml
+
+    # Fail a pipeline test unless the generated config changed as expected
+    ymldiff assert --expect expected-changes.yaml old.yaml new.yaml
+
+    # Script on whether two files differ
+    if ymldiff -q old.yaml new.yaml; then echo "no changes"; fi
+
     # Combine multiple options (short flags can be combined)
     ymldiff -cd config1.yaml config2.yaml
     ymldiff -cdn config1.yaml config2.yaml
 
+EXIT STATUS:
+    0 when the files are identical or differ (1 when they differ with --quiet),
+    1 when a forbidden path changed, --max-changes was exceeded, a change of a
+    --fail-on type was found or the changes don't match an assert, 2 on usage
+    errors, 3 when a file is not found, 4 when a file can't be parsed and 5 on
+    other errors. The exit code of each outcome (identical, changes,
+    forbidden-path-change, too-many-changes, fail-on-change, assertion-failed,
+    usage-error, file-not-found, parse-error, internal-error) can be set in the
+    exitCodes section of the config file.
+
 AUTHOR:
     Marek Wajdzik <marek@jest.pro>
 
@@ -489,87 +751,55 @@ LICENSE:
 	fmt.Print(helpText)
 }
 
-// parseYAML parses a YAML file and normalizes it, handling multiple documents and preserving comments
-func parseYAML(filename string) ([]YAMLDocument, error) {
-	data, err := os.ReadFile(filename)
+// readInput reads a file, standard input for "-" or a file as it was in a git
+// revision for FILE@REV, and prepares its content for parsing
+func readInput(filename string) ([]byte, error) {
+	var data []byte
+	var err error
+	if filename == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else if file, revision, ok := splitRevision(filename); ok {
+		data, err = readRevision(file, revision)
+		filename = file
+	} else {
+		data, err = readFile(filename)
+	}
 	if err != nil {
 		return nil, err
 	}
-
-	var documents []YAMLDocument
-	decoder := yaml.NewDecoder(bytes.NewReader(data))
-
-	for {
-		var node yaml.Node
-		if err := decoder.Decode(&node); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
-		}
-
-		// Extract comments from the node
-		comments := extractComments(&node)
-
-		// Convert node to interface{}
-		var doc interface{}
-		if err := node.Decode(&doc); err != nil {
-			return nil, err
-		}
-
-		documents = append(documents, YAMLDocument{
-			Data:     normalizeValue(doc),
-			Comments: comments,
-		})
-	}
-
-	return documents, nil
+	return prepareInput(filename, data)
 }
 
-// extractComments recursively extracts all comments from a YAML node
-func extractComments(node *yaml.Node) []string {
-	var comments []string
-
-	if node.HeadComment != "" {
-		lines := strings.Split(strings.TrimSpace(node.HeadComment), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" {
-				if !strings.HasPrefix(line, "#") {
-					line = "# " + line
-				}
-				comments = append(comments, line)
-			}
+// prepareInput renders and extracts the part of a file's content to be parsed
+func prepareInput(filename string, data []byte) ([]byte, error) {
+	if renderEngine == "go-template" {
+		var err error
+		data, err = renderTemplate(filename, data)
+		if err != nil {
+			return nil, newParseError(filename, err)
 		}
 	}
 
-	if node.LineComment != "" {
-		line := strings.TrimSpace(node.LineComment)
-		if !strings.HasPrefix(line, "#") {
-			line = "# " + line
-		}
-		comments = append(comments, line)
+	// Markdown files are compared by their front matter only
+	if frontMatter || isMarkdownFile(filename) {
+		data = extractFrontMatter(data)
 	}
 
-	if node.FootComment != "" {
-		lines := strings.Split(strings.TrimSpace(node.FootComment), "\n")
-		for _, line := range lines {
-			line = strings.TrimSpace(line)
-			if line != "" {
-				if !strings.HasPrefix(line, "#") {
-					line = "# " + line
-				}
-				comments = append(comments, line)
-			}
-		}
-	}
+	return data, nil
+}
 
-	// Recursively extract from children
-	for _, child := range node.Content {
-		comments = append(comments, extractComments(child)...)
+// parseYAML parses a YAML file and normalizes it, handling multiple documents and preserving comments
+func parseYAML(filename string) ([]YAMLDocument, error) {
+	data, err := readInput(filename)
+	if err != nil {
+		return nil, err
 	}
+	return parseYAMLData(data)
+}
 
-	return comments
+// parseYAMLData parses and normalizes the documents of YAML content
+func parseYAMLData(data []byte) ([]YAMLDocument, error) {
+	return ymldiff.Parse(data, engineOptions())
 }
 
 func main() {
@@ -578,14 +808,100 @@ func main() {
 	disableCommentsFlag := flag.BoolP("disable-comments", "c", false, "Disable display of YAML comments")
 	noDocCommentFlag := flag.BoolP("no-doc-comment", "d", false, "Disable document separator comments")
 	noColorFlag := flag.BoolP("no-color", "n", false, "Disable colored output")
+	quietFlag := flag.BoolP("quiet", "q", false, "Print nothing and report differences through the exit code")
+	presetFlag := flag.StringArray("preset", nil, "Apply a named preset profile")
+	configFlag := flag.String("config", "", "Load settings from a configuration file")
+	markersFlag := flag.String("markers", "symbols", "Change markers: symbols, ascii or words")
+	preserveKeyOrderFlag := flag.Bool("preserve-key-order", false, "Keep source key order in displayed values")
+	rawFlag := flag.Bool("raw", false, "Disable all normalization and compare documents as authored")
+	multisetFlag := flag.Bool("multiset", false, "Compare lists of scalars as bags, counting added and removed duplicates")
+	strictFlag := flag.Bool("strict", false, "Fail on unknown tags and map keys that can't be compared")
+	groupByParentFlag := flag.Bool("group-by-parent", false, "Group changes under their parent path")
+	sortFlag := flag.String("sort", "path", "Order of the changes: path, type, source or none")
+	showSourceFlag := flag.Bool("show-source", false, "Print the source lines of each change from both files")
+	locationsFlag := flag.Bool("locations", false, "Show the file, line and column of each change")
+	rollupFlag := flag.Bool("rollup", false, "Show the paths above the changes with how many changes are nested below each")
+	collapseBlocksFlag := flag.Bool("collapse-blocks", false, "Summarize added and removed maps and lists in one line")
+	expandNewBlocksFlag := flag.Bool("expand-new-blocks", false, "Show added blocks in full when collapsing blocks")
+	maxChangesFlag := flag.Int("max-changes", -1, "Fail when more than N changes are detected")
+	limitFlag := flag.Int("limit", -1, "Only show the first N changes, noting how many more there are")
+	failOnFlag := flag.String("fail-on", "", "Only fail on changes of these types: addition, deletion, modification, move (comma-separated)")
+	reportFlag := flag.Bool("report", false, "Add a report header and footer with metadata and totals")
+	briefFlag := flag.Bool("brief", false, "Only list the changed paths, one per line")
+	countFlag := flag.Bool("count", false, "Only print the number of changes")
+	porcelainFlag := flag.Bool("porcelain", false, "Print changes in the stable, tab-separated porcelain format for scripts")
+	contextFlag := flag.IntP("context", "C", 0, "Show up to N unchanged sibling keys above and below each change")
+	outputFlag := flag.StringP("output", "o", "text", "Output format: text, json, ndjson, yaml, porcelain, unified, gitlab or tap")
+	yamlSnippetsFlag := flag.Bool("yaml-snippets", false, "Include the old and new values rendered as YAML in JSON reports")
+	reportSchemaFlag := flag.Bool("report-schema", false, "Print the JSON Schema of the JSON report and exit")
+	frontMatterFlag := flag.Bool("front-matter", false, "Compare only the YAML front matter of the files")
+	renderFlag := flag.String("render", "", "Render inputs with a template engine before parsing (go-template)")
+	valuesFlag := flag.StringArray("values", nil, "Values file for --render (can be repeated)")
+	annotateFlag := flag.Bool("annotate", false, "Print the second file with comments marking the changes")
+	leftFormatFlag := flag.String("left-format", "", "Format of the first file: yaml, json, toml or env")
+	rightFormatFlag := flag.String("right-format", "", "Format of the second file: yaml, json, toml or env")
+	blameFlag := flag.Bool("blame", false, "Show the author and commit that last touched each changed line")
+	labelFlag := flag.StringArray("label", nil, "Name an input in headers and reports, as old=NAME or new=NAME (can be repeated)")
+	mapFlag := flag.StringArray("map", nil, "Compare a path of the first file against another path of the second, as OLD=>NEW (can be repeated)")
+	idKeyFlag := flag.StringArray("id-key", nil, "Identifier field of list items, in priority order (can be repeated)")
+	detectReordersFlag := flag.Bool("detect-reorders", false, "Report keyed list items whose position changed")
+	itemSimilarityFlag := flag.Float64("item-similarity", ymldiff.DefaultItemSimilarity, "Minimum similarity of paired items in lists without identifier fields (0 disables)")
+	matchDocumentsFlag := flag.String("match-documents", "", "Match documents by index, kubernetes identity or similarity")
+	pathFlag := flag.String("path", "", "Only compare the subtree at this path")
+	parseEmbeddedFlag := flag.Bool("parse-embedded", false, "Compare strings holding YAML or JSON structurally")
+	maxDepthFlag := flag.Int("max-depth", 0, "Report changed maps and lists N path segments deep as one modification")
+	parseCertsFlag := flag.Bool("parse-certs", false, "Compare PEM certificates by subject, issuer, SANs, validity and fingerprint")
+	normalizeQuantitiesFlag := flag.Bool("normalize-quantities", false, "Treat quantities of the same amount as equal, such as 1Gi and 1024Mi or 0.5 and 500m")
+	normalizeDurationsFlag := flag.Bool("normalize-durations", false, "Treat durations of the same length as equal, such as 60s, 1m and PT1M")
+	ignoreTimestampsFlag := flag.Bool("ignore-timestamps", false, "Treat any two dates and times, such as creationTimestamp, as equal")
+	timestampPatternFlag := flag.StringArray("timestamp-pattern", nil, "Also treat values matching this regular expression as timestamps (can be repeated, implies --ignore-timestamps)")
+	nullIsAbsentFlag := flag.Bool("null-is-absent", false, "Treat keys set to null as missing")
+	emptyIsAbsentFlag := flag.Bool("empty-is-absent", false, "Treat keys set to an empty map or list as missing")
+	trimStringsFlag := flag.Bool("trim-strings", false, "Treat strings differing only in leading and trailing whitespace as equal")
+	collapseWhitespaceFlag := flag.Bool("collapse-whitespace", false, "Treat strings differing only in the length of runs of whitespace as equal")
+	looseNumbersFlag := flag.Bool("loose-numbers", false, "Treat numbers and strings holding them as equal, such as \"8080\" and 8080")
+	booleansFlag := flag.String("booleans", "yaml1.2", "How to read booleans: yaml1.2 (yes and true differ) or yaml1.1 (yes, on and true are equal)")
+	epsilonFlag := flag.StringArray("epsilon", nil, "Treat numbers differing by at most N as equal, at paths matching PATTERN if given as PATTERN=N (can be repeated)")
+	decodeBase64Flag := flag.StringArray("decode-base64", nil, "Decode base64-encoded text before comparing, at paths matching the pattern if given (can be repeated)")
+	flag.Lookup("decode-base64").NoOptDefVal = "**"
+	ignoreFlag := flag.StringArray("ignore", nil, "Drop changes at paths matching this pattern (can be repeated)")
+	redactSecretsFlag := flag.Bool("redact-secrets", false, "Show values that look like secrets as ***** (default in CI)")
+	maskFlag := flag.StringArray("mask", nil, "Show the values at paths matching this pattern as ***** while still comparing them (can be repeated)")
+	ignoreValueRegexFlag := flag.StringArray("ignore-value-regex", nil, "Leave the parts of values matching this regular expression out of the comparison (can be repeated)")
+	explainFlag := flag.String("explain", "", "Explain why changes at a path were or weren't reported")
+	debugFlag := flag.Bool("debug", false, "Trace normalization, matching and ignore decisions to stderr")
+	jobsFlag := flag.Int("jobs", jobs, "Maximum number of files processed concurrently")
+	interactiveFlag := flag.BoolP("interactive", "i", false, "Step through the changes and accept or reject each one")
+	emitFlag := flag.String("emit", "merged", "With --interactive, output the merged YAML (merged) or a patch (patch)")
+	dryRunFlag := flag.Bool("dry-run", false, "With apply, print the resulting file and changes without writing it")
+	confirmFlag := flag.Bool("confirm", false, "With apply, confirm each change interactively")
+	onlyFlag := flag.StringArray("only", nil, "With apply, only apply changes to paths matching this pattern (can be repeated)")
+	skipFlag := flag.StringArray("skip", nil, "With apply, skip changes to paths matching this pattern (can be repeated)")
+	expectFlag := flag.String("expect", "", "With assert, the patch file of the expected changes")
+	expectModeFlag := flag.String("expect-mode", expectExact, "With assert, whether the changes must be exactly (exact), within (subset) or at least (superset) the expected ones")
+	docsFlag := flag.String("docs", "", "Compare two documents N:M of a single file, or only the listed documents of two files")
+	sinceFlag := flag.String("since", "", "With log, only include commits after this revision")
+	formatFlag := flag.String("format", "", "Print each change with a Go template, e.g. '{{.Type}} {{.Path}} {{.New}}'")
+	formatFileFlag := flag.String("format-file", "", "Print each change with the Go template in a file")
+	reversePatchFlag := flag.String("reverse-patch", "", "Write the patch turning the second file back into the first to a file")
+	reverseFlag := flag.Bool("reverse", false, "Print the patch turning the second file back into the first instead of the diff")
 
 	// Custom usage function
 	flag.Usage = func() {
 		printHelp()
 	}
 
-	// Parse flags
-	flag.Parse()
+	// Parse flags, reporting bad ones as usage errors so that they exit with
+	// the usage error code, the configured one when --config came before them
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if *configFlag != "" {
+			if config, configErr := loadConfig(*configFlag); configErr == nil {
+				exitCodes = config.ExitCodes
+			}
+		}
+		usageError("%v", err)
+	}
 
 	// Check for help flags
 	if *helpFlag {
@@ -593,10 +909,325 @@ func main() {
 		os.Exit(0)
 	}
 
+	if *reportSchemaFlag {
+		fmt.Print(reportSchema)
+		os.Exit(0)
+	}
+
+	// The config is loaded before the flags are checked, so that usage errors
+	// already exit with the codes it configures
+	var config *Config
+	if *configFlag != "" {
+		var err error
+		config, err = loadConfig(*configFlag)
+		if err != nil {
+			exitWithError(fmt.Errorf("loading config %s: %w", *configFlag, err))
+		}
+		exitCodes = config.ExitCodes
+		forbiddenPaths = config.ForbiddenPaths
+		theme, _ = mergeTheme(defaultTheme, config.Theme)
+		dateRules = config.Dates
+		equivalenceRules = config.Equivalences
+		toleranceRules = config.Tolerances
+	}
+
 	// Set global flags
 	disableComments = *disableCommentsFlag
 	noDocComment = *noDocCommentFlag
 	noColor = *noColorFlag
+	preserveKeyOrder = *preserveKeyOrderFlag
+	rawMode = *rawFlag
+	multisetMode = *multisetFlag
+	strictMode = *strictFlag
+	groupByParent = *groupByParentFlag
+	rollupMode = *rollupFlag
+	locationsMode = *locationsFlag
+	showSource = *showSourceFlag
+	sortOrder = *sortFlag
+	if !containsString(sortOrders, sortOrder) {
+		usageError("Unknown sort order %q (available: %s)", sortOrder, strings.Join(sortOrders, ", "))
+	}
+	contextSiblings = *contextFlag
+	collapseBlocks = *collapseBlocksFlag
+	expandNewBlocks = *expandNewBlocksFlag
+	maxChanges = *maxChangesFlag
+	changeLimit = *limitFlag
+	if *failOnFlag != "" {
+		types, bumps, err := parseFailOn(*failOnFlag)
+		if err != nil {
+			usageError("%v", err)
+		}
+		failOnTypes, failOnBumps, failOnSpec = types, bumps, *failOnFlag
+	}
+	reportMode = *reportFlag
+	reportOptions = usedOptions(flag.CommandLine)
+	outputFormat = *outputFlag
+	switch outputFormat {
+	case "text", "json", "ndjson", "yaml", "porcelain", "unified", "gitlab", "tap":
+	default:
+		usageError("Unknown output format %q", outputFormat)
+	}
+	if *porcelainFlag {
+		if flag.CommandLine.Changed("output") && outputFormat != "porcelain" {
+			usageError("--porcelain can't be combined with --output %s", outputFormat)
+		}
+		outputFormat = "porcelain"
+	}
+	if *briefFlag {
+		if outputFormat != "text" {
+			usageError("--brief can't be combined with --output %s", outputFormat)
+		}
+		outputFormat = "brief"
+	}
+	if *countFlag {
+		if outputFormat == "brief" {
+			usageError("--count can't be combined with --brief")
+		}
+		if outputFormat != "text" {
+			usageError("--count can't be combined with --output %s", outputFormat)
+		}
+		outputFormat = "count"
+	}
+	if *formatFlag != "" || *formatFileFlag != "" {
+		if *formatFlag != "" && *formatFileFlag != "" {
+			usageError("--format and --format-file can't be combined")
+		}
+		if outputFormat == "brief" || outputFormat == "count" {
+			usageError("--format can't be combined with --%s", outputFormat)
+		}
+		if outputFormat != "text" {
+			usageError("--format can't be combined with --output %s", outputFormat)
+		}
+		text := *formatFlag
+		if *formatFileFlag != "" {
+			data, err := os.ReadFile(*formatFileFlag)
+			if err != nil {
+				exitWithError(err)
+			}
+			text = string(data)
+		}
+		tmpl, err := parseChangeTemplate(text)
+		if err != nil {
+			usageError("%v", err)
+		}
+		changeTemplate = tmpl
+		outputFormat = "template"
+	}
+	yamlSnippets = *yamlSnippetsFlag
+	frontMatter = *frontMatterFlag
+	renderEngine = *renderFlag
+	annotateMode = *annotateFlag
+	blameMode = *blameFlag
+	jobs = *jobsFlag
+	debugMode = *debugFlag
+	explainMode = flag.CommandLine.Changed("explain")
+	subtreePath = normalizePathArgument(*pathFlag)
+	maxDepth = *maxDepthFlag
+	parseEmbedded = *parseEmbeddedFlag
+	if maxDepth < 0 {
+		usageError("--max-depth must not be negative")
+	}
+	detectReorders = *detectReordersFlag
+	itemSimilarity = *itemSimilarityFlag
+	interactiveMode = *interactiveFlag
+	emitFormat = *emitFlag
+	quietMode = *quietFlag
+
+	switch emitFormat {
+	case "merged", "patch":
+	default:
+		usageError("Unknown --emit format %q", emitFormat)
+	}
+	if interactiveMode && (outputFormat != "text" || annotateMode || explainMode) {
+		usageError("--interactive only supports text output without --annotate and --explain")
+	}
+	explainPath = normalizePathArgument(*explainFlag)
+	if quietMode && (interactiveMode || flag.CommandLine.Changed("confirm")) {
+		usageError("--quiet can't be combined with --interactive or --confirm")
+	}
+	if quietMode {
+		// Only errors are printed; the result is told by the exit code
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Stdout = devNull
+		color.Output = devNull
+		warningOutput = io.Discard
+	}
+
+	if explainMode && outputFormat != "text" {
+		usageError("--explain only supports text output")
+	}
+
+	if itemSimilarity < 0 || itemSimilarity > 1 {
+		usageError("--item-similarity must be between 0 and 1")
+	}
+
+	if contextSiblings < 0 {
+		usageError("--context must not be negative")
+	}
+
+	if jobs < 1 {
+		usageError("--jobs must be at least 1")
+	}
+	reversePatchFile = *reversePatchFlag
+	if *reverseFlag {
+		if reversePatchFile != "" || quietMode || interactiveMode || annotateMode || explainMode || outputFormat != "text" {
+			usageError("--reverse can't be combined with --reverse-patch, --quiet, --interactive, --annotate, --explain or --output")
+		}
+		if containsString([]string{"matrix", "self", "log", "assert", "apply"}, flag.Arg(0)) {
+			usageError("--reverse can't be combined with the %s command", flag.Arg(0))
+		}
+		// "-" prints the roll-back patch in place of the diff
+		reversePatchFile = "-"
+	}
+	if parseEmbedded && (interactiveMode || reversePatchFile != "") {
+		usageError("--parse-embedded can't be combined with --interactive or --reverse-patch")
+	}
+	leftFormat = *leftFormatFlag
+	rightFormat = *rightFormatFlag
+
+	for _, format := range []string{leftFormat, rightFormat} {
+		if format != "" && !containsString(inputFormats, format) {
+			usageError("Unknown input format %q (available: %s)", format, strings.Join(inputFormats, ", "))
+		}
+	}
+
+	if annotateMode && outputFormat != "text" {
+		usageError("--annotate only supports text output")
+	}
+	if blameMode && outputFormat != "text" {
+		usageError("--blame only supports text output")
+	}
+	if showSource && outputFormat != "text" {
+		usageError("--show-source only supports text output")
+	}
+	for _, pattern := range *maskFlag {
+		maskPatterns = append(maskPatterns, normalizePathArgument(pattern))
+	}
+	redactSecrets = *redactSecretsFlag
+	if !flag.CommandLine.Changed("redact-secrets") {
+		// CI logs are shared widely, so likely secrets are redacted there
+		// unless the output is meant to be applied or read by a program
+		redactSecrets = os.Getenv("CI") != "" && !interactiveMode && reversePatchFile == "" && !showSource &&
+			!containsString(machineReadableFormats, outputFormat) && flag.Arg(0) != "assert"
+	}
+	if masking() && (interactiveMode || reversePatchFile != "" || showSource) {
+		usageError("--mask and --redact-secrets can't be combined with --interactive, --reverse-patch or --show-source")
+	}
+	if rollupMode && outputFormat != "text" {
+		usageError("--rollup only supports text output")
+	}
+	if rollupMode && groupByParent {
+		usageError("--rollup can't be combined with --group-by-parent")
+	}
+
+	switch renderEngine {
+	case "", "go-template":
+	default:
+		usageError("Unknown template engine %q", renderEngine)
+	}
+	if len(*valuesFlag) > 0 {
+		if renderEngine == "" {
+			usageError("--values requires --render")
+		}
+		var err error
+		templateValues, err = loadTemplateValues(*valuesFlag)
+		if err != nil {
+			exitWithError(fmt.Errorf("loading values: %w", err))
+		}
+	}
+
+	var markerOverrides Markers
+	if config != nil {
+		markerOverrides = config.Markers
+	}
+	resolvedMarkers, err := resolveMarkers(*markersFlag, markerOverrides)
+	if err != nil {
+		usageError("%v", err)
+	}
+	markers = resolvedMarkers
+
+	for _, name := range *presetFlag {
+		preset, err := resolvePreset(name, config)
+		if err != nil {
+			usageError("%v", err)
+		}
+		applyPreset(preset)
+	}
+
+	// Patterns given on the command line add to the ones of the presets
+	base64Patterns = *decodeBase64Flag
+	// Decoded values would be written into the file or patch as plain text
+	if len(base64Patterns) > 0 && (interactiveMode || reversePatchFile != "") {
+		usageError("--decode-base64 can't be combined with --interactive or --reverse-patch")
+	}
+	parseCerts = *parseCertsFlag
+	// Certificate descriptions would be written into the file or patch in
+	// place of the PEM blocks
+	if parseCerts && (interactiveMode || reversePatchFile != "") {
+		usageError("--parse-certs can't be combined with --interactive or --reverse-patch")
+	}
+	normalizeQuantities = *normalizeQuantitiesFlag
+	normalizeDurations = *normalizeDurationsFlag
+	looseNumbers = *looseNumbersFlag
+	nullIsAbsent = *nullIsAbsentFlag
+	emptyIsAbsent = *emptyIsAbsentFlag
+	trimStrings = *trimStringsFlag
+	collapseWhitespace = *collapseWhitespaceFlag
+	booleanMode = strings.ToLower(*booleansFlag)
+	if !containsString(ymldiff.BooleanModes, booleanMode) {
+		usageError("Unknown boolean mode %q (available: %s)", booleanMode, strings.Join(ymldiff.BooleanModes, ", "))
+	}
+	var cliTolerances []ToleranceRule
+	for _, spec := range *epsilonFlag {
+		rule, err := parseEpsilon(spec)
+		if err != nil {
+			usageError("%v", err)
+		}
+		if rule.Path == "" {
+			epsilon = rule.Epsilon
+		} else {
+			cliTolerances = append(cliTolerances, rule)
+		}
+	}
+	toleranceRules = append(cliTolerances, toleranceRules...)
+	if *ignoreTimestampsFlag || len(*timestampPatternFlag) > 0 {
+		rules, err := timestampRules(*timestampPatternFlag)
+		if err != nil {
+			usageError("%v", err)
+		}
+		equivalenceRules = append(equivalenceRules, rules...)
+	}
+	patterns, err := compileValuePatterns(*ignoreValueRegexFlag)
+	if err != nil {
+		usageError("%v", err)
+	}
+	ignoredValues = patterns
+	for _, pattern := range *ignoreFlag {
+		ignorePatterns = append(ignorePatterns, normalizePathArgument(pattern))
+	}
+	for _, spec := range *mapFlag {
+		mapping, err := parsePathMapping(spec)
+		if err != nil {
+			usageError("%v", err)
+		}
+		pathMappings = append(pathMappings, mapping)
+	}
+
+	// Identifier keys given on the command line take priority over all others
+	if len(*idKeyFlag) > 0 {
+		applyPreset(Preset{IDKeys: *idKeyFlag})
+	}
+
+	// An explicit matching rule overrides the one of the presets
+	if *matchDocumentsFlag != "" {
+		if !documentMatchers[*matchDocumentsFlag] {
+			usageError("Unknown document matching rule %q", *matchDocumentsFlag)
+		}
+		documentMatch = *matchDocumentsFlag
+	}
 
 	// Disable colors globally if flag is set
 	if noColor {
@@ -605,69 +1236,323 @@ func main() {
 
 	// Get remaining arguments (file names)
 	args := flag.Args()
+
+	// Matrix mode compares a base file against several environment files
+	if len(args) > 0 && args[0] == "matrix" {
+		if len(args) < 3 {
+			usageError("matrix expects a base file and at least one environment file")
+		}
+		if outputFormat != "text" {
+			usageError("matrix only supports text output")
+		}
+		result, err := runMatrix(args[1:])
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(exitCodeFor(resultOutcome(result)))
+	}
+
+	// Self mode compares two subtrees of the same file
+	if len(args) > 0 && args[0] == "self" {
+		if len(args) != 4 {
+			usageError("self expects a file and two paths")
+		}
+		if outputFormat != "text" {
+			usageError("self only supports text output")
+		}
+		result, err := runSelf(args[1], args[2], args[3])
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(exitCodeFor(resultOutcome(result)))
+	}
+
+	// Assert mode checks the changes between two files against an expected patch
+	if len(args) > 0 && args[0] == "assert" {
+		if len(args) != 3 {
+			usageError("assert expects exactly 2 YAML files to compare")
+		}
+		if *expectFlag == "" {
+			usageError("assert requires --expect with the expected changes")
+		}
+		if !containsString(expectModes, *expectModeFlag) {
+			usageError("invalid --expect-mode %q (available: %s)", *expectModeFlag, strings.Join(expectModes, ", "))
+		}
+		passed, err := runAssert(args[1], args[2], *expectFlag, *expectModeFlag)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !passed {
+			os.Exit(exitCodeFor(outcomeAssertionFailed))
+		}
+		os.Exit(0)
+	}
+	if *expectFlag != "" {
+		usageError("--expect requires the assert command")
+	}
+
+	// Log mode prints the changelog of a file across its git history
+	if len(args) > 0 && args[0] == "log" {
+		if len(args) != 2 {
+			usageError("log expects exactly one file")
+		}
+		if outputFormat != "text" {
+			usageError("log only supports text output")
+		}
+		if err := runLog(args[1], *sinceFlag); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+	if *sinceFlag != "" {
+		usageError("--since requires the log command")
+	}
+
+	// Apply mode applies a patch written by --reverse-patch to a file
+	if len(args) > 0 && args[0] == "apply" {
+		if len(args) != 3 {
+			usageError("apply expects a patch file and a target file")
+		}
+		options := applyOptions{DryRun: *dryRunFlag, Confirm: *confirmFlag, Only: *onlyFlag, Skip: *skipFlag}
+		if err := runApply(args[1], args[2], options); err != nil {
+			exitWithError(err)
+		}
+		os.Exit(0)
+	}
+	if *dryRunFlag || *confirmFlag || len(*onlyFlag) > 0 || len(*skipFlag) > 0 {
+		usageError("--dry-run, --confirm, --only and --skip require the apply command")
+	}
+
+	// Docs mode compares two documents of the same file
+	if *docsFlag != "" && len(args) == 1 {
+		if outputFormat != "text" {
+			usageError("--docs only supports text output")
+		}
+		from, to, err := parseDocsSpec(*docsFlag)
+		if err != nil {
+			usageError("%v", err)
+		}
+		result, err := runDocs(args[0], from, to)
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Exit(exitCodeFor(resultOutcome(result)))
+	}
+
+	// Called by git as an external diff program, the inputs are named after
+	// their paths in the repository rather than git's temporary files
+	if diff, ok := parseExternalDiffArgs(args); ok {
+		args = []string{diff.OldFile, diff.NewFile}
+		inputNames["old"] = diff.Path
+		inputNames["new"] = diff.NewPath
+	}
+
 	if len(args) != 2 {
-		fmt.Fprintf(os.Stderr, "Error: Expected exactly 2 YAML files to compare\n\n")
-		printHelp()
-		os.Exit(1)
+		usageError("Expected exactly 2 YAML files to compare")
+	}
+	for _, spec := range *labelFlag {
+		side, name, err := parseInputLabel(spec)
+		if err != nil {
+			usageError("%v", err)
+		}
+		inputNames[side] = name
+	}
+	if *docsFlag != "" {
+		selection, err := parseDocumentSelection(*docsFlag)
+		if err != nil {
+			usageError("%v", err)
+		}
+		selectedDocuments = selection
 	}
 
 	file1 := args[0]
 	file2 := args[1]
 
-	documents1, err := parseYAML(file1)
-	if err != nil {
-		log.Fatalf("Error parsing %s: %v", file1, err)
+	// With --reverse the diff is discarded and only the roll-back patch printed
+	patchOutput := os.Stdout
+	if reversePatchFile == "-" {
+		devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		if err != nil {
+			exitWithError(err)
+		}
+		os.Stdout = devNull
+		color.Output = devNull
 	}
 
-	documents2, err := parseYAML(file2)
+	result, err := compareFiles(file1, file2)
 	if err != nil {
-		log.Fatalf("Error parsing %s: %v", file2, err)
+		exitWithError(err)
+	}
+	locationFiles = [2]string{inputName("old", file1), inputName("new", file2)}
+	if showSource {
+		for i, source := range result.Sources {
+			sourceLines[i] = splitSourceLines(source)
+		}
 	}
+	documents1, documents2 := result.Old, result.New
 
-	// Compare documents by index
-	maxDocs := len(documents1)
-	if len(documents2) > maxDocs {
-		maxDocs = len(documents2)
+	var blame map[int]blameLine
+	if blameMode {
+		blame, err = fileBlame(file2)
+		if err != nil {
+			exitWithError(fmt.Errorf("running git blame on %s: %w", file2, err))
+		}
 	}
 
-	blue := color.New(color.FgBlue)
+	blue := themeColor(theme.Comment)
 
 	// Determine total document count for the header
-	totalDocs := maxDocs
+	totalDocs := result.TotalDocuments
+
+	labels := []string{"Old", "New"}
+	generated := time.Now()
+	if reportMode && outputFormat == "text" {
+		blue.Print(formatReportHeader(labels, []string{file1, file2}, reportOptions, generated))
+		fmt.Println()
+	}
 
-	for i := 0; i < maxDocs; i++ {
-		var doc1Data, doc2Data interface{}
+	// Track the outcome for the exit code and the report footer
+	counts := result.Counts
+	changedDocuments := result.ChangedDocuments
+	var jsonChanges []jsonChange
+	var codeQualityIssues []codeQualityIssue
+	fileAnnotations := newAnnotations()
+	var reverseOperations []patchOperation
+	var explanations []string
+	var stagedChanges []stagedChange
+	stagedDocuments := 0
+	appendedDocuments := 0
+	shownChanges := 0
+	omittedChanges := 0
+
+	// Output document separator with inline comment
+	printSeparator := func(i int) {
+		if noDocComment {
+			blue.Println("---")
+		} else {
+			if hasInputNames() {
+				blue.Printf("--- # YAML Document: %d/%d, %s%s%s\n", i+1, totalDocs, inputName("old", file1), arrow(), inputName("new", file2))
+			} else {
+				blue.Printf("--- # YAML Document: %d/%d\n", i+1, totalDocs)
+			}
+		}
+	}
+
+	for position, document := range result.Documents {
+		resetFormattedValues()
+		i, pair := document.Index, document.Pair
+		doc1Data, doc2Data := document.OldData, document.NewData
+
+		// Masked values are compared as they are but never shown, so only
+		// what is printed is masked
+		shown1Data, shown2Data := doc1Data, doc2Data
+		if masking() {
+			shown1Data, shown2Data = maskValue(doc1Data, ""), maskValue(doc2Data, "")
+		}
+		var doc1Node, doc2Node *yaml.Node
 		var comments []string
 
-		if i < len(documents1) {
-			doc1Data = documents1[i].Data
-			comments = documents1[i].Comments
+		if pair.Old >= 0 {
+			doc1Node = documents1[pair.Old].Node
+			comments = documents1[pair.Old].Comments
 		}
-		if i < len(documents2) {
-			doc2Data = documents2[i].Data
+		if pair.New >= 0 {
+			doc2Node = documents2[pair.New].Node
 			// Merge comments from both documents, preferring doc2
-			if len(documents2[i].Comments) > 0 {
-				comments = documents2[i].Comments
+			if len(documents2[pair.New].Comments) > 0 {
+				comments = documents2[pair.New].Comments
+			}
+		}
+
+		// Explanations include the changes dropped by ignore patterns
+		if explainMode {
+			if explanation := explainDocument(explainPath, doc1Data, doc2Data, diffDocuments(doc1Data, doc2Data), i+1, totalDocs); explanation != "" {
+				explanations = append(explanations, explanation)
+			}
+		}
+		changes := result.documentChanges(position)
+
+		// Skip documents with no changes, only noting documents that moved
+		if len(changes) == 0 {
+			if pair.IsMoved() && outputFormat == "text" && !annotateMode && !explainMode && !interactiveMode {
+				printSeparator(i)
+				blue.Println(formatMoveNote(pair))
+				fmt.Println()
+			}
+			continue
+		}
+
+		// The roll-back patch applies to the new file, so documents are numbered as there
+		if reversePatchFile != "" {
+			target := pair.New + 1
+			if pair.New < 0 {
+				appendedDocuments++
+				target = len(documents2) + appendedDocuments
+			}
+			reverseOperations = append(reverseOperations, newPatchOperations(invertChanges(changes), target)...)
+		}
+
+		// Explanations replace the diff output
+		if explainMode {
+			continue
+		}
+
+		// Interactive staging asks about each change once all documents are compared
+		if interactiveMode {
+			target := pair.Old + 1
+			if pair.Old < 0 {
+				stagedDocuments++
+				target = len(documents1) + stagedDocuments
 			}
+			stagedChanges = append(stagedChanges, newStagedChanges(changes, target)...)
+			continue
 		}
 
-		// Skip if both documents are nil
-		if doc1Data == nil && doc2Data == nil {
+		if masking() {
+			changes = maskChanges(changes)
+		}
+
+		// Annotations are written into the new file once all documents are compared
+		if annotateMode {
+			fileAnnotations.addChanges(changes, doc2Node, doc2Data)
 			continue
 		}
 
-		changes := diffValues(doc1Data, doc2Data, "")
+		if locationsMode || showSource {
+			attachLocations(changes, doc1Node, doc2Node, doc1Data, doc2Data)
+		}
+		if sortOrder == "source" {
+			sortBySource(changes, doc2Node, doc2Data)
+		}
+
+		// Structured output is written once all documents are compared
+		if outputFormat != "text" {
+			jsonChanges = append(jsonChanges, newJSONChanges(changes, i+1)...)
+			if outputFormat == "gitlab" {
+				codeQualityIssues = append(codeQualityIssues, newCodeQualityIssues(changes, i+1, file2, doc2Node, doc2Data)...)
+			}
+			continue
+		}
 
-		// Skip documents with no changes
+		// Past --limit, changes are only counted
+		var omitted int
+		changes, omitted = limitChanges(changes, shownChanges)
+		shownChanges += len(changes)
+		omittedChanges += omitted
 		if len(changes) == 0 {
 			continue
 		}
 
-		// Output document separator with inline comment
-		if noDocComment {
-			blue.Println("---")
-		} else {
-			blue.Printf("--- # YAML Document: %d/%d\n", i+1, totalDocs)
+		if preserveKeyOrder || showSource {
+			attachSourceNodes(changes, doc1Node, doc2Node, doc1Data, doc2Data)
+		}
+		if blameMode {
+			attachBlame(changes, doc2Node, doc2Data, blame)
+		}
+
+		printSeparator(i)
+		if pair.IsMoved() {
+			blue.Println(formatMoveNote(pair))
 		}
 
 		// Output all comments from the document (unless disabled)
@@ -678,8 +1563,108 @@ func main() {
 		}
 
 		// Generate colored diff output showing only changes
-		coloredDiff := generateColoredDiff(changes)
+		coloredDiff := generateContextDiff(changes, shown1Data, shown2Data)
 		fmt.Print(coloredDiff)
 		fmt.Println() // Add blank line between documents
 	}
+
+	if omittedChanges > 0 {
+		blue.Println(formatOmitted(omittedChanges))
+	}
+
+	if interactiveMode {
+		accepted, err := stageChanges(bufio.NewReader(os.Stdin), os.Stderr, stagedChanges)
+		if err != nil {
+			exitWithError(fmt.Errorf("reading answers: %w", err))
+		}
+		var output []byte
+		if emitFormat == "patch" {
+			var patch string
+			patch, err = formatPatch(accepted, inputName("old", file1), inputName("new", file2))
+			output = []byte(patch)
+		} else {
+			output, err = mergeAccepted(file1, accepted)
+		}
+		if err != nil {
+			exitWithError(fmt.Errorf("merging accepted changes: %w", err))
+		}
+		fmt.Print(string(output))
+	}
+
+	if explainMode {
+		if len(explanations) == 0 {
+			fmt.Printf("Path %s not found in either file\n", debugPath(explainPath))
+		}
+		fmt.Print(strings.Join(explanations, "\n"))
+	}
+
+	if annotateMode {
+		if masking() {
+			for _, document := range documents2 {
+				fileAnnotations.addMasked(document.Node, document.Data)
+			}
+		}
+		fmt.Print(generateAnnotated(result.Sources[1], fileAnnotations))
+		if reportMode {
+			fmt.Println()
+		}
+	}
+
+	if reportMode && outputFormat == "text" {
+		blue.Print(formatReportFooter(counts, changedDocuments, totalDocs))
+	}
+
+	if outputFormat != "text" {
+		report := jsonReport{
+			SchemaVersion: reportSchemaVersion,
+			Changes:       jsonChanges,
+			Warnings:      result.Warnings,
+			Summary:       newJSONSummary(counts, changedDocuments, totalDocs),
+		}
+		if reportMode {
+			report.Metadata = newJSONMetadata(labels, []string{file1, file2}, reportOptions, generated)
+		}
+
+		var output string
+		switch outputFormat {
+		case "json":
+			output, err = formatJSONReport(report)
+		case "yaml":
+			output, err = formatYAMLReport(report)
+		case "porcelain":
+			output, err = formatPorcelain(report.Changes)
+		case "unified":
+			output = formatUnified(result.Result, file1, file2)
+		case "gitlab":
+			output, err = formatCodeQuality(codeQualityIssues)
+		case "tap":
+			output = formatTAP(result)
+		case "template":
+			output, err = formatChangeTemplate(changeTemplate, report.Changes)
+		case "brief":
+			output = formatBrief(report.Changes)
+		case "count":
+			output = fmt.Sprintf("%d\n", counts.Total())
+		default:
+			output, err = formatNDJSONReport(report)
+		}
+		if err != nil {
+			exitWithError(fmt.Errorf("encoding report: %w", err))
+		}
+		fmt.Print(output)
+	}
+
+	if reversePatchFile == "-" {
+		content, err := formatPatch(reverseOperations, inputName("new", file2), inputName("old", file1))
+		if err != nil {
+			exitWithError(fmt.Errorf("encoding reverse patch: %w", err))
+		}
+		fmt.Fprint(patchOutput, content)
+	} else if reversePatchFile != "" {
+		if err := writePatch(reversePatchFile, reverseOperations, inputName("new", file2), inputName("old", file1)); err != nil {
+			exitWithError(fmt.Errorf("writing reverse patch %s: %w", reversePatchFile, err))
+		}
+	}
+
+	os.Exit(exitCodeFor(resultOutcome(result.Result)))
 }
@@ -1,13 +1,14 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
 	"github.com/fatih/color"
-	"gopkg.in/yaml.v3"
 )
 
 // TestMultiDocFiles tests support for multi-document YAML files
@@ -483,6 +484,27 @@ func createTempFile(t *testing.T, pattern, content string) string {
 	return tmpfile.Name()
 }
 
+// writeTestFile writes a test file at a given path, for tests needing its name
+func writeTestFile(t *testing.T, path, content string) {
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// discardOutput discards what the test prints to standard output, until it ends
+func discardOutput(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	stdout, colorOutput := os.Stdout, color.Output
+	os.Stdout, color.Output = devNull, devNull
+	t.Cleanup(func() {
+		os.Stdout, color.Output = stdout, colorOutput
+		devNull.Close()
+	})
+}
+
 // Helper function to create temporary binary test files
 func createTempFileBytes(t *testing.T, pattern string, content []byte) string {
 	tmpfile, err := ioutil.TempFile("", pattern)
@@ -540,6 +562,31 @@ func TestFormatValue(t *testing.T) {
 	}
 }
 
+// TestFormatValueMemoized tests that maps and lists are formatted once and
+// that summarized blocks are not formatted at all
+func TestFormatValueMemoized(t *testing.T) {
+	value := normalizeValue(map[string]interface{}{"a": 1, "b": []interface{}{"x", "z"}})
+	first := formatValue(value)
+	if first != "a: 1\nb:\n   - x\n   - z" {
+		t.Errorf("Unexpected formatting %q", first)
+	}
+	if _, ok := formattedValues[valueIdentity{reflect.Map, reflect.ValueOf(value).Pointer(), 2}]; !ok {
+		t.Errorf("Expected the formatted map to be memoized")
+	}
+	if second := formatValue(value); second != first {
+		t.Errorf("Expected memoized formatting %q, got %q", first, second)
+	}
+
+	formatted := false
+	summary := summarizeBlock(value, func() string {
+		formatted = true
+		return formatValue(value)
+	})
+	if summary != "<map, 2 keys>" || formatted {
+		t.Errorf("Expected an unformatted summary, got %q (formatted: %v)", summary, formatted)
+	}
+}
+
 // TestDiffValuesWithNil tests diffing when one or both values are nil
 func TestDiffValuesWithNil(t *testing.T) {
 	tests := []struct {
@@ -644,33 +691,6 @@ func TestIsSliceOfDictsWithIds(t *testing.T) {
 	}
 }
 
-// TestExtractComments tests comment extraction from YAML nodes
-func TestExtractComments(t *testing.T) {
-	yamlContent := `# Header comment
-name: John # inline
-age: 30
-# Footer comment`
-
-	var node yaml.Node
-	err := yaml.Unmarshal([]byte(yamlContent), &node)
-	if err != nil {
-		t.Fatalf("Failed to unmarshal YAML: %v", err)
-	}
-
-	comments := extractComments(&node)
-
-	if len(comments) == 0 {
-		t.Error("Expected to extract comments, but got none")
-	}
-
-	// Check that extracted comments have # prefix
-	for _, comment := range comments {
-		if !strings.HasPrefix(comment, "#") {
-			t.Errorf("Expected comment to start with #, got: %s", comment)
-		}
-	}
-}
-
 // Benchmark tests
 func BenchmarkParseYAML(b *testing.B) {
 	content := `
@@ -982,3 +1002,674 @@ func TestCommentsInOutput(t *testing.T) {
 		t.Error("Expected comments to be hidden when disableComments is true")
 	}
 }
+
+// TestRawMode tests that raw mode keeps list order and compares lists by position
+func TestRawMode(t *testing.T) {
+	originalRawMode := rawMode
+	defer func() { rawMode = originalRawMode }()
+
+	file1Content := `
+items:
+  - apple
+  - banana
+users:
+  - name: Alice
+  - name: Bob
+`
+	file2Content := `
+items:
+  - banana
+  - apple
+users:
+  - name: Bob
+  - name: Alice
+`
+
+	file1 := createTempFile(t, "raw1.yaml", file1Content)
+	defer os.Remove(file1)
+	file2 := createTempFile(t, "raw2.yaml", file2Content)
+	defer os.Remove(file2)
+
+	for _, tt := range []struct {
+		raw      bool
+		expected int
+	}{
+		{raw: false, expected: 0},
+		{raw: true, expected: 4},
+	} {
+		rawMode = tt.raw
+
+		docs1, err := parseYAML(file1)
+		if err != nil {
+			t.Fatalf("Failed to parse file1: %v", err)
+		}
+		docs2, err := parseYAML(file2)
+		if err != nil {
+			t.Fatalf("Failed to parse file2: %v", err)
+		}
+
+		changes := diffValues(docs1[0].Data, docs2[0].Data, "")
+		if len(changes) != tt.expected {
+			t.Errorf("raw=%v: expected %d changes, got %d", tt.raw, tt.expected, len(changes))
+		}
+	}
+}
+
+// TestCollapseBlocks tests summarizing added and removed subtrees
+func TestCollapseBlocks(t *testing.T) {
+	originalCollapseBlocks := collapseBlocks
+	originalExpandNewBlocks := expandNewBlocks
+	defer func() {
+		collapseBlocks = originalCollapseBlocks
+		expandNewBlocks = originalExpandNewBlocks
+	}()
+
+	changes := []Change{
+		{Type: Addition, Path: ".spec.sidecars[istio]", NewValue: map[interface{}]interface{}{"image": "proxy", "port": 15001}},
+		{Type: Deletion, Path: ".spec.volumes", OldValue: []interface{}{"cache"}},
+		{Type: Addition, Path: ".spec.paused", NewValue: true},
+	}
+
+	collapseBlocks = true
+	expandNewBlocks = false
+	output := generateColoredDiff(changes)
+	if !strings.Contains(output, ".spec.sidecars[istio]: <map, 2 keys>") {
+		t.Errorf("Expected added map to be summarized, got:\n%s", output)
+	}
+	if !strings.Contains(output, ".spec.volumes: <list, 1 item>") {
+		t.Errorf("Expected removed list to be summarized, got:\n%s", output)
+	}
+	if !strings.Contains(output, ".spec.paused: true") {
+		t.Errorf("Expected scalar values to be shown as is, got:\n%s", output)
+	}
+
+	expandNewBlocks = true
+	output = generateColoredDiff(changes)
+	if !strings.Contains(output, "image: proxy") {
+		t.Errorf("Expected added map to be expanded, got:\n%s", output)
+	}
+	if !strings.Contains(output, "<list, 1 item>") {
+		t.Errorf("Expected removed list to stay summarized, got:\n%s", output)
+	}
+}
+
+// TestDiffDocumentsSubtree tests restricting the comparison to a subtree with --path
+func TestDiffDocumentsSubtree(t *testing.T) {
+	originalSubtreePath := subtreePath
+	defer func() { subtreePath = originalSubtreePath }()
+	subtreePath = normalizePathArgument("spec.template")
+
+	oldDoc := normalizeValue(map[string]interface{}{
+		"metadata": map[string]interface{}{"generation": 1},
+		"spec":     map[string]interface{}{"replicas": 1, "template": map[string]interface{}{"image": "app:1"}},
+	})
+	newDoc := normalizeValue(map[string]interface{}{
+		"metadata": map[string]interface{}{"generation": 2},
+		"spec":     map[string]interface{}{"replicas": 3, "template": map[string]interface{}{"image": "app:2"}},
+	})
+
+	changes := diffDocuments(oldDoc, newDoc)
+	if len(changes) != 1 || changes[0].Path != ".spec.template.image" {
+		t.Errorf("Expected only .spec.template.image to change, got %+v", changes)
+	}
+
+	// A subtree missing from one side is reported as added or removed as a whole
+	delete(oldDoc.(map[interface{}]interface{})["spec"].(map[interface{}]interface{}), "template")
+	changes = diffDocuments(oldDoc, newDoc)
+	if len(changes) != 1 || changes[0].Type != Addition || changes[0].Path != ".spec.template" {
+		t.Errorf("Expected .spec.template to be added, got %+v", changes)
+	}
+}
+
+// TestIDKeyPriority tests that identifier fields are tried in priority order and mixed keys are warned about
+func TestIDKeyPriority(t *testing.T) {
+	originalIDKeys, originalWarningOutput := idKeys, warningOutput
+	defer func() { idKeys, warningOutput = originalIDKeys, originalWarningOutput }()
+	var output strings.Builder
+	warningOutput = &output
+	warnings = nil
+
+	item := map[interface{}]interface{}{"id": "7", "name": "web"}
+	idKeys = []string{"name", "id"}
+	if id, _ := itemIdentifier(item); id != "web" {
+		t.Errorf("Expected name to take priority, got %q", id)
+	}
+	idKeys = []string{"id", "name"}
+	if id, _ := itemIdentifier(item); id != "7" {
+		t.Errorf("Expected id to take priority, got %q", id)
+	}
+
+	mixed := []interface{}{
+		map[interface{}]interface{}{"id": "1", "value": "a"},
+		map[interface{}]interface{}{"name": "web", "value": "b"},
+	}
+	diffValues(map[interface{}]interface{}{"items": mixed}, map[interface{}]interface{}{"items": mixed[:1]}, "")
+	expected := "Warning: .items: list items are identified by different fields (id, name); set their priority with --id-key\n"
+	if output.String() != expected {
+		t.Errorf("Expected warning %q, got %q", expected, output.String())
+	}
+}
+
+// TestFormatValueReset tests that values changed in place are formatted anew
+// once the memoized forms are reset for the next document
+func TestFormatValueReset(t *testing.T) {
+	value := map[interface{}]interface{}{"a": 1}
+	if formatted := formatValue(value); formatted != "a: 1" {
+		t.Fatalf("Unexpected formatting %q", formatted)
+	}
+	value["a"] = 2
+	resetFormattedValues()
+	if formatted := formatValue(value); formatted != "a: 2" {
+		t.Errorf("Expected the changed value to be formatted anew, got %q", formatted)
+	}
+}
+
+// TestMarkers tests formatting changes with other marker styles and overrides
+func TestMarkers(t *testing.T) {
+	originalMarkers, originalNoColor := markers, color.NoColor
+	defer func() { markers, color.NoColor = originalMarkers, originalNoColor }()
+	color.NoColor = true
+
+	var err error
+	markers, err = resolveMarkers("words", Markers{Addition: ">>", Arrow: "=>"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, tt := range []struct {
+		change   Change
+		expected string
+	}{
+		{Change{Type: Addition, Path: ".a", NewValue: 1}, ">> .a: 1\n"},
+		{Change{Type: Deletion, Path: ".b", OldValue: 2}, "removed .b: 2\n"},
+		{Change{Type: Modification, Path: ".c", OldValue: "x", NewValue: "y"}, "changed .c: x => y\n"},
+		{Change{Type: Move, Path: ".d[app]", OldValue: 0, NewValue: 1}, "moved .d[app]: position 0 => 1\n"},
+	} {
+		if result := formatChange(tt.change, tt.change.Path, ""); result != tt.expected {
+			t.Errorf("Expected %q, got %q", tt.expected, result)
+		}
+	}
+
+	if _, err := resolveMarkers("emoji", Markers{}); err == nil {
+		t.Errorf("Expected an error for an unknown marker style")
+	}
+}
+
+// TestColorizeBlock tests that keys and scalars of formatted values are colored
+// with the theme, leaving block scalar text alone
+func TestColorizeBlock(t *testing.T) {
+	originalNoColor, originalTheme := color.NoColor, theme
+	defer func() { color.NoColor, theme = originalNoColor, originalTheme }()
+	color.NoColor = false
+	theme = defaultTheme
+	theme.Scalar = "magenta"
+
+	formatted := "name: x\nlist:\n   - key: v\n   - one\ntext: |\n   a: b\nlast: 1"
+	expected := strings.Join([]string{
+		"\x1b[36mname\x1b[0m: \x1b[35mx\x1b[0m",
+		"\x1b[36mlist\x1b[0m:",
+		"   - \x1b[36mkey\x1b[0m: \x1b[35mv\x1b[0m",
+		"   - \x1b[35mone\x1b[0m",
+		"\x1b[36mtext\x1b[0m: |",
+		"   a: b",
+		"\x1b[36mlast\x1b[0m: \x1b[35m1\x1b[0m",
+	}, "\n")
+	if result := colorizeBlock(formatted); result != expected {
+		t.Errorf("Expected:\n%q\ngot:\n%q", expected, result)
+	}
+}
+
+// TestMergeTheme tests overriding theme colors and rejecting unknown ones
+func TestMergeTheme(t *testing.T) {
+	merged, err := mergeTheme(defaultTheme, Theme{Key: "bold hi-blue", Scalar: "none"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if merged.Key != "bold hi-blue" || merged.Addition != defaultTheme.Addition {
+		t.Errorf("Unexpected theme %+v", merged)
+	}
+
+	if _, err := mergeTheme(defaultTheme, Theme{Deletion: "crimson"}); err == nil || !strings.Contains(err.Error(), `theme deletion: unknown color "crimson"`) {
+		t.Errorf("Expected an unknown color error, got %v", err)
+	}
+}
+
+// TestSplitWords tests splitting strings into words, whitespace and punctuation
+func TestSplitWords(t *testing.T) {
+	tests := map[string][]string{
+		"":                     nil,
+		"image: nginx:1.25":    {"image", ":", " ", "nginx", ":", "1", ".", "25"},
+		"--max-old  space_ü":   {"-", "-", "max", "-", "old", "  ", "space_ü"},
+		"line one\nline  two ": {"line", " ", "one", "\n", "line", "  ", "two", " "},
+	}
+	for input, expected := range tests {
+		got := splitWords(input)
+		if strings.Join(got, "|") != strings.Join(expected, "|") || len(got) != len(expected) {
+			t.Errorf("splitWords(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+// TestColorStringDiff tests that only the changed words, or characters of similar words, are colored
+func TestColorStringDiff(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = false
+
+	// Longer strings are diffed word by word
+	prefix := strings.Repeat("a long description ", 4)
+	oldStr, newStr := colorStringDiff(prefix+"of the nightly backup job", prefix+"of the weekly backup job now")
+	red := themeColor(theme.Deletion)
+	green := themeColor(theme.Addition)
+	if expected := prefix + "of the " + red.Sprint("nightly") + " backup job"; oldStr != expected {
+		t.Errorf("Unexpected old string %q, expected %q", oldStr, expected)
+	}
+	if expected := prefix + "of the " + green.Sprint("weekly") + " backup job" + green.Sprint(" now"); newStr != expected {
+		t.Errorf("Unexpected new string %q, expected %q", newStr, expected)
+	}
+
+	// Short strings are diffed word by word too
+	oldStr, newStr = colorStringDiff("postgres://db-1:5432/app", "postgres://db-2:5432/app")
+	if expected := "postgres://db-" + red.Sprint("1") + ":5432/app"; oldStr != expected {
+		t.Errorf("Unexpected old string %q, expected %q", oldStr, expected)
+	}
+	if expected := "postgres://db-" + green.Sprint("2") + ":5432/app"; newStr != expected {
+		t.Errorf("Unexpected new string %q, expected %q", newStr, expected)
+	}
+
+	// Inside a word replaced by a similar one, the changed characters are highlighted
+	oldStr, newStr = colorStringDiff("image v1.25.3", "image v1.26.3")
+	if expected := "image v1." + red.Sprint("2") + themeColor(theme.InlineDeletion).Sprint("5") + ".3"; oldStr != expected {
+		t.Errorf("Unexpected old string %q, expected %q", oldStr, expected)
+	}
+	if expected := "image v1." + green.Sprint("2") + themeColor(theme.InlineAddition).Sprint("6") + ".3"; newStr != expected {
+		t.Errorf("Unexpected new string %q, expected %q", newStr, expected)
+	}
+
+	color.NoColor = true
+	if oldStr, newStr := colorStringDiff("a b c", "a x c"); oldStr != "a b c" || newStr != "a x c" {
+		t.Errorf("Expected the strings unchanged without color, got %q and %q", oldStr, newStr)
+	}
+}
+
+// TestGenerateContextDiff tests showing unchanged sibling keys around changes
+func TestGenerateContextDiff(t *testing.T) {
+	defer func(n int, noColor bool) { contextSiblings, color.NoColor = n, noColor }(contextSiblings, color.NoColor)
+	color.NoColor = true
+	contextSiblings = 1
+
+	oldDoc := map[interface{}]interface{}{
+		"a": 1, "b": 2, "c": 3, "d": 4, "e": 5,
+		"spec": map[interface{}]interface{}{"replicas": 1, "image": "app:1", "labels": map[interface{}]interface{}{"app": "web"}},
+	}
+	newDoc := map[interface{}]interface{}{
+		"a": 1, "b": 20, "c": 30, "d": 4, "e": 5,
+		"spec": map[interface{}]interface{}{"replicas": 2, "image": "app:1", "labels": map[interface{}]interface{}{"app": "web"}},
+	}
+	changes := diffValues(oldDoc, newDoc, "")
+
+	expected := strings.Join([]string{
+		"  .a: 1",
+		"~ .b: 2 → 20 (+900%)",
+		"~ .c: 3 → 30 (+900%)",
+		"  .d: 4",
+		"  .spec.labels: <map, 1 key>",
+		"~ .spec.replicas: 1 → 2 (+100%)",
+		"",
+	}, "\n")
+	if got := generateContextDiff(changes, oldDoc, newDoc); got != expected {
+		t.Errorf("generateContextDiff =\n%s\nexpected\n%s", got, expected)
+	}
+
+	contextSiblings = 0
+	if got, plain := generateContextDiff(changes, oldDoc, newDoc), generateColoredDiff(changes); got != plain {
+		t.Errorf("Expected no context with -C 0, got\n%s", got)
+	}
+}
+
+// TestFormatStringLinesDiff tests diffing the lines of multi-line strings
+func TestFormatStringLinesDiff(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	var oldLines, newLines []string
+	for i := 1; i <= 12; i++ {
+		oldLines = append(oldLines, fmt.Sprintf("line %d", i))
+		newLines = append(newLines, fmt.Sprintf("line %d", i))
+	}
+	newLines[1] = "line two"
+	newLines = append(newLines, "line 13")
+
+	output := formatStringLinesDiff(strings.Join(oldLines, "\n")+"\n", strings.Join(newLines, "\n")+"\n", "")
+	expected := `     line 1
+-    line 2
++    line two
+     line 3
+     line 4
+     line 5
+     …
+     line 10
+     line 11
+     line 12
++    line 13
+`
+	if output != expected {
+		t.Errorf("Unexpected line diff:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+// TestMultilineStrings tests which modifications are shown as line diffs
+func TestMultilineStrings(t *testing.T) {
+	tests := []struct {
+		old, new interface{}
+		expected bool
+	}{
+		{"a\nb\n", "a\nc\n", true},
+		{"one line", "a\nb", true},
+		{"a\nb\n", "a\nb", false}, // only the final line break changed
+		{"a\n", "b\n", false},
+		{"a\nb", 3, false},
+	}
+	for _, test := range tests {
+		_, _, ok := multilineStrings(Change{Type: Modification, OldValue: test.old, NewValue: test.new})
+		if ok != test.expected {
+			t.Errorf("multilineStrings(%q, %q) = %v, expected %v", test.old, test.new, ok, test.expected)
+		}
+	}
+}
+
+// TestFormatRollup tests the counts of nested changes shown at each path above them
+func TestFormatRollup(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	changes := []Change{
+		{Type: Modification, Path: ".kind", OldValue: "A", NewValue: "B"},
+		{Type: Addition, Path: ".spec.template.port", NewValue: 80},
+		{Type: Modification, Path: ".spec.template.env[1]", OldValue: 2, NewValue: 3},
+		{Type: Deletion, Path: ".spec.items", OldValue: []interface{}{"x"}},
+		{Type: Modification, Path: ".spec.templates", OldValue: 1, NewValue: 2},
+		{Type: Addition, Path: ".spec[\"a.b\"].c", NewValue: true},
+	}
+
+	output := formatRollup(changes)
+	expected := `(document): + 2 - 1 ~ 3
+  .spec: + 2 - 1 ~ 2
+    .spec.template: + 1 ~ 1
+      .spec.template.env: ~ 1
+    .spec["a.b"]: + 1
+`
+	if output != expected {
+		t.Errorf("Unexpected rollup:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+// TestPathSegments tests splitting paths into their keys and list items
+func TestPathSegments(t *testing.T) {
+	tests := map[string]int{
+		"":                 0,
+		".spec":            1,
+		".spec.items[2]":   3,
+		".spec[\"a.b\"].c": 3,
+		".data[0][1].name": 4,
+	}
+	for path, expected := range tests {
+		if got := pathSegments(path); len(got) != expected {
+			t.Errorf("pathSegments(%q) = %q, expected %d segments", path, got, expected)
+		}
+	}
+}
+
+// TestSplitLastSegment tests splitting paths into parent and last segment
+func TestSplitLastSegment(t *testing.T) {
+	tests := []struct {
+		path    string
+		parent  string
+		segment string
+	}{
+		{".spec.replicas", ".spec", ".replicas"},
+		{".spec.containers[app]", ".spec.containers", "[app]"},
+		{".items[0].name", ".items[0]", ".name"},
+		{".name", "", ".name"},
+		{".data.app|.server", ".data.app|", ".server"},
+		{".data.app|", ".data.app", "|"},
+		{"", "", ""},
+	}
+
+	for _, tt := range tests {
+		parent, segment := splitLastSegment(tt.path)
+		if parent != tt.parent || segment != tt.segment {
+			t.Errorf("splitLastSegment(%q) = (%q, %q), expected (%q, %q)", tt.path, parent, segment, tt.parent, tt.segment)
+		}
+	}
+}
+
+// TestGroupByParent tests that changes are grouped under their parent path
+func TestGroupByParent(t *testing.T) {
+	originalGroupByParent := groupByParent
+	originalNoColor := color.NoColor
+	defer func() {
+		groupByParent = originalGroupByParent
+		color.NoColor = originalNoColor
+	}()
+	color.NoColor = true
+	groupByParent = true
+
+	changes := []Change{
+		{Type: Modification, Path: ".spec.containers[app].image", OldValue: "app:1", NewValue: "app:2"},
+		{Type: Addition, Path: ".spec.containers[app].args", NewValue: "--verbose"},
+		{Type: Modification, Path: ".spec.replicas", OldValue: 2, NewValue: 3},
+		{Type: Deletion, Path: ".version", OldValue: "1"},
+	}
+
+	output := generateColoredDiff(changes)
+	expected := `- .version: 1
+.spec:
+  ~ replicas: 2 → 3 (+50%)
+.spec.containers[app]:
+  + args: --verbose
+  ~ image: app:1 → app:2
+`
+	if output != expected {
+		t.Errorf("Unexpected grouped output:\n%s\nexpected:\n%s", output, expected)
+	}
+	if strings.Count(output, ".spec.containers[app]") != 1 {
+		t.Error("Expected the parent path to be printed once")
+	}
+}
+
+// TestGroupedChangeCounts tests that group headers tell how many keys or items of the parent changed
+func TestGroupedChangeCounts(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	oldDoc := normalizeValue(map[string]interface{}{
+		"spec":  map[string]interface{}{"replicas": 2, "paused": false, "strategy": "Recreate", "revision": 7},
+		"ports": []interface{}{80, 443},
+	})
+	newDoc := normalizeValue(map[string]interface{}{
+		"spec":  map[string]interface{}{"replicas": 3, "strategy": "Recreate", "revision": 7, "minReady": 5},
+		"ports": []interface{}{80, 443, 8080},
+	})
+	changes := []Change{
+		{Type: Addition, Path: ".ports[2]", NewValue: 8080},
+		{Type: Addition, Path: ".spec.minReady", NewValue: 5},
+		{Type: Deletion, Path: ".spec.paused", OldValue: false},
+		{Type: Modification, Path: ".spec.replicas", OldValue: 2, NewValue: 3},
+	}
+
+	output := formatGroupedChanges(changes, oldDoc, newDoc)
+	expected := `.ports: (1 of 3 items changed)
+  + [2]: 8080
+.spec: (3 of 5 keys changed)
+  + minReady: 5
+  - paused: false
+  ~ replicas: 2 → 3 (+50%)
+`
+	if output != expected {
+		t.Errorf("Unexpected grouped output:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+// TestFormatPercentChange tests formatting the relative change of numbers
+func TestFormatPercentChange(t *testing.T) {
+	tests := []struct {
+		old, new interface{}
+		expected string
+	}{
+		{4, 12, "+200%"},
+		{8, 4, "-50%"},
+		{3, 4, "+33.3%"},
+		{-2, -1, "+50%"},
+		{0.5, 0.25, "-50%"},
+		{1000, 1000.1, "+0.01%"},
+		{0, 5, ""},
+		{"4", "12", ""},
+		{4, "12", ""},
+	}
+	for _, tt := range tests {
+		if got := formatPercentChange(tt.old, tt.new); got != tt.expected {
+			t.Errorf("formatPercentChange(%v, %v) = %q, expected %q", tt.old, tt.new, got, tt.expected)
+		}
+	}
+}
+
+// TestSortBySource tests ordering changes by their line in the new file
+func TestSortBySource(t *testing.T) {
+	newNode, newData := parseNode(t, "kind: B\nspec:\n  replicas: 5\n  image: b\nzone: eu\n")
+	changes := []Change{
+		{Type: Addition, Path: ".zone", NewValue: "eu"},
+		{Type: Modification, Path: ".spec.image", OldValue: "a", NewValue: "b"},
+		{Type: Deletion, Path: ".spec.paused", OldValue: true},
+		{Type: Modification, Path: ".kind", OldValue: "A", NewValue: "B"},
+		{Type: Modification, Path: ".spec.replicas", OldValue: 3, NewValue: 5},
+		{Type: Deletion, Path: ".legacy.flag", OldValue: true},
+	}
+	sortBySource(changes, newNode, newData)
+
+	// Removed entries sit at the line of their closest remaining parent
+	expected := []string{".kind", ".legacy.flag", ".spec.paused", ".spec.replicas", ".spec.image", ".zone"}
+	for i, change := range changes {
+		if change.Path != expected[i] {
+			t.Errorf("Change %d: expected %s, got %s", i, expected[i], change.Path)
+		}
+	}
+}
+
+// TestSortChanges tests the orders of --sort, keeping source order once
+// changes are sorted by source
+func TestSortChanges(t *testing.T) {
+	defer func(order string) { sortOrder = order }(sortOrder)
+
+	tests := []struct {
+		order    string
+		changes  []Change
+		expected []string
+	}{
+		{"source", []Change{{Path: ".b"}, {Path: ".a"}}, []string{".b", ".a"}},
+		{"none", []Change{{Path: ".b"}, {Path: ".a"}}, []string{".b", ".a"}},
+		{"path", []Change{{Path: ".b"}, {Path: ".a"}}, []string{".a", ".b"}},
+		{"type", []Change{
+			{Type: Modification, Path: ".a"},
+			{Type: Addition, Path: ".d"},
+			{Type: Deletion, Path: ".c"},
+			{Type: Addition, Path: ".b"},
+			{Type: Move, Path: ".e[x]"},
+		}, []string{".c", ".b", ".d", ".a", ".e[x]"}},
+	}
+	for _, tt := range tests {
+		sortOrder = tt.order
+		sortChanges(tt.changes)
+		var paths []string
+		for _, change := range tt.changes {
+			paths = append(paths, change.Path)
+		}
+		if strings.Join(paths, " ") != strings.Join(tt.expected, " ") {
+			t.Errorf("--sort %s: expected %v, got %v", tt.order, tt.expected, paths)
+		}
+	}
+}
+
+// TestLimitChanges tests keeping the changes within --limit across documents
+func TestLimitChanges(t *testing.T) {
+	defer func(limit int) { changeLimit = limit }(changeLimit)
+	changes := []Change{
+		{Type: Addition, Path: ".c"},
+		{Type: Addition, Path: ".a"},
+		{Type: Addition, Path: ".b"},
+	}
+
+	changeLimit = -1
+	if shown, omitted := limitChanges(changes, 10); len(shown) != 3 || omitted != 0 {
+		t.Errorf("Expected all changes without a limit, got %v and %d omitted", shown, omitted)
+	}
+
+	changeLimit = 4
+	shown, omitted := limitChanges(changes, 2)
+	if len(shown) != 2 || shown[0].Path != ".a" || shown[1].Path != ".b" || omitted != 1 {
+		t.Errorf("Expected .a and .b with 1 omitted, got %v and %d omitted", shown, omitted)
+	}
+	if shown, omitted := limitChanges(changes, 5); len(shown) != 0 || omitted != 3 {
+		t.Errorf("Expected no changes past the limit, got %v and %d omitted", shown, omitted)
+	}
+}
+
+// TestFormatOmitted tests the note on changes left out by --limit
+func TestFormatOmitted(t *testing.T) {
+	tests := map[int]string{
+		1:       "… and 1 more change",
+		999:     "… and 999 more changes",
+		3214:    "… and 3,214 more changes",
+		1234567: "… and 1,234,567 more changes",
+	}
+	for omitted, expected := range tests {
+		if got := formatOmitted(omitted); got != expected {
+			t.Errorf("formatOmitted(%d) = %q, expected %q", omitted, got, expected)
+		}
+	}
+}
+
+// TestLastLine tests finding the last source line of nested and block values
+func TestLastLine(t *testing.T) {
+	node, _ := parseNode(t, "a: 1\nb:\n  c: [1,\n    2]\n  d: |\n    x\n    y\ne: 2\n")
+	root := resolveNode(node)
+	tests := map[string]int{"a": 1, "b": 7, "e": 8}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i].Value, root.Content[i+1]
+		if got := lastLine(value); got != tests[key] {
+			t.Errorf("lastLine(%s) = %d, expected %d", key, got, tests[key])
+		}
+	}
+}
+
+// TestFormatSourceSnippet tests printing the lines of a change as written in both files
+func TestFormatSourceSnippet(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+	defer func(files [2]string, lines [2][]string) { locationFiles, sourceLines = files, lines }(locationFiles, sourceLines)
+	locationFiles = [2]string{"old.yaml", "new.yaml"}
+
+	oldSource := "spec:\n  replicas: 3  # low\n  ports:\n    - 80\n"
+	newSource := "spec:\n  ports: [80, 443]\n  replicas: 3\n"
+	sourceLines = [2][]string{splitSourceLines([]byte(oldSource)), splitSourceLines([]byte(newSource))}
+	oldNode, oldData := parseNode(t, oldSource)
+	newNode, newData := parseNode(t, newSource)
+
+	changes := []Change{{Type: Modification, Path: ".spec.ports", OldValue: []interface{}{80}, NewValue: []interface{}{80, 443}}}
+	attachLocations(changes, oldNode, newNode, oldData, newData)
+	attachSourceNodes(changes, oldNode, newNode, oldData, newData)
+
+	expected := `  --- old.yaml
+  3 |   ports:
+  4 |     - 80
+  +++ new.yaml
+  2 |   ports: [80, 443]
+`
+	if got := formatSourceSnippet(changes[0], "  "); got != expected {
+		t.Errorf("Unexpected snippet:\n%s\nexpected:\n%s", got, expected)
+	}
+}
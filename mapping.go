@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// pathMapping compares the value at a path of the old file against the value
+// at another path of the new file, set with --map
+type pathMapping struct {
+	From string
+	To   string
+}
+
+// pathMappings are the active path mappings
+var pathMappings []pathMapping
+
+// parsePathMapping parses a mapping written as OLD=>NEW
+func parsePathMapping(spec string) (pathMapping, error) {
+	from, to, found := strings.Cut(spec, "=>")
+	mapping := pathMapping{
+		From: normalizePathArgument(strings.TrimSpace(from)),
+		To:   normalizePathArgument(strings.TrimSpace(to)),
+	}
+	if !found || mapping.From == "" || mapping.To == "" {
+		return pathMapping{}, fmt.Errorf("invalid --map %q, expected OLD=>NEW such as '.db.host=>.database.hostname'", spec)
+	}
+	return mapping, nil
+}
+
+// applyPathMappings moves the values at the old paths of the mappings to their
+// new paths, so they are compared with the new file under the new names. The
+// document is copied along the changed paths and left as is.
+func applyPathMappings(doc interface{}) interface{} {
+	for _, mapping := range pathMappings {
+		value, ok := lookupPath(doc, mapping.From)
+		if !ok {
+			continue
+		}
+		debugf("%s: mapped to %s", debugPath(mapping.From), debugPath(mapping.To))
+		doc = replacePath(doc, mapping.From, nil, false)
+		doc = replacePath(doc, mapping.To, value, true)
+	}
+	return doc
+}
+
+// replacePath returns a copy of a document with the value at a path set, or
+// removed when keep is false. Missing maps along the path are created.
+func replacePath(doc interface{}, path string, value interface{}, keep bool) interface{} {
+	if path == "" {
+		return value
+	}
+	parentPath, segment := splitLastSegment(path)
+	parent, _ := lookupPath(doc, parentPath)
+
+	var updated interface{}
+	if strings.HasPrefix(segment, "[") {
+		list, ok := parent.([]interface{})
+		if !ok {
+			return doc
+		}
+		index := listIndex(list, segment[1:len(segment)-1])
+		if index < 0 {
+			return doc
+		}
+		copied := append([]interface{}{}, list[:index]...)
+		if keep {
+			copied = append(copied, value)
+		}
+		updated = append(copied, list[index+1:]...)
+	} else {
+		name := strings.TrimPrefix(segment, ".")
+		copied := make(map[interface{}]interface{})
+		var key interface{} = name
+		if m, ok := parent.(map[interface{}]interface{}); ok {
+			for k, v := range m {
+				if fmt.Sprintf("%v", k) == name {
+					key = k
+					continue
+				}
+				copied[k] = v
+			}
+		}
+		if keep {
+			copied[key] = value
+		}
+		updated = copied
+	}
+	return replacePath(doc, parentPath, updated, true)
+}
+
+// listIndex finds a list item by its identifier or, in lists without
+// identifiers, by its position
+func listIndex(list []interface{}, segment string) int {
+	if !rawMode && isSliceOfDictsWithIds(list) {
+		for i, item := range list {
+			if id, ok := itemIdentifier(item); ok && id == segment {
+				return i
+			}
+		}
+		return -1
+	}
+	index, err := strconv.Atoi(segment)
+	if err != nil || index < 0 || index >= len(list) {
+		return -1
+	}
+	return index
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Markers holds the prefixes marking each type of change and the arrow
+// between the old and new values
+type Markers struct {
+	Addition     string `yaml:"addition"`
+	Deletion     string `yaml:"deletion"`
+	Modification string `yaml:"modification"`
+	Move         string `yaml:"move"`
+	Arrow        string `yaml:"arrow"`
+}
+
+// markerStyles holds the sets of markers selectable with --markers
+var markerStyles = map[string]Markers{
+	"symbols": {Addition: "+", Deletion: "-", Modification: "~", Move: "↕", Arrow: "→"},
+	"ascii":   {Addition: "+", Deletion: "-", Modification: "~", Move: "^", Arrow: "->"},
+	"words":   {Addition: "added", Deletion: "removed", Modification: "changed", Move: "moved", Arrow: "to"},
+}
+
+// markers are the active change markers
+var markers = markerStyles["symbols"]
+
+// markerStyleNames returns the names of all marker styles in sorted order
+func markerStyleNames() []string {
+	names := make([]string, 0, len(markerStyles))
+	for name := range markerStyles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolveMarkers returns the markers of a style with the entries set in
+// override replaced
+func resolveMarkers(style string, override Markers) (Markers, error) {
+	base, ok := markerStyles[style]
+	if !ok {
+		return Markers{}, fmt.Errorf("unknown marker style %q (available: %s)", style, strings.Join(markerStyleNames(), ", "))
+	}
+	for _, entry := range []struct {
+		base     *string
+		override string
+	}{
+		{&base.Addition, override.Addition},
+		{&base.Deletion, override.Deletion},
+		{&base.Modification, override.Modification},
+		{&base.Move, override.Move},
+		{&base.Arrow, override.Arrow},
+	} {
+		if entry.override != "" {
+			*entry.base = entry.override
+		}
+	}
+	return base, nil
+}
+
+// markerFor returns the marker of a change type, followed by a space
+func markerFor(changeType ChangeType) string {
+	switch changeType {
+	case Addition:
+		return markers.Addition + " "
+	case Deletion:
+		return markers.Deletion + " "
+	case Move:
+		return markers.Move + " "
+	default:
+		return markers.Modification + " "
+	}
+}
+
+// arrow returns the arrow between an old and a new value, surrounded by spaces
+func arrow() string {
+	return " " + markers.Arrow + " "
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maskText replaces masked values in the output
+const maskText = "*****"
+
+// maskPatterns are the paths whose values are masked in the output, set with
+// --mask. They are still compared, so changes to them are reported.
+var maskPatterns []string
+
+// masking checks if any values are masked in the output
+func masking() bool {
+	return len(maskPatterns) > 0 || redactSecrets
+}
+
+// shouldMask checks if the value at a path is masked: it matches one of
+// maskPatterns or, with redactSecrets, looks like a secret
+func shouldMask(path string, value interface{}) bool {
+	if path == "" || value == nil {
+		return false
+	}
+	if matchAnyPath(maskPatterns, path) {
+		return true
+	}
+	return redactSecrets && looksSecret(path, value)
+}
+
+// maskValue returns a copy of a value with everything that should be masked,
+// scalars and whole maps and lists alike, replaced by maskText
+func maskValue(value interface{}, path string) interface{} {
+	if shouldMask(path, value) {
+		return maskText
+	}
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for key, child := range v {
+			result[key] = maskValue(child, path+"."+fmt.Sprintf("%v", key))
+		}
+		return result
+	case []interface{}:
+		keyed := !rawMode && isSliceOfDictsWithIds(v)
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			if id, ok := itemIdentifier(item); keyed && ok {
+				itemPath = path + "[" + id + "]"
+			}
+			result[i] = maskValue(item, itemPath)
+		}
+		return result
+	}
+	return value
+}
+
+// maskChanges returns a copy of changes with the old and new values, and the
+// values nested in them, that should be masked replaced by maskText. The
+// changes themselves are kept, as assertions and --fail-on check their values.
+// Moves only carry positions and are copied as they are.
+func maskChanges(changes []Change) []Change {
+	masked := append([]Change{}, changes...)
+	for i := range masked {
+		if masked[i].Type == Move {
+			continue
+		}
+		if masked[i].OldValue != nil {
+			masked[i].OldValue = maskValue(masked[i].OldValue, masked[i].Path)
+		}
+		if masked[i].NewValue != nil {
+			masked[i].NewValue = maskValue(masked[i].NewValue, masked[i].Path)
+		}
+	}
+	return masked
+}
+
+// maskedPaths lists the paths of a value whose values should be masked,
+// leaving out those nested in a value that is masked as a whole
+func maskedPaths(value interface{}, path string) []string {
+	if shouldMask(path, value) {
+		return []string{path}
+	}
+	var paths []string
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for key, child := range v {
+			paths = append(paths, maskedPaths(child, path+"."+fmt.Sprintf("%v", key))...)
+		}
+	case []interface{}:
+		keyed := !rawMode && isSliceOfDictsWithIds(v)
+		for i, item := range v {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			if id, ok := itemIdentifier(item); keyed && ok {
+				itemPath = path + "[" + id + "]"
+			}
+			paths = append(paths, maskedPaths(item, itemPath)...)
+		}
+	}
+	return paths
+}
+
+// maskedNodes finds the source nodes of a document holding values that
+// should be masked
+func maskedNodes(root *yaml.Node, data interface{}) []*yaml.Node {
+	var nodes []*yaml.Node
+	for _, path := range maskedPaths(data, "") {
+		if node := locateNode(root, data, path); node != nil && node.Line > 0 {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// sourceSpan is the text of a value in the source lines, from a 1-based line
+// and column up to, but not including, an end line and column
+type sourceSpan struct {
+	Line, Column       int
+	EndLine, EndColumn int
+}
+
+// maskedSpans finds the spans of source text to replace by a quoted maskText
+// for masked nodes. Scalars and flow collections are replaced as a whole,
+// anchors and tags before them being kept; block collections have each of
+// their values replaced, so that the lines around them still parse.
+func maskedSpans(nodes []*yaml.Node, lines [][]rune) []sourceSpan {
+	var spans []sourceSpan
+	for _, node := range nodes {
+		if (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && node.Style&yaml.FlowStyle == 0 {
+			var values []*yaml.Node
+			for i, child := range node.Content {
+				if node.Kind == yaml.SequenceNode || i%2 == 1 {
+					values = append(values, resolveNode(child))
+				}
+			}
+			spans = append(spans, maskedSpans(values, lines)...)
+			continue
+		}
+		if span, ok := valueSpan(node, lines); ok {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// valueSpan finds the span of a scalar or flow collection in the source lines
+func valueSpan(node *yaml.Node, lines [][]rune) (sourceSpan, bool) {
+	if node == nil || node.Line < 1 || node.Line > len(lines) {
+		return sourceSpan{}, false
+	}
+	line, column := node.Line, node.Column
+	text := lines[line-1]
+	at := func(i int) rune {
+		if i < len(text) {
+			return text[i]
+		}
+		return 0
+	}
+
+	// Skip the anchor and tag of the value
+	i := column - 1
+	for at(i) == '&' || at(i) == '!' {
+		for i < len(text) && text[i] != ' ' {
+			i++
+		}
+		for at(i) == ' ' {
+			i++
+		}
+	}
+	if i >= len(text) {
+		return sourceSpan{}, false
+	}
+	span := sourceSpan{Line: line, Column: i + 1}
+
+	switch text[i] {
+	case '"', '\'', '[', '{':
+		endLine, endColumn, ok := scanFlowValue(lines, line, i)
+		if !ok {
+			return sourceSpan{}, false
+		}
+		span.EndLine, span.EndColumn = endLine, endColumn
+	case '|', '>':
+		span.EndLine = max(line, lastLine(node))
+		if span.EndLine > len(lines) {
+			span.EndLine = len(lines)
+		}
+		span.EndColumn = len(lines[span.EndLine-1]) + 1
+	default:
+		// Plain scalars on one line are written as their value
+		value := []rune(node.Value)
+		if i+len(value) > len(text) || string(text[i:i+len(value)]) != node.Value {
+			return sourceSpan{}, false
+		}
+		span.EndLine, span.EndColumn = line, i+len(value)+1
+	}
+	return span, true
+}
+
+// scanFlowValue finds the end of a quoted scalar or flow collection starting
+// at a 0-based index of a line, returning the line and column following it
+func scanFlowValue(lines [][]rune, line, index int) (int, int, bool) {
+	depth := 0
+	var quote rune
+	for l := line; l <= len(lines); l++ {
+		text := lines[l-1]
+		start := 0
+		if l == line {
+			start = index
+		}
+		for i := start; i < len(text); i++ {
+			c := text[i]
+			switch {
+			case quote == '"' && c == '\\':
+				i++
+				continue
+			case quote == '\'' && c == '\'' && i+1 < len(text) && text[i+1] == '\'':
+				i++
+				continue
+			case quote != 0:
+				if c != quote {
+					continue
+				}
+				quote = 0
+			case c == '"' || c == '\'':
+				quote = c
+				continue
+			case c == '[' || c == '{':
+				depth++
+				continue
+			case c == ']' || c == '}':
+				depth--
+			case c == '#' && (i == 0 || text[i-1] == ' '):
+				i = len(text)
+				continue
+			default:
+				continue
+			}
+			if depth == 0 {
+				return l, i + 2, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Values to mask in a unified diff are rendered between maskStart and maskEnd,
+// control characters YAML escapes in double-quoted strings, so that they are
+// still compared line by line and replaced by maskText only in the diff
+const (
+	maskStart = "\x01"
+	maskEnd   = "\x02"
+)
+
+// maskedRendering matches a value rendered between maskStart and maskEnd
+var maskedRendering = regexp.MustCompile(`"\\x01(?:[^"\\]|\\.)*?\\x02"`)
+
+// markMasked returns a copy of a value with everything that should be masked
+// replaced by a string holding its rendering between the mask delimiters
+func markMasked(value interface{}, path string) interface{} {
+	if shouldMask(path, value) {
+		rendered, err := yaml.Marshal(value)
+		if err != nil {
+			rendered = []byte(fmt.Sprintf("%v", value))
+		}
+		return maskStart + string(rendered) + maskEnd
+	}
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[interface{}]interface{}, len(v))
+		for key, child := range v {
+			result[key] = markMasked(child, path+"."+fmt.Sprintf("%v", key))
+		}
+		return result
+	case []interface{}:
+		keyed := !rawMode && isSliceOfDictsWithIds(v)
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			itemPath := path + "[" + strconv.Itoa(i) + "]"
+			if id, ok := itemIdentifier(item); keyed && ok {
+				itemPath = path + "[" + id + "]"
+			}
+			result[i] = markMasked(item, itemPath)
+		}
+		return result
+	}
+	return value
+}
+
+// holdsMask checks if a value is, or contains, a masked value
+func holdsMask(value interface{}) bool {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		for _, child := range v {
+			if holdsMask(child) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if holdsMask(item) {
+				return true
+			}
+		}
+	case string:
+		return v == maskText
+	}
+	return false
+}
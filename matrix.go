@@ -0,0 +1,238 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/fatih/color"
+	"ymldiff/pkg/ymldiff"
+)
+
+// maxMatrixCellWidth limits the width of a single matrix table cell
+const maxMatrixCellWidth = 40
+
+// matrixRow holds the values of one differing path across all environments
+type matrixRow struct {
+	Path  string
+	Base  string
+	Cells []string // one per environment, empty when equal to the base
+}
+
+// matrixChange is a change of an environment keyed by its matrix row
+type matrixChange struct {
+	Key    string
+	Change Change
+}
+
+// buildMatrix diffs every environment against the base and collects the
+// differing paths. It also returns the comparisons of all environments in one
+// Result.
+func buildMatrix(base []YAMLDocument, envs [][]YAMLDocument) ([]matrixRow, Result) {
+	// Environments are diffed concurrently, then merged in order
+	envChanges := make([][]matrixChange, len(envs))
+	envResults := make([]Result, len(envs))
+	forEachParallel(len(envs), func(envIndex int) {
+		envChanges[envIndex], envResults[envIndex] = diffEnvironment(base, envs[envIndex])
+	})
+
+	var total Result
+	for _, envResult := range envResults {
+		total.TotalDocuments += envResult.TotalDocuments
+		for _, document := range envResult.Documents {
+			total.Add(document)
+		}
+	}
+
+	rows := make(map[string]*matrixRow)
+	for envIndex, changes := range envChanges {
+		for _, keyed := range changes {
+			change := keyed.Change
+			row, exists := rows[keyed.Key]
+			if !exists {
+				row = &matrixRow{Path: keyed.Key, Base: "<absent>", Cells: make([]string, len(envs))}
+				rows[keyed.Key] = row
+			}
+			if change.Type != Addition {
+				row.Base = formatMatrixValue(change.OldValue)
+			}
+			if change.Type == Deletion {
+				row.Cells[envIndex] = "<absent>"
+			} else {
+				row.Cells[envIndex] = formatMatrixValue(change.NewValue)
+			}
+		}
+	}
+
+	result := make([]matrixRow, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, *row)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Path < result[j].Path
+	})
+	return result, total
+}
+
+// diffEnvironment diffs an environment against the base, keying each change by
+// its path prefixed with the document it belongs to. It also returns the
+// comparison, without the moves the matrix leaves out.
+func diffEnvironment(base, env []YAMLDocument) ([]matrixChange, Result) {
+	compared := comparePairs(base, env, pairDocuments(base, env))
+	var result []matrixChange
+	counted := Result{TotalDocuments: compared.TotalDocuments}
+	for position := range compared.Documents {
+		document := &compared.Documents[position]
+		prefix := ""
+		if document.Pair.Old < 0 {
+			prefix = fmt.Sprintf("#new%d ", document.Pair.New+1)
+		} else if len(base) > 1 {
+			prefix = fmt.Sprintf("#%d ", document.Pair.Old+1)
+		}
+
+		// The matrix compares values, so list item positions are left out
+		var kept []ymldiff.Change
+		for _, change := range document.Changes {
+			if change.Type != Move {
+				kept = append(kept, change)
+			}
+		}
+		document.Changes = kept
+		counted.Add(*document)
+
+		changes := compared.documentChanges(position)
+		if masking() {
+			changes = maskChanges(changes)
+		}
+		for _, change := range changes {
+			result = append(result, matrixChange{Key: prefix + change.Path, Change: change})
+		}
+	}
+	return result, counted
+}
+
+// formatMatrixValue formats a value on a single line for a matrix cell
+func formatMatrixValue(v interface{}) string {
+	return formatSingleLine(v, maxMatrixCellWidth)
+}
+
+// formatSingleLine formats a value on a single line of at most maxWidth characters,
+// summarizing maps and lists and escaping newlines
+func formatSingleLine(v interface{}, maxWidth int) string {
+	formatted := summarizeBlock(v, func() string { return formatValue(v) })
+	formatted = strings.ReplaceAll(formatted, "\n", "\\n")
+	if utf8.RuneCountInString(formatted) > maxWidth {
+		runes := []rune(formatted)
+		formatted = string(runes[:maxWidth-1]) + "…"
+	}
+	return formatted
+}
+
+// generateMatrix renders the matrix rows as a table with one column per file.
+// Cells equal to the base value are shown as "=".
+func generateMatrix(rows []matrixRow, names []string) string {
+	if len(rows) == 0 {
+		return "No changes found.\n"
+	}
+
+	table := [][]string{append([]string{"PATH"}, names...)}
+	for _, row := range rows {
+		line := []string{row.Path, row.Base}
+		for _, cell := range row.Cells {
+			if cell == "" {
+				cell = "="
+			}
+			line = append(line, cell)
+		}
+		table = append(table, line)
+	}
+
+	widths := make([]int, len(table[0]))
+	for _, line := range table {
+		for i, cell := range line {
+			if width := utf8.RuneCountInString(cell); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	bold := color.New(color.Bold)
+	yellow := color.New(color.FgYellow)
+	var result strings.Builder
+	for lineIndex, line := range table {
+		for i, cell := range line {
+			padded := cell
+			if i < len(line)-1 {
+				padded += strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)+2)
+			}
+			switch {
+			case lineIndex == 0:
+				result.WriteString(bold.Sprint(padded))
+			case i > 1 && cell != "=":
+				// Highlight environments drifting from the base
+				result.WriteString(yellow.Sprint(padded))
+			default:
+				result.WriteString(padded)
+			}
+		}
+		result.WriteString("\n")
+	}
+
+	return result.String()
+}
+
+// runMatrix compares every environment file against the base file and prints
+// the matrix. It returns the comparisons of all environments.
+func runMatrix(files []string) (Result, error) {
+	parsed := make([][]YAMLDocument, len(files))
+	errs := make([]error, len(files))
+	forEachParallel(len(files), func(i int) {
+		documents, err := parseInput(files[i], "")
+		if err != nil {
+			errs[i] = err
+			return
+		}
+		normalizeDocuments(documents)
+		parsed[i] = documents
+	})
+	if err := errors.Join(errs...); err != nil {
+		return Result{}, err
+	}
+
+	// Mapped paths of the base are compared under their new names in every environment
+	mapDocuments(parsed[0])
+	rows, result := buildMatrix(parsed[0], parsed[1:])
+	defer resetFormattedValues()
+
+	blue := color.New(color.FgBlue)
+	if reportMode {
+		labels := []string{"Base"}
+		for i := range files[1:] {
+			labels = append(labels, fmt.Sprintf("Env %d", i+1))
+		}
+		blue.Print(formatReportHeader(labels, files, reportOptions, time.Now()))
+		fmt.Println()
+	}
+
+	// Past --limit, differing paths are only counted
+	shown := rows
+	if changeLimit >= 0 && len(rows) > changeLimit {
+		shown = rows[:changeLimit]
+	}
+	if len(shown) > 0 || len(rows) == 0 {
+		fmt.Print(generateMatrix(shown, files))
+	}
+	if omitted := len(rows) - len(shown); omitted > 0 {
+		blue.Printf("… and %s more differing %s\n", groupThousands(omitted), pluralize(omitted, "path", "paths"))
+	}
+
+	if reportMode {
+		fmt.Println()
+		blue.Printf("# Total: %d differing %s across %d environment %s\n",
+			len(rows), pluralize(len(rows), "path", "paths"), len(files)-1, pluralize(len(files)-1, "file", "files"))
+	}
+	return result, nil
+}
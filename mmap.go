@@ -0,0 +1,34 @@
+package main
+
+import "os"
+
+// mmapThreshold is the size from which input files are memory-mapped instead
+// of read into memory, so the kernel pages giant manifests in as they are parsed
+var mmapThreshold int64 = 64 << 20
+
+// readFile reads a file, memory-mapping it when it is at least mmapThreshold
+// bytes large. Mapped files stay mapped until the process exits, as parsed
+// documents and annotations may refer back to their content.
+func readFile(filename string) ([]byte, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() < mmapThreshold || info.Size() == 0 {
+		return os.ReadFile(filename)
+	}
+
+	data, err := mapFile(file, info.Size())
+	if err != nil {
+		debugf("%s: memory-mapping failed (%v), reading it instead", filename, err)
+		return os.ReadFile(filename)
+	}
+	debugf("%s: memory-mapped %d bytes", filename, len(data))
+	return data, nil
+}
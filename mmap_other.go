@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// mapFile is not supported on this platform, so files are always read
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	return nil, errors.New("memory-mapping is not supported on this platform")
+}
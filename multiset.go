@@ -0,0 +1,15 @@
+package main
+
+import "fmt"
+
+// multisetMode compares lists of scalars as bags, set with --multiset
+var multisetMode bool
+
+// formatCount prefixes a value with the number of times it was added or
+// removed, when that is more than once
+func formatCount(change Change, formatted string) string {
+	if change.Count > 1 {
+		return fmt.Sprintf("%d× %s", change.Count, formatted)
+	}
+	return formatted
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Patch operation names
+const (
+	patchAdd     = "add"
+	patchRemove  = "remove"
+	patchReplace = "replace"
+)
+
+// patchOperation is a single operation of a patch file. Document is the
+// 1-based index of the document in the file the patch applies to; an "add" of
+// the root path at an index past the last document appends a new document.
+type patchOperation struct {
+	Document int         `yaml:"document"`
+	Op       string      `yaml:"op"`
+	Path     string      `yaml:"path"`
+	Value    interface{} `yaml:"value,omitempty"`
+}
+
+// invertChanges returns the changes turning the new side back into the old side
+func invertChanges(changes []Change) []Change {
+	inverted := make([]Change, 0, len(changes))
+	for _, change := range changes {
+		reversed := Change{
+			Type:     change.Type,
+			Path:     change.Path,
+			OldValue: change.NewValue,
+			NewValue: change.OldValue,
+			OldNode:  change.NewNode,
+			NewNode:  change.OldNode,
+		}
+		switch change.Type {
+		case Addition:
+			reversed.Type = Deletion
+		case Deletion:
+			reversed.Type = Addition
+		}
+		inverted = append(inverted, reversed)
+	}
+	return inverted
+}
+
+// newPatchOperations converts the changes of a document to patch operations,
+// sorted by path. Moves of list items have no patch operation and are left out.
+func newPatchOperations(changes []Change, document int) []patchOperation {
+	sorted := append([]Change{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	operations := make([]patchOperation, 0, len(sorted))
+	for _, change := range sorted {
+		if change.Type == Move {
+			continue
+		}
+		operation := patchOperation{Document: document, Path: change.Path}
+		switch change.Type {
+		case Addition:
+			operation.Op = patchAdd
+			operation.Value = change.NewValue
+		case Deletion:
+			operation.Op = patchRemove
+		case Modification:
+			operation.Op = patchReplace
+			operation.Value = change.NewValue
+		}
+		operations = append(operations, operation)
+	}
+	return operations
+}
+
+// formatPatch renders patch operations as a YAML patch file turning from into to
+func formatPatch(operations []patchOperation, from, to string) (string, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# ymldiff patch: %s -> %s\n", from, to)
+	if len(operations) == 0 {
+		buf.WriteString("[]\n")
+		return buf.String(), nil
+	}
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(operations); err != nil {
+		return "", err
+	}
+	if err := encoder.Close(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// writePatch writes patch operations to a file
+func writePatch(filename string, operations []patchOperation, from, to string) error {
+	content, err := formatPatch(operations, from, to)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, []byte(content), 0o644)
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// TestInvertChanges tests that changes are inverted into their roll-back form
+func TestInvertChanges(t *testing.T) {
+	changes := []Change{
+		{Type: Addition, Path: ".debug", NewValue: true},
+		{Type: Deletion, Path: ".legacy", OldValue: "on"},
+		{Type: Modification, Path: ".replicas", OldValue: 3, NewValue: 5},
+	}
+
+	expected := []Change{
+		{Type: Deletion, Path: ".debug", OldValue: true},
+		{Type: Addition, Path: ".legacy", NewValue: "on"},
+		{Type: Modification, Path: ".replicas", OldValue: 5, NewValue: 3},
+	}
+	if inverted := invertChanges(changes); !reflect.DeepEqual(inverted, expected) {
+		t.Errorf("invertChanges() = %+v, expected %+v", inverted, expected)
+	}
+}
+
+// TestReversePatch tests the roll-back patch generated from a diff
+func TestReversePatch(t *testing.T) {
+	oldData := map[interface{}]interface{}{
+		"replicas": 3,
+		"legacy":   map[interface{}]interface{}{"enabled": true},
+	}
+	newData := map[interface{}]interface{}{
+		"replicas": 5,
+		"debug":    false,
+	}
+
+	operations := newPatchOperations(invertChanges(diffValues(oldData, newData, "")), 2)
+	output, err := formatPatch(operations, "new.yaml", "old.yaml")
+	if err != nil {
+		t.Fatalf("formatPatch() error: %v", err)
+	}
+
+	expected := `# ymldiff patch: new.yaml -> old.yaml
+- document: 2
+  op: remove
+  path: .debug
+- document: 2
+  op: add
+  path: .legacy
+  value:
+    enabled: true
+- document: 2
+  op: replace
+  path: .replicas
+  value: 3
+`
+	if output != expected {
+		t.Errorf("Unexpected patch:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+// TestApplyReversePatch tests that applying a roll-back patch to the new file restores the old one
+func TestApplyReversePatch(t *testing.T) {
+	oldSource := "replicas: 3 # keep\nports: [443, 80]\ncontainers:\n  - name: app\n    image: app:1\n  - name: sidecar\n    image: proxy:1\n---\nkind: Extra\n"
+	newSource := "replicas: 5 # keep\nports: [80]\ncontainers:\n  - name: app\n    image: app:2\n    args: [--debug]\ndebug: true\n"
+
+	oldDocs, err := parseYAMLData([]byte(oldSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDocs, err := parseYAMLData([]byte(newSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var operations []patchOperation
+	for _, pair := range pairDocuments(oldDocs, newDocs) {
+		var oldData, newData interface{}
+		document := len(newDocs) + 1
+		if pair.Old >= 0 {
+			oldData = oldDocs[pair.Old].Data
+		}
+		if pair.New >= 0 {
+			newData = newDocs[pair.New].Data
+			document = pair.New + 1
+		}
+		operations = append(operations, newPatchOperations(invertChanges(diffValues(oldData, newData, "")), document)...)
+	}
+
+	resolved, err := resolveOperations(operations, newDocs)
+	if err != nil {
+		t.Fatalf("resolveOperations() error: %v", err)
+	}
+	nodes := []*yaml.Node{newDocs[0].Node}
+	for _, r := range resolved {
+		if nodes, err = applyResolved(r, nodes); err != nil {
+			t.Fatalf("applyResolved() error: %v", err)
+		}
+	}
+	result, err := encodeDocuments(nodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(result), "replicas: 3 # keep") {
+		t.Errorf("Expected the line comment to be kept, got:\n%s", result)
+	}
+	restored, err := parseYAMLData(result)
+	if err != nil {
+		t.Fatalf("Failed to parse the patched file: %v\n%s", err, result)
+	}
+	if len(restored) != len(oldDocs) {
+		t.Fatalf("Expected %d documents, got %d:\n%s", len(oldDocs), len(restored), result)
+	}
+	for i := range oldDocs {
+		if !reflect.DeepEqual(restored[i].Data, oldDocs[i].Data) {
+			t.Errorf("Document %d: expected %v, got %v", i+1, oldDocs[i].Data, restored[i].Data)
+		}
+	}
+}
+
+// TestResolveOperationErrors tests that operations not matching the target are rejected
+func TestResolveOperationErrors(t *testing.T) {
+	documents, err := parseYAMLData([]byte("spec:\n  replicas: 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, operation := range []patchOperation{
+		{Document: 1, Op: patchReplace, Path: ".spec.missing", Value: 1},
+		{Document: 1, Op: patchRemove, Path: ".status.phase"},
+		{Document: 1, Op: patchAdd, Path: ".spec.replicas", Value: 5},
+		{Document: 3, Op: patchReplace, Path: ".spec", Value: 5},
+	} {
+		if _, err := resolveOperations([]patchOperation{operation}, documents); err == nil {
+			t.Errorf("Expected an error for %+v", operation)
+		}
+	}
+}
+
+// TestConfirmChange tests the interactive confirmation answers
+func TestConfirmChange(t *testing.T) {
+	change := Change{Type: Modification, Path: ".replicas", OldValue: 3, NewValue: 5}
+	tests := []struct {
+		input string
+		apply bool
+		stop  bool
+	}{
+		{"y\n", true, false},
+		{"maybe\nn\n", false, false},
+		{"q\n", false, true},
+		{"", false, true},
+	}
+
+	for _, tt := range tests {
+		apply, stop, _ := confirmChange(bufio.NewReader(strings.NewReader(tt.input)), io.Discard, change, "Apply this change")
+		if apply != tt.apply || stop != tt.stop {
+			t.Errorf("Input %q: got apply=%v stop=%v, expected apply=%v stop=%v", tt.input, apply, stop, tt.apply, tt.stop)
+		}
+	}
+}
+
+// TestRunApplyDryRun tests that a dry run leaves the target file untouched
+func TestRunApplyDryRun(t *testing.T) {
+	dir := t.TempDir()
+	patchFile := filepath.Join(dir, "patch.yaml")
+	target := filepath.Join(dir, "target.yaml")
+	writeTestFile(t, patchFile, "- document: 1\n  op: replace\n  path: .replicas\n  value: 3\n")
+	writeTestFile(t, target, "replicas: 5\n")
+
+	discardOutput(t)
+	if err := runApply(patchFile, target, applyOptions{DryRun: true}); err != nil {
+		t.Fatalf("runApply() error: %v", err)
+	}
+
+	if content, _ := os.ReadFile(target); string(content) != "replicas: 5\n" {
+		t.Errorf("Expected the target to be unchanged, got %q", content)
+	}
+
+	if err := runApply(patchFile, target, applyOptions{}); err != nil {
+		t.Fatalf("runApply() error: %v", err)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "replicas: 3\n" {
+		t.Errorf("Expected the patch to be applied, got %q", content)
+	}
+}
+
+// TestSelectOperations tests cherry-picking operations by path
+func TestSelectOperations(t *testing.T) {
+	operations := []patchOperation{
+		{Document: 1, Op: patchReplace, Path: ".spec.replicas", Value: 3},
+		{Document: 1, Op: patchReplace, Path: ".spec.template.image", Value: "app:1"},
+		{Document: 1, Op: patchRemove, Path: ".data.password"},
+		{Document: 1, Op: patchAdd, Path: ".data.user", Value: "admin"},
+	}
+
+	tests := []struct {
+		only     []string
+		skip     []string
+		expected []string
+	}{
+		{nil, nil, []string{".spec.replicas", ".spec.template.image", ".data.password", ".data.user"}},
+		{[]string{".spec.replicas"}, nil, []string{".spec.replicas"}},
+		{[]string{".spec"}, []string{".spec.template"}, []string{".spec.replicas"}},
+		{nil, []string{".data.*"}, []string{".spec.replicas", ".spec.template.image"}},
+	}
+
+	for _, tt := range tests {
+		var paths []string
+		for _, operation := range selectOperations(operations, tt.only, tt.skip) {
+			paths = append(paths, operation.Path)
+		}
+		if !reflect.DeepEqual(paths, tt.expected) {
+			t.Errorf("only=%v skip=%v: got %v, expected %v", tt.only, tt.skip, paths, tt.expected)
+		}
+	}
+}
+
+// TestStageChanges tests accepting and rejecting changes interactively
+func TestStageChanges(t *testing.T) {
+	changes := newStagedChanges([]Change{
+		{Type: Modification, Path: ".replicas", OldValue: 3, NewValue: 5},
+		{Type: Addition, Path: ".debug", NewValue: true},
+		{Type: Deletion, Path: ".legacy", OldValue: "on"},
+	}, 1)
+
+	accepted, err := stageChanges(bufio.NewReader(strings.NewReader("n\ny\ny\n")), io.Discard, changes)
+	if err != nil {
+		t.Fatalf("stageChanges() error: %v", err)
+	}
+	if len(accepted) != 2 || accepted[0].Path != ".legacy" || accepted[0].Op != patchRemove || accepted[1].Path != ".replicas" {
+		t.Errorf("Unexpected accepted operations: %+v", accepted)
+	}
+
+	// Quitting, or running out of answers, stops staging
+	accepted, err = stageChanges(bufio.NewReader(strings.NewReader("y\n")), io.Discard, changes)
+	if err != nil {
+		t.Fatalf("stageChanges() error: %v", err)
+	}
+	if len(accepted) != 1 || accepted[0].Path != ".debug" {
+		t.Errorf("Unexpected accepted operations: %+v", accepted)
+	}
+}
+
+// TestMergeAccepted tests merging accepted changes into the first file
+func TestMergeAccepted(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "old.yaml")
+	writeTestFile(t, file, "replicas: 3 # keep\nlegacy: on\n")
+
+	merged, err := mergeAccepted(file, []patchOperation{
+		{Document: 1, Op: patchReplace, Path: ".replicas", Value: 5},
+		{Document: 1, Op: patchAdd, Path: ".debug", Value: true},
+	})
+	if err != nil {
+		t.Fatalf("mergeAccepted() error: %v", err)
+	}
+
+	expected := "replicas: 5 # keep\nlegacy: on\ndebug: true\n"
+	if string(merged) != expected {
+		t.Errorf("Unexpected merged YAML:\n%s\nexpected:\n%s", merged, expected)
+	}
+}
+
+// TestRunAssert tests checking the changes between two files against an expected patch
+func TestRunAssert(t *testing.T) {
+	old := createTempFile(t, "old.yaml", "replicas: 1\nimage: app:1.4\ndebug: true\n")
+	defer os.Remove(old)
+	updated := createTempFile(t, "new.yaml", "replicas: 3\nimage: app:1.5\n")
+	defer os.Remove(updated)
+
+	patches := map[string]string{
+		"exact":    "- {document: 1, op: replace, path: .replicas, value: 3}\n- {document: 1, op: replace, path: image, value: app:1.5}\n- {document: 1, op: remove, path: .debug}\n",
+		"fewer":    "- {document: 1, op: replace, path: .replicas, value: 3}\n",
+		"more":     "- {document: 1, op: replace, path: .replicas, value: 3}\n- {document: 1, op: replace, path: .image, value: app:1.5}\n- {document: 1, op: remove, path: .debug}\n- {document: 1, op: add, path: .port, value: 80}\n",
+		"mismatch": "- {document: 1, op: replace, path: .replicas, value: 5}\n- {document: 1, op: replace, path: .image, value: app:1.5}\n- {document: 1, op: remove, path: .debug}\n",
+	}
+	files := make(map[string]string)
+	for name, content := range patches {
+		files[name] = createTempFile(t, name+".yaml", content)
+		defer os.Remove(files[name])
+	}
+
+	discardOutput(t)
+
+	tests := []struct {
+		patch, mode string
+		expected    bool
+	}{
+		{"exact", expectExact, true},
+		{"fewer", expectExact, false},
+		{"fewer", expectSuperset, true},
+		{"fewer", expectSubset, false},
+		{"more", expectExact, false},
+		{"more", expectSubset, true},
+		{"more", expectSuperset, false},
+		{"mismatch", expectSubset, false},
+		{"mismatch", expectSuperset, false},
+	}
+	for _, tt := range tests {
+		passed, err := runAssert(old, updated, files[tt.patch], tt.mode)
+		if err != nil {
+			t.Fatalf("runAssert(%s, %s) error: %v", tt.patch, tt.mode, err)
+		}
+		if passed != tt.expected {
+			t.Errorf("runAssert(%s, %s) = %v, expected %v", tt.patch, tt.mode, passed, tt.expected)
+		}
+	}
+}
+
+// TestCheckExpected tests matching values of computed and expected operations
+func TestCheckExpected(t *testing.T) {
+	computed := []stagedChange{{Operation: patchOperation{Document: 1, Op: patchAdd, Path: ".labels", Value: map[interface{}]interface{}{"app": "web"}}}}
+	expected := []patchOperation{{Document: 1, Op: patchAdd, Path: ".labels", Value: map[string]interface{}{"app": "web"}}}
+	if check := checkExpected(computed, expected); !check.passes(expectExact) {
+		t.Errorf("Expected the decoded patch value to match, got %+v", check)
+	}
+
+	expected[0].Document = 2
+	check := checkExpected(computed, expected)
+	if len(check.Unexpected) != 1 || len(check.Missing) != 1 {
+		t.Errorf("Expected one unexpected and one missing change for another document, got %+v", check)
+	}
+}
+
+// commitFile writes a file and commits it to the git repository in dir
+func commitFile(t *testing.T, dir, name, content, author string) {
+	t.Helper()
+	writeTestFile(t, filepath.Join(dir, name), content)
+	for _, args := range [][]string{
+		{"add", name},
+		{"-c", "user.name=" + author, "-c", "user.email=" + author + "@example.com", "commit", "-q", "-m", "Update " + name},
+	} {
+		if _, err := runGit(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestChangelog tests collecting the changes of a file across its git history
+func TestChangelog(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	dir := t.TempDir()
+	if _, err := runGit(dir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, "config.yaml", "replicas: 1\nimage: app:1\n", "alice")
+	if _, err := runGit(dir, "tag", "v1.0.0"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, "config.yaml", "replicas: 3\nimage: app:1\ndebug: true\n", "bob")
+	commitFile(t, dir, "config.yaml", "replicas: 5\nimage: app:1\n", "alice")
+
+	changelog, err := buildChangelog(filepath.Join(dir, "config.yaml"), "v1.0.0")
+	if err != nil {
+		t.Fatalf("buildChangelog() error: %v", err)
+	}
+
+	if len(changelog[".replicas"]) != 2 || len(changelog[".debug"]) != 2 || len(changelog) != 2 {
+		t.Fatalf("Unexpected changelog: %+v", changelog)
+	}
+	first, second := changelog[".replicas"][0], changelog[".replicas"][1]
+	if first.Revision.Author != "bob" || first.Change.OldValue != 1 || first.Change.NewValue != 3 {
+		t.Errorf("Unexpected first entry: %+v", first)
+	}
+	if second.Revision.Author != "alice" || second.Change.NewValue != 5 {
+		t.Errorf("Unexpected second entry: %+v", second)
+	}
+	if changelog[".debug"][1].Change.Type != Deletion {
+		t.Errorf("Expected .debug to be removed last, got %+v", changelog[".debug"][1])
+	}
+}
+
+// TestGenerateChangelog tests the changelog output format
+func TestGenerateChangelog(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	revision := gitRevision{ShortHash: "3f2a9c1", Author: "alice", Date: "2024-05-02"}
+	changelog := map[string][]changelogEntry{
+		".replicas": {{Revision: revision, Change: Change{Type: Modification, Path: ".replicas", OldValue: 1, NewValue: 3}}},
+		".debug":    {{Revision: revision, Change: Change{Type: Addition, Path: ".debug", NewValue: true}}},
+	}
+
+	expected := `.debug
+  3f2a9c1 2024-05-02 alice: + true
+.replicas
+  3f2a9c1 2024-05-02 alice: ~ 1 → 3
+`
+	if output := generateChangelog(changelog); output != expected {
+		t.Errorf("Unexpected changelog:\n%s\nexpected:\n%s", output, expected)
+	}
+	if output := generateChangelog(nil); output != "No changes found.\n" {
+		t.Errorf("Unexpected output for an empty changelog: %q", output)
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// splitLastSegment splits a change path into its parent path and last segment.
+// The segment keeps its leading "." or its brackets, e.g. ".a.b[c]" yields ".a.b" and "[c]".
+func splitLastSegment(path string) (string, string) {
+	if strings.HasSuffix(path, ymldiff.EmbeddedSeparator) {
+		return strings.TrimSuffix(path, ymldiff.EmbeddedSeparator), ymldiff.EmbeddedSeparator
+	}
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndex(path, "["); i >= 0 {
+			return path[:i], path[i:]
+		}
+	}
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i], path[i:]
+	}
+	return "", path
+}
+
+// normalizePathArgument adds the leading dot a path given on the command line
+// may lack, and treats "." as the document root. Patterns starting with "*"
+// match at any depth and are kept as they are.
+func normalizePathArgument(path string) string {
+	if path == "." {
+		return ""
+	}
+	if path != "" && !strings.HasPrefix(path, ".") && !strings.HasPrefix(path, "[") && !strings.HasPrefix(path, "*") {
+		return "." + path
+	}
+	return path
+}
@@ -0,0 +1,37 @@
+package ymldiff
+
+// BooleanModes lists the ways booleans can be read: as YAML 1.2 does, where
+// only true and false are booleans, or as YAML 1.1 does, where yes, on, no and
+// off are too
+var BooleanModes = []string{"yaml1.2", "yaml1.1"}
+
+// yaml11Booleans maps the words YAML 1.1 reads as booleans to their values
+var yaml11Booleans = map[string]bool{
+	"y": true, "Y": true, "yes": true, "Yes": true, "YES": true,
+	"n": false, "N": false, "no": false, "No": false, "NO": false,
+	"true": true, "True": true, "TRUE": true,
+	"false": false, "False": false, "FALSE": false,
+	"on": true, "On": true, "ON": true,
+	"off": false, "Off": false, "OFF": false,
+}
+
+// yaml11Bool reads a boolean or a string YAML 1.1 reads as one
+func yaml11Bool(v interface{}) (bool, bool) {
+	switch b := v.(type) {
+	case bool:
+		return b, true
+	case string:
+		value, ok := yaml11Booleans[b]
+		return value, ok
+	default:
+		return false, false
+	}
+}
+
+// yaml11BooleansEqual checks if two values are the same boolean as YAML 1.1
+// reads them, so yes, on and true are equal
+func yaml11BooleansEqual(a, b interface{}) bool {
+	boolA, okA := yaml11Bool(a)
+	boolB, okB := yaml11Bool(b)
+	return okA && okB && boolA == boolB
+}
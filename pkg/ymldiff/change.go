@@ -0,0 +1,37 @@
+package ymldiff
+
+// ChangeType represents the type of change
+type ChangeType int
+
+const (
+	Addition ChangeType = iota
+	Deletion
+	Modification
+	Move // position change of a keyed list item, reported with Options.DetectReorders
+)
+
+// String returns the lowercase name of the change type
+func (t ChangeType) String() string {
+	switch t {
+	case Addition:
+		return "addition"
+	case Deletion:
+		return "deletion"
+	case Modification:
+		return "modification"
+	case Move:
+		return "move"
+	default:
+		return "unknown"
+	}
+}
+
+// Change represents a single change in the diff
+type Change struct {
+	Type     ChangeType
+	Path     string
+	OldValue interface{}
+	NewValue interface{}
+	// Number of equal items added or removed, with Options.Multiset
+	Count int
+}
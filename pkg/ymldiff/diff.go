@@ -0,0 +1,447 @@
+package ymldiff
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Diff compares two normalized documents and returns the changes turning the
+// first into the second. With Options.Path only the values at that path are
+// compared.
+func Diff(oldDoc, newDoc interface{}, opts Options) []Change {
+	if opts.Path != "" {
+		oldVal, _ := Lookup(oldDoc, opts.Path, opts)
+		newVal, _ := Lookup(newDoc, opts.Path, opts)
+		return DiffAt(oldVal, newVal, opts.Path, opts)
+	}
+	opts.hashes = make(hashCache)
+	return opts.diffValues(oldDoc, newDoc, "")
+}
+
+// DiffAt compares two normalized values found at path, labelling the changes
+// with paths below it
+func DiffAt(oldVal, newVal interface{}, path string, opts Options) []Change {
+	opts.hashes = make(hashCache)
+	return opts.diffValues(oldVal, newVal, path)
+}
+
+// IsKeyedList checks if a slice contains dictionaries with identifier fields
+func IsKeyedList(slice []interface{}, idKeys []string) bool {
+	if len(slice) == 0 {
+		return false
+	}
+
+	for _, item := range slice {
+		m, ok := item.(map[interface{}]interface{})
+		if !ok {
+			return false
+		}
+		// Check for configured identifier fields
+		for _, idKey := range idKeys {
+			if _, hasId := m[idKey]; hasId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ItemIdentifier returns the identifier field of a list item along with its value
+func ItemIdentifier(item interface{}, idKeys []string) (string, string, bool) {
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return "", "", false
+	}
+	for _, idKey := range idKeys {
+		if id, hasId := m[idKey]; hasId {
+			return idKey, fmt.Sprintf("%v", id), true
+		}
+	}
+	return "", "", false
+}
+
+// diffSliceOfDicts compares slices of dictionaries by matching on identifier fields
+func (o Options) diffSliceOfDicts(oldSlice, newSlice []interface{}, path string) []Change {
+	var changes []Change
+
+	// Group by identifier
+	oldMap := make(map[string]interface{})
+	newMap := make(map[string]interface{})
+
+	usedKeys := make(map[string]bool)
+	var oldOrder, newOrder []string
+	for _, side := range []struct {
+		slice []interface{}
+		items map[string]interface{}
+		order *[]string
+	}{{oldSlice, oldMap, &oldOrder}, {newSlice, newMap, &newOrder}} {
+		sideKeys := make(map[string]bool)
+		for _, item := range side.slice {
+			if idKey, id, ok := ItemIdentifier(item, o.IDKeys); ok {
+				side.items[id] = item
+				*side.order = append(*side.order, id)
+				sideKeys[idKey] = true
+				usedKeys[idKey] = true
+			}
+		}
+		// Items keyed by different fields may be matched inconsistently between files
+		if len(sideKeys) > 1 {
+			o.warnf(path, "list items are identified by different fields (%s); set their priority with --id-key",
+				strings.Join(sortedKeys(sideKeys), ", "))
+		}
+	}
+	o.debugf("%s: keyed list, items matched by %s", DisplayPath(path), strings.Join(sortedKeys(usedKeys), ", "))
+
+	// Find matches and differences, in the order of the lists
+	for _, key := range uniqueKeys(oldOrder) {
+		oldItem := oldMap[key]
+		if newItem, exists := newMap[key]; exists {
+			// Both exist, diff them
+			subChanges := o.diffValues(oldItem, newItem, path+"["+key+"]")
+			changes = append(changes, subChanges...)
+		} else {
+			// Only in old, it's a deletion
+			changes = append(changes, Change{
+				Type:     Deletion,
+				Path:     path + "[" + key + "]",
+				OldValue: oldItem,
+				NewValue: nil,
+			})
+		}
+	}
+
+	for _, key := range uniqueKeys(newOrder) {
+		newItem := newMap[key]
+		if _, exists := oldMap[key]; !exists {
+			// Only in new, it's an addition
+			changes = append(changes, Change{
+				Type:     Addition,
+				Path:     path + "[" + key + "]",
+				OldValue: nil,
+				NewValue: newItem,
+			})
+		}
+	}
+
+	if o.DetectReorders {
+		changes = append(changes, reorderedItems(oldOrder, newOrder, path)...)
+	}
+
+	return changes
+}
+
+// countsAsAbsent checks if a map value counts as a missing key: null with
+// NullIsAbsent, an empty map or list with EmptyIsAbsent. It returns what the
+// value is for the debug trace.
+func (o Options) countsAsAbsent(v interface{}) (string, bool) {
+	switch val := v.(type) {
+	case nil:
+		return "null", o.NullIsAbsent
+	case map[interface{}]interface{}:
+		return "empty map", o.EmptyIsAbsent && len(val) == 0
+	case []interface{}:
+		return "empty list", o.EmptyIsAbsent && len(val) == 0
+	default:
+		return "", false
+	}
+}
+
+// diffValues compares two normalized values and returns a list of changes
+func (o Options) diffValues(oldVal, newVal interface{}, path string) []Change {
+	var changes []Change
+
+	// Subtrees are compared by their hashes first, so unequal ones are told
+	// apart without walking them
+	if o.hashes.equal(oldVal, newVal) {
+		return changes
+	}
+
+	// Dates written in different formats of a date rule, and values matching
+	// the pattern of an equivalence rule, are equal
+	if len(o.DateRules) > 0 && datesEqual(o.DateRules, path, oldVal, newVal) {
+		return changes
+	}
+	if len(o.Equivalences) > 0 && equivalentValues(o.Equivalences, path, oldVal, newVal) {
+		return changes
+	}
+	if (o.Epsilon > 0 || len(o.Tolerances) > 0) && o.numbersClose(path, oldVal, newVal) {
+		return changes
+	}
+	if o.NormalizeQuantities && quantitiesEqual(oldVal, newVal) {
+		return changes
+	}
+	if o.NormalizeDurations && durationsEqual(oldVal, newVal) {
+		return changes
+	}
+	if o.YAML11Booleans && yaml11BooleansEqual(oldVal, newVal) {
+		return changes
+	}
+	if o.LooseNumbers && looseNumbersEqual(oldVal, newVal) {
+		return changes
+	}
+	if (o.TrimStrings || o.CollapseWhitespace) && o.whitespaceEqual(oldVal, newVal) {
+		return changes
+	}
+	if len(o.IgnoreValues) > 0 && o.ignoredValuesEqual(oldVal, newVal) {
+		return changes
+	}
+
+	oldType := reflect.TypeOf(oldVal)
+	newType := reflect.TypeOf(newVal)
+
+	// If types are different, it's a modification
+	if oldType != newType && oldVal != nil && newVal != nil {
+		changes = append(changes, Change{
+			Type:     Modification,
+			Path:     path,
+			OldValue: oldVal,
+			NewValue: newVal,
+		})
+		return changes
+	}
+
+	// Handle nil values
+	if oldVal == nil && newVal != nil {
+		changes = append(changes, Change{
+			Type:     Addition,
+			Path:     path,
+			OldValue: nil,
+			NewValue: newVal,
+		})
+		return changes
+	}
+	if oldVal != nil && newVal == nil {
+		changes = append(changes, Change{
+			Type:     Deletion,
+			Path:     path,
+			OldValue: oldVal,
+			NewValue: nil,
+		})
+		return changes
+	}
+
+	// At MaxDepth, a changed map or list is reported as one modification
+	if o.MaxDepth > 0 && pathDepth(path) >= o.MaxDepth && (oldType.Kind() == reflect.Map || oldType.Kind() == reflect.Slice) {
+		full := o
+		full.MaxDepth = 0
+		if len(full.diffValues(oldVal, newVal, path)) == 0 {
+			return changes
+		}
+		o.debugf("%s: changes below depth %d reported as one modification", DisplayPath(path), o.MaxDepth)
+		return append(changes, Change{
+			Type:     Modification,
+			Path:     path,
+			OldValue: oldVal,
+			NewValue: newVal,
+		})
+	}
+
+	switch oldType.Kind() {
+	case reflect.Map:
+		oldMap := oldVal.(map[interface{}]interface{})
+		newMap := newVal.(map[interface{}]interface{})
+
+		// Check for deletions and modifications
+		for _, key := range mapKeys(oldMap) {
+			oldValue := oldMap[key]
+			keyStr := fmt.Sprintf("%v", key)
+			newValue, exists := newMap[key]
+			if absent, ok := o.countsAsAbsent(oldValue); !exists && ok {
+				o.debugf("%s: %s treated as absent", DisplayPath(path+"."+keyStr), absent)
+			} else if !exists {
+				changes = append(changes, Change{
+					Type:     Deletion,
+					Path:     path + "." + keyStr,
+					OldValue: oldValue,
+					NewValue: nil,
+				})
+			} else {
+				subChanges := o.diffValues(oldValue, newValue, path+"."+keyStr)
+				changes = append(changes, subChanges...)
+			}
+		}
+
+		// Check for additions
+		for _, key := range mapKeys(newMap) {
+			newValue := newMap[key]
+			keyStr := fmt.Sprintf("%v", key)
+			_, exists := oldMap[key]
+			if absent, ok := o.countsAsAbsent(newValue); !exists && ok {
+				o.debugf("%s: %s treated as absent", DisplayPath(path+"."+keyStr), absent)
+			} else if !exists {
+				changes = append(changes, Change{
+					Type:     Addition,
+					Path:     path + "." + keyStr,
+					OldValue: nil,
+					NewValue: newValue,
+				})
+			}
+		}
+
+	case reflect.Slice:
+		oldSlice := oldVal.([]interface{})
+		newSlice := newVal.([]interface{})
+
+		// Check if this is a slice of dictionaries with identifier fields
+		// Raw mode compares every list by position
+		if !o.Raw && IsKeyedList(oldSlice, o.IDKeys) && IsKeyedList(newSlice, o.IDKeys) {
+			changes = append(changes, o.diffSliceOfDicts(oldSlice, newSlice, path)...)
+		} else if o.Multiset && isSliceOfScalars(oldSlice) && isSliceOfScalars(newSlice) {
+			o.debugf("%s: list of scalars compared as a multiset", DisplayPath(path))
+			changes = append(changes, diffMultiset(oldSlice, newSlice, path)...)
+		} else if !o.Raw && o.ItemSimilarity > 0 && isSliceOfDicts(oldSlice) && isSliceOfDicts(newSlice) {
+			// Lists of maps without identifier fields are paired by content
+			o.debugf("%s: list without identifier fields, items matched by similarity", DisplayPath(path))
+			o.warnUnkeyedMaps(path, "matched by content similarity", oldSlice, newSlice)
+			changes = append(changes, o.diffSliceBySimilarity(oldSlice, newSlice, path)...)
+		} else {
+			if o.Raw {
+				o.debugf("%s: list compared by position (raw mode)", DisplayPath(path))
+			} else {
+				o.debugf("%s: list without identifier fields, compared by position after sorting", DisplayPath(path))
+				o.warnUnkeyedMaps(path, "compared by position after sorting", oldSlice, newSlice)
+			}
+
+			// For slices, we compare element by element since they're sorted
+			minLen := len(oldSlice)
+			if len(newSlice) < minLen {
+				minLen = len(newSlice)
+			}
+
+			for i := 0; i < minLen; i++ {
+				subChanges := o.diffValues(oldSlice[i], newSlice[i], path+"["+strconv.Itoa(i)+"]")
+				changes = append(changes, subChanges...)
+			}
+
+			// Handle extra elements
+			if len(oldSlice) > len(newSlice) {
+				for i := len(newSlice); i < len(oldSlice); i++ {
+					changes = append(changes, Change{
+						Type:     Deletion,
+						Path:     path + "[" + strconv.Itoa(i) + "]",
+						OldValue: oldSlice[i],
+						NewValue: nil,
+					})
+				}
+			} else if len(newSlice) > len(oldSlice) {
+				for i := len(oldSlice); i < len(newSlice); i++ {
+					changes = append(changes, Change{
+						Type:     Addition,
+						Path:     path + "[" + strconv.Itoa(i) + "]",
+						OldValue: nil,
+						NewValue: newSlice[i],
+					})
+				}
+			}
+		}
+
+	default:
+		if o.ParseEmbedded {
+			if embedded, ok := o.diffEmbedded(oldVal, newVal, path); ok {
+				return append(changes, embedded...)
+			}
+		}
+
+		// Primitive values - if they're different, it's a modification
+		if !reflect.DeepEqual(oldVal, newVal) {
+			changes = append(changes, Change{
+				Type:     Modification,
+				Path:     path,
+				OldValue: oldVal,
+				NewValue: newVal,
+			})
+		}
+	}
+
+	return changes
+}
+
+// Normalize recursively normalizes a YAML value by sorting maps and slices.
+// Maps of any key type become map[interface{}]interface{} and lists
+// []interface{}, the forms Diff compares.
+func Normalize(v interface{}, opts Options) interface{} {
+	if v == nil {
+		return v
+	}
+
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Map:
+		// Sort map keys
+		keys := make([]reflect.Value, 0, val.Len())
+		for _, key := range val.MapKeys() {
+			keys = append(keys, key)
+		}
+
+		// Sort keys by their string representation
+		sort.Slice(keys, func(i, j int) bool {
+			return fmt.Sprintf("%v", keys[i].Interface()) < fmt.Sprintf("%v", keys[j].Interface())
+		})
+
+		// Create normalized map
+		normalized := make(map[interface{}]interface{})
+		for _, key := range keys {
+			normalized[key.Interface()] = Normalize(val.MapIndex(key).Interface(), opts)
+		}
+		return normalized
+
+	case reflect.Slice:
+		// Sort slice elements
+		elements := make([]interface{}, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			elements[i] = Normalize(val.Index(i).Interface(), opts)
+		}
+
+		// Only sort slices that are not lists of dictionaries with identifiers,
+		// and keep the authored order altogether in raw mode
+		if !opts.Raw && !IsKeyedList(elements, opts.IDKeys) {
+			// Sort by string representation for consistency
+			sort.Slice(elements, func(i, j int) bool {
+				return fmt.Sprintf("%v", elements[i]) < fmt.Sprintf("%v", elements[j])
+			})
+		}
+		return elements
+
+	default:
+		return v
+	}
+}
+
+// mapKeys returns the keys of a map sorted by their string representation, so
+// changes are found in the same order on every run
+func mapKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprintf("%v", keys[i]) < fmt.Sprintf("%v", keys[j])
+	})
+	return keys
+}
+
+// uniqueKeys returns keys in order without repeats
+func uniqueKeys(keys []string) []string {
+	seen := make(map[string]bool, len(keys))
+	unique := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			unique = append(unique, key)
+		}
+	}
+	return unique
+}
+
+// sortedKeys returns the keys of a set in sorted order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,342 @@
+package ymldiff
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// TestMaxDepth tests reporting changed maps and lists at MaxDepth as one modification
+func TestMaxDepth(t *testing.T) {
+	opts := DefaultOptions()
+	oldDoc := Normalize(map[string]interface{}{
+		"kind": "Deployment",
+		"spec": map[string]interface{}{
+			"replicas": 2,
+			"template": map[string]interface{}{"labels": map[string]interface{}{"app": "web", "tier": "a"}},
+			"selector": map[string]interface{}{"app": "web"},
+		},
+	}, opts)
+	newDoc := Normalize(map[string]interface{}{
+		"kind": "StatefulSet",
+		"spec": map[string]interface{}{
+			"replicas": 3,
+			"template": map[string]interface{}{"labels": map[string]interface{}{"app": "web", "tier": "b"}, "paused": true},
+			"selector": map[string]interface{}{"app": "web"},
+		},
+	}, opts)
+
+	opts.MaxDepth = 2
+	changes := Diff(oldDoc, newDoc, opts)
+	paths := map[string]ChangeType{}
+	for _, change := range changes {
+		paths[change.Path] = change.Type
+	}
+	expected := map[string]ChangeType{".kind": Modification, ".spec.replicas": Modification, ".spec.template": Modification}
+	if len(paths) != len(expected) {
+		t.Fatalf("Expected changes at %v, got %v", expected, paths)
+	}
+	for path, changeType := range expected {
+		if paths[path] != changeType {
+			t.Errorf("Expected a %s at %s, got %v", changeType, path, paths)
+		}
+	}
+
+	opts.MaxDepth = 0
+	if changes := Diff(oldDoc, newDoc, opts); len(changes) != 4 {
+		t.Errorf("Expected every nested change without MaxDepth, got %d", len(changes))
+	}
+}
+
+// TestPathDepth tests counting the segments of a path
+func TestPathDepth(t *testing.T) {
+	tests := map[string]int{
+		"":                               0,
+		".spec":                          1,
+		".spec.containers[web].image":    4,
+		".data[0]":                       2,
+		".hosts[db.example.com].address": 3,
+	}
+	for path, expected := range tests {
+		if depth := pathDepth(path); depth != expected {
+			t.Errorf("pathDepth(%q) = %d, expected %d", path, depth, expected)
+		}
+	}
+}
+
+// TestDiffOrder tests that changes are found in the same order on every run:
+// map keys sorted, keyed list items in the order of the lists
+func TestDiffOrder(t *testing.T) {
+	opts := DefaultOptions()
+	oldDoc := Normalize(map[string]interface{}{
+		"b": 1, "a": 1, "c": 1,
+		"items": []interface{}{
+			map[string]interface{}{"name": "z", "v": 1},
+			map[string]interface{}{"name": "m", "v": 1},
+		},
+	}, opts)
+	newDoc := Normalize(map[string]interface{}{
+		"b": 2, "a": 2, "d": 2,
+		"items": []interface{}{
+			map[string]interface{}{"name": "z", "v": 2},
+			map[string]interface{}{"name": "m", "v": 2},
+			map[string]interface{}{"name": "k", "v": 2},
+		},
+	}, opts)
+
+	expected := []string{".a", ".b", ".c", ".items[z].v", ".items[m].v", ".items[k]", ".d"}
+	for run := 0; run < 5; run++ {
+		changes := Diff(oldDoc, newDoc, opts)
+		if len(changes) != len(expected) {
+			t.Fatalf("Expected %d changes, got %v", len(expected), changes)
+		}
+		for i, change := range changes {
+			if change.Path != expected[i] {
+				t.Fatalf("Run %d, change %d: expected %s, got %s", run, i, expected[i], change.Path)
+			}
+		}
+	}
+}
+
+// TestNullIsAbsent tests treating keys set to null as missing
+func TestNullIsAbsent(t *testing.T) {
+	oldDoc := map[interface{}]interface{}{"a": nil, "b": 1, "c": nil}
+	newDoc := map[interface{}]interface{}{"b": 1, "c": 2, "d": nil}
+
+	opts := DefaultOptions()
+	if changes := Diff(oldDoc, newDoc, opts); len(changes) != 3 {
+		t.Errorf("Expected 3 changes with nulls as values, got %v", changes)
+	}
+
+	opts.NullIsAbsent = true
+	changes := Diff(oldDoc, newDoc, opts)
+	if len(changes) != 1 || changes[0].Path != ".c" {
+		t.Errorf("Expected only .c to change, got %v", changes)
+	}
+}
+
+// TestEmptyIsAbsent tests treating keys set to empty maps and lists as missing
+func TestEmptyIsAbsent(t *testing.T) {
+	oldDoc := map[interface{}]interface{}{"a": map[interface{}]interface{}{}, "b": []interface{}{}, "c": nil}
+	newDoc := map[interface{}]interface{}{"d": []interface{}{}, "e": []interface{}{1}}
+
+	opts := DefaultOptions()
+	opts.EmptyIsAbsent = true
+	changes := Diff(oldDoc, newDoc, opts)
+	paths := make(map[string]bool)
+	for _, change := range changes {
+		paths[change.Path] = true
+	}
+	if len(paths) != 2 || !paths[".c"] || !paths[".e"] {
+		t.Errorf("Expected only .c and .e to change, got %v", changes)
+	}
+}
+
+// TestDiffPath tests limiting a comparison to the values at a path
+func TestDiffPath(t *testing.T) {
+	oldDoc := map[interface{}]interface{}{
+		"name": "a",
+		"spec": map[interface{}]interface{}{"ports": []interface{}{map[interface{}]interface{}{"name": "http", "port": 80}}},
+	}
+	newDoc := map[interface{}]interface{}{
+		"name": "b",
+		"spec": map[interface{}]interface{}{"ports": []interface{}{map[interface{}]interface{}{"name": "http", "port": 8080}}},
+	}
+
+	opts := DefaultOptions()
+	if value, ok := Lookup(newDoc, ".spec.ports[http].port", opts); !ok || value != 8080 {
+		t.Errorf("Lookup(.spec.ports[http].port) = %v, %v, expected 8080", value, ok)
+	}
+	if _, ok := Lookup(newDoc, ".spec.missing", opts); ok {
+		t.Error("Expected no value at a missing path")
+	}
+
+	opts.Path = ".spec"
+	changes := Diff(oldDoc, newDoc, opts)
+	if len(changes) != 1 || changes[0].Path != ".spec.ports[http].port" {
+		t.Errorf("Expected only .spec.ports[http].port to change, got %v", changes)
+	}
+}
+
+// TestEqual tests comparing normalized values
+func TestEqual(t *testing.T) {
+	a := Normalize(map[string]interface{}{"a": 1, "b": []interface{}{"x", map[string]interface{}{"c": true}}}, DefaultOptions())
+	b := Normalize(map[string]interface{}{"b": []interface{}{"x", map[string]interface{}{"c": true}}, "a": 1}, DefaultOptions())
+	c := Normalize(map[string]interface{}{"a": "1", "b": []interface{}{"x", map[string]interface{}{"c": true}}}, DefaultOptions())
+	d := Normalize(map[string]interface{}{"a": 1, "b": []interface{}{"x", map[string]interface{}{"c": false}}}, DefaultOptions())
+
+	if !Equal(a, b) {
+		t.Errorf("Expected equal maps to be equal")
+	}
+	if Equal(a, c) {
+		t.Errorf("Expected 1 and \"1\" to differ")
+	}
+	if Equal(a, d) {
+		t.Errorf("Expected a nested change to make the maps differ")
+	}
+	if Equal(a, []interface{}{}) || Equal(map[interface{}]interface{}{}, nil) {
+		t.Errorf("Expected values of different types to differ")
+	}
+	// Swapped entries must not cancel out
+	e := Normalize(map[string]interface{}{"a": 2, "b": 1}, DefaultOptions())
+	f := Normalize(map[string]interface{}{"a": 1, "b": 2}, DefaultOptions())
+	if Equal(e, f) {
+		t.Errorf("Expected maps with swapped values to differ")
+	}
+}
+
+// TestDiffAfterChangeInPlace tests that a map changed in place between two
+// comparisons isn't compared by its hash from the first one
+func TestDiffAfterChangeInPlace(t *testing.T) {
+	a := map[interface{}]interface{}{"x": 1, "y": 2}
+	b := map[interface{}]interface{}{"x": 1, "y": 2}
+	if changes := Diff(a, b, DefaultOptions()); len(changes) != 0 {
+		t.Fatalf("Expected no changes, got %v", changes)
+	}
+	b["y"] = 3
+	if changes := Diff(a, b, DefaultOptions()); len(changes) != 1 {
+		t.Errorf("Expected the in-place change to be found, got %v", changes)
+	}
+}
+
+// TestEqualHashCollision tests that different values whose first hashes
+// collide are told apart by their second hashes
+func TestEqualHashCollision(t *testing.T) {
+	a := map[interface{}]interface{}{"x": 1}
+	b := map[interface{}]interface{}{"x": 2}
+	cache := hashCache{
+		{reflect.Map, reflect.ValueOf(a).Pointer(), 1}: {sum: 42, check: hashCache(nil).hash(a).check, size: 2},
+		{reflect.Map, reflect.ValueOf(b).Pointer(), 1}: {sum: 42, check: hashCache(nil).hash(b).check, size: 2},
+	}
+	if cache.equal(a, b) {
+		t.Error("Expected colliding hashes of different maps not to make them equal")
+	}
+}
+
+// BenchmarkDiffEqualSubtrees benchmarks diffing large documents that differ
+// in a single value, whose equal subtrees are skipped by their hashes
+func BenchmarkDiffEqualSubtrees(b *testing.B) {
+	document := func(replicas int) interface{} {
+		services := make(map[interface{}]interface{})
+		for i := 0; i < 200; i++ {
+			env := make([]interface{}, 50)
+			for j := range env {
+				env[j] = map[interface{}]interface{}{"name": "VAR_" + strconv.Itoa(j), "value": strconv.Itoa(i * j)}
+			}
+			services["service-"+strconv.Itoa(i)] = map[interface{}]interface{}{
+				"image": "registry.example.com/service:" + strconv.Itoa(i),
+				"env":   env,
+			}
+		}
+		return map[interface{}]interface{}{"replicas": replicas, "services": services}
+	}
+	oldDoc, newDoc := document(1), document(2)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if changes := Diff(oldDoc, newDoc, DefaultOptions()); len(changes) != 1 {
+			b.Fatalf("Expected 1 change, got %d", len(changes))
+		}
+	}
+}
+
+// TestReorderedItems tests reporting the keyed list items that moved
+func TestReorderedItems(t *testing.T) {
+	tests := []struct {
+		name     string
+		oldOrder []string
+		newOrder []string
+		expected []Change
+	}{
+		{
+			name:     "one item moved to the end",
+			oldOrder: []string{"a", "b", "c"},
+			newOrder: []string{"b", "c", "a"},
+			expected: []Change{{Type: Move, Path: ".init[a]", OldValue: 1, NewValue: 3}},
+		},
+		{
+			name:     "additions and deletions are not moves",
+			oldOrder: []string{"a", "b", "c"},
+			newOrder: []string{"x", "a", "c"},
+			expected: nil,
+		},
+		{
+			name:     "swap",
+			oldOrder: []string{"a", "b"},
+			newOrder: []string{"b", "a"},
+			expected: []Change{{Type: Move, Path: ".init[a]", OldValue: 1, NewValue: 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if changes := reorderedItems(tt.oldOrder, tt.newOrder, ".init"); !reflect.DeepEqual(changes, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, changes)
+			}
+		})
+	}
+}
+
+// TestSimilarity tests scoring the similarity of two values
+func TestSimilarity(t *testing.T) {
+	a := Normalize(map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 4}, DefaultOptions())
+	b := Normalize(map[string]interface{}{"a": 1, "b": 2, "c": 3, "d": 5}, DefaultOptions())
+	c := Normalize(map[string]interface{}{"x": 1}, DefaultOptions())
+
+	if similarity := Similarity(a, a, DefaultOptions()); similarity != 1 {
+		t.Errorf("Expected equal values to score 1, got %v", similarity)
+	}
+	if similarity := Similarity(a, b, DefaultOptions()); similarity != 0.75 {
+		t.Errorf("Expected 0.75, got %v", similarity)
+	}
+	if similarity := Similarity(a, c, DefaultOptions()); similarity != 0 {
+		t.Errorf("Expected unrelated values to score 0, got %v", similarity)
+	}
+}
+
+// TestCandidateIDKeys tests that only fields with a distinct scalar per item are suggested
+func TestCandidateIDKeys(t *testing.T) {
+	items := Normalize([]interface{}{
+		map[string]interface{}{"host": "a", "port": 1, "tags": []interface{}{"x"}},
+		map[string]interface{}{"host": "b", "port": 1, "tags": []interface{}{"y"}},
+	}, DefaultOptions()).([]interface{})
+
+	candidates := candidateIDKeys(items)
+	if len(candidates) != 1 || candidates[0] != "host" {
+		t.Errorf("Expected [host], got %v", candidates)
+	}
+}
+
+// TestCompare tests that documents are paired by position and the result
+// holds their changes, totals and warnings
+func TestCompare(t *testing.T) {
+	opts := DefaultOptions()
+	oldDocs, err := Parse([]byte("a: 1\nrules: [{port: 1}]\n---\nb: 1\n"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newDocs, err := Parse([]byte("a: 2\nrules: [{port: 2}]\n---\nb: 1\n---\nc: 1\n"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var warned []string
+	opts.Warn = func(path, message string) { warned = append(warned, path) }
+	result := Compare(oldDocs, newDocs, opts)
+
+	if result.TotalDocuments != 3 || len(result.Documents) != 3 || result.ChangedDocuments != 2 {
+		t.Errorf("Unexpected document totals: %d of %d documents changed", result.ChangedDocuments, result.TotalDocuments)
+	}
+	if pair := result.Documents[2].Pair; pair.Old != -1 || pair.New != 2 || pair.IsMoved() {
+		t.Errorf("Expected the third document to be added, got %+v", pair)
+	}
+	if result.Counts.Modifications != 1 || result.Counts.Additions != 2 || result.Counts.Deletions != 1 || result.Counts.Total() != 4 {
+		t.Errorf("Unexpected counts %+v", result.Counts)
+	}
+	if result.Documents[0].Counts.Total() != 3 || result.Documents[1].Counts.Total() != 0 || len(result.Changes()) != 4 {
+		t.Errorf("Unexpected document counts %+v, %+v", result.Documents[0].Counts, result.Documents[1].Counts)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Path != ".rules" || len(warned) == 0 {
+		t.Errorf("Expected a warning for .rules, got %+v", result.Warnings)
+	}
+}
@@ -0,0 +1,20 @@
+// Package ymldiff computes semantic differences between YAML documents.
+//
+// Documents are parsed with Parse, which normalizes them so that key order
+// and the order of unkeyed lists don't matter, and compared with Diff, which
+// returns the changes turning one document into the other. Items of lists of
+// maps are matched by an identifier field (name, key or id by default) or by
+// content similarity, so inserting an item doesn't report every item after it.
+//
+//	oldDocs, err := ymldiff.Parse(oldYAML, ymldiff.DefaultOptions())
+//	...
+//	newDocs, err := ymldiff.Parse(newYAML, ymldiff.DefaultOptions())
+//	...
+//	for _, change := range ymldiff.Diff(oldDocs[0].Data, newDocs[0].Data, ymldiff.DefaultOptions()) {
+//		fmt.Println(change.Type, change.Path)
+//	}
+//
+// Compare compares all documents of both files at once and returns a Result
+// with the changes of each pair of documents, their totals and the warnings.
+// Options.Path limits both to the values at one path, as Lookup finds them.
+package ymldiff
@@ -0,0 +1,87 @@
+package ymldiff
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationUnits are the units of durations written as in Go, with days and weeks
+var durationUnits = map[string]time.Duration{
+	"ns": time.Nanosecond,
+	"us": time.Microsecond,
+	"µs": time.Microsecond,
+	"ms": time.Millisecond,
+	"s":  time.Second,
+	"m":  time.Minute,
+	"h":  time.Hour,
+	"d":  24 * time.Hour,
+	"w":  7 * 24 * time.Hour,
+}
+
+// durationPart matches one number and unit of a duration such as 1h30m
+var durationPart = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?|\.[0-9]+)(ns|us|µs|ms|s|m|h|d|w)`)
+
+// isoDurationPattern matches an ISO 8601 duration of weeks, days and time,
+// such as PT1M or P1DT12H; years and months are left out as their length varies
+var isoDurationPattern = regexp.MustCompile(`^P(?:([0-9.]+)W)?(?:([0-9.]+)D)?(?:T(?:([0-9.]+)H)?(?:([0-9.]+)M)?(?:([0-9.]+)S)?)?$`)
+
+// parseDuration reads a duration written as in Go (60s, 1h30m, 2d) or in
+// ISO 8601 (PT1M)
+func parseDuration(s string) (time.Duration, bool) {
+	s = strings.TrimSpace(s)
+	if match := isoDurationPattern.FindStringSubmatch(s); match != nil {
+		if s == "P" || strings.HasSuffix(s, "T") {
+			return 0, false
+		}
+		var total float64
+		for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+			if match[i+1] == "" {
+				continue
+			}
+			amount, err := strconv.ParseFloat(match[i+1], 64)
+			if err != nil {
+				return 0, false
+			}
+			total += amount * float64(unit)
+		}
+		return time.Duration(math.Round(total)), true
+	}
+
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimLeft(s, "+-")
+	if s == "" {
+		return 0, false
+	}
+	var total float64
+	for s != "" {
+		match := durationPart.FindStringSubmatch(s)
+		if match == nil {
+			return 0, false
+		}
+		amount, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			return 0, false
+		}
+		total += amount * float64(durationUnits[match[2]])
+		s = s[len(match[0]):]
+	}
+	if negative {
+		total = -total
+	}
+	return time.Duration(math.Round(total)), true
+}
+
+// durationsEqual checks if two strings are durations of the same length
+func durationsEqual(a, b interface{}) bool {
+	stringA, okA := a.(string)
+	stringB, okB := b.(string)
+	if !okA || !okB {
+		return false
+	}
+	durationA, okA := parseDuration(stringA)
+	durationB, okB := parseDuration(stringB)
+	return okA && okB && durationA == durationB
+}
@@ -0,0 +1,49 @@
+package ymldiff
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EmbeddedSeparator joins the path of a string holding YAML to the paths of
+// the changes inside it, e.g. .data.app.yaml|.server.port
+const EmbeddedSeparator = "|"
+
+// DecodeEmbedded parses a string holding a YAML or JSON map or list and
+// normalizes it for comparison. Single-line strings only count when they
+// start like a JSON object or array, so values like "note: see below" stay
+// strings.
+func DecodeEmbedded(s string, opts Options) (interface{}, bool) {
+	trimmed := strings.TrimSpace(s)
+	if !strings.Contains(trimmed, "\n") && !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return nil, false
+	}
+	var value interface{}
+	if err := yaml.Unmarshal([]byte(s), &value); err != nil {
+		return nil, false
+	}
+	switch value.(type) {
+	case map[string]interface{}, map[interface{}]interface{}, []interface{}:
+		return Normalize(value, opts), true
+	default:
+		return nil, false
+	}
+}
+
+// diffEmbedded compares two strings that both hold YAML structurally, with
+// the paths inside them below path and EmbeddedSeparator
+func (o Options) diffEmbedded(oldVal, newVal interface{}, path string) ([]Change, bool) {
+	oldStr, oldOK := oldVal.(string)
+	newStr, newOK := newVal.(string)
+	if !oldOK || !newOK {
+		return nil, false
+	}
+	oldParsed, oldOK := DecodeEmbedded(oldStr, o)
+	newParsed, newOK := DecodeEmbedded(newStr, o)
+	if !oldOK || !newOK {
+		return nil, false
+	}
+	o.debugf("%s: string holding YAML compared structurally", DisplayPath(path))
+	return o.diffValues(oldParsed, newParsed, path+EmbeddedSeparator), true
+}
@@ -0,0 +1,184 @@
+package ymldiff
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ParseError is an error in the content of an input file, located by line and
+// column when the parser reports them
+type ParseError struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+// Error formats the error as file:line:column: message
+func (e *ParseError) Error() string {
+	location := e.File
+	if e.Line > 0 {
+		location += ":" + strconv.Itoa(e.Line)
+		if e.Column > 0 {
+			location += ":" + strconv.Itoa(e.Column)
+		}
+	}
+	if location == "" {
+		return e.Message
+	}
+	return location + ": " + e.Message
+}
+
+// yamlErrorPattern matches the errors of the YAML parser and the line they
+// give, if any
+var yamlErrorPattern = regexp.MustCompile(`^yaml: (?:line (\d+): )?(.*)$`)
+
+// Locate attributes an error returned by Parse to a file, locating errors of
+// the YAML parser by line. Each of several joined errors is located on its own.
+func Locate(filename string, err error) error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		var errs []error
+		for _, e := range joined.Unwrap() {
+			errs = append(errs, Locate(filename, e))
+		}
+		return errors.Join(errs...)
+	}
+
+	var located *ParseError
+	if errors.As(err, &located) {
+		located.File = filename
+		return located
+	}
+
+	result := &ParseError{File: filename, Message: err.Error()}
+	if match := yamlErrorPattern.FindStringSubmatch(err.Error()); match != nil {
+		if match[1] != "" {
+			result.Line, _ = strconv.Atoi(match[1])
+		}
+		result.Message = match[2]
+	}
+	return result
+}
+
+// documentErrors parses each document of YAML content on its own after the
+// decoder stopped at the first error, so the errors of all broken documents
+// are reported at once
+func documentErrors(data []byte, first error) error {
+	lines := strings.SplitAfter(string(data), "\n")
+	var errs []error
+	start := 0
+	parseDocument := func(end int) {
+		decoder := yaml.NewDecoder(strings.NewReader(strings.Join(lines[start:end], "")))
+		for {
+			var node yaml.Node
+			err := decoder.Decode(&node)
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				for _, located := range locateSyntaxErrors(strings.Join(lines[start:end], ""), err) {
+					located.Line += start
+					errs = append(errs, located)
+				}
+				return
+			}
+		}
+	}
+	for i, line := range lines {
+		if i > start && isDocumentStart(line) {
+			parseDocument(i)
+			start = i
+		}
+	}
+	parseDocument(len(lines))
+
+	if len(errs) == 0 {
+		return first
+	}
+	return errors.Join(errs...)
+}
+
+// isDocumentStart checks if a line starts a YAML document with "---"
+func isDocumentStart(line string) bool {
+	line = strings.TrimRight(line, "\r\n")
+	return line == "---" || strings.HasPrefix(line, "--- ") || strings.HasPrefix(line, "---\t")
+}
+
+// typeErrorPattern matches the location of each error of the YAML decoder
+var typeErrorPattern = regexp.MustCompile(`^line (\d+): (.*)$`)
+
+// locateSyntaxErrors locates by line and column the errors the YAML parser
+// failed with on a document. The parser only reports a line, counted from 0
+// for some errors and left out on the first line, but it stops at the
+// problem, so the shortest content failing with the same message ends at it.
+func locateSyntaxErrors(content string, err error) []*ParseError {
+	lines := strings.SplitAfter(content, "\n")
+
+	// Decoding errors are listed with their lines
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		var errs []*ParseError
+		for _, message := range typeErr.Errors {
+			located := &ParseError{Line: 1, Column: 1, Message: message}
+			if match := typeErrorPattern.FindStringSubmatch(message); match != nil {
+				located.Line, _ = strconv.Atoi(match[1])
+				located.Message = match[2]
+				if located.Line <= len(lines) {
+					line := lines[located.Line-1]
+					located.Column = len([]rune(line)) - len([]rune(strings.TrimLeft(line, " \t"))) + 1
+				}
+			}
+			errs = append(errs, located)
+		}
+		return errs
+	}
+
+	located := Locate("", err).(*ParseError)
+	fails := func(end int) bool {
+		err := decodeDocuments(content[:end])
+		return err != nil && Locate("", err).(*ParseError).Message == located.Message
+	}
+	ends := make([]int, len(lines))
+	offset := 0
+	for i, line := range lines {
+		offset += len(line)
+		ends[i] = offset
+	}
+	// The line given by the parser, if any, is at most one past the problem,
+	// which is usually close after it
+	low := min(max(located.Line-2, 0), len(lines)-1)
+	high := low
+	for step := 1; !fails(ends[high]); step *= 2 {
+		if high == len(lines)-1 {
+			return []*ParseError{located}
+		}
+		low = high + 1
+		high = min(high+step, len(lines)-1)
+	}
+	line := low + sort.Search(high-low, func(i int) bool { return fails(ends[low+i]) })
+	start := ends[line] - len(lines[line])
+	end := start + 1 + sort.Search(len(lines[line])-1, func(i int) bool { return fails(start + 1 + i) })
+	located.Line = line + 1
+	located.Column = len([]rune(content[start:end-1])) + 1
+	return []*ParseError{located}
+}
+
+// decodeDocuments decodes every document of YAML content, returning the first error
+func decodeDocuments(content string) error {
+	decoder := yaml.NewDecoder(strings.NewReader(content))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package ymldiff
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// hashCache memoizes the hashes of the maps and lists of the documents of
+// one comparison, so each subtree is hashed once. It lives only as long as
+// that comparison, during which the documents must not change.
+type hashCache map[valueIdentity]subtreeHash
+
+// valueIdentity identifies a map or list by its address
+type valueIdentity struct {
+	kind    reflect.Kind
+	pointer uintptr
+	length  int
+}
+
+// subtreeHash is the hash of a value along with a second hash, computed from
+// a different hash of its scalars and combined differently, and the number of
+// values in it. Equal values share all three, while different ones sharing
+// them by chance are far too unlikely to be told apart by walking them.
+type subtreeHash struct {
+	sum   uint64
+	check uint64
+	size  int
+}
+
+// Equal checks if two normalized values are equal
+func Equal(a, b interface{}) bool {
+	return hashCache(nil).equal(a, b)
+}
+
+// Hash returns a hash of a normalized value. Map hashes don't depend on the
+// order of their keys.
+func Hash(v interface{}) uint64 {
+	return hashCache(nil).hash(v).sum
+}
+
+// equal checks if two normalized values are equal. Maps and lists are equal
+// when their hashes are, without walking them.
+func (c hashCache) equal(a, b interface{}) bool {
+	switch a.(type) {
+	case map[interface{}]interface{}, []interface{}:
+		return reflect.TypeOf(a) == reflect.TypeOf(b) && c.hash(a) == c.hash(b)
+	}
+	return deepEqual(a, b)
+}
+
+// hash returns the hash of a normalized value, memoizing the hashes of maps
+// and lists unless the cache is nil
+func (c hashCache) hash(v interface{}) subtreeHash {
+	var identity valueIdentity
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		identity = valueIdentity{reflect.Map, reflect.ValueOf(val).Pointer(), len(val)}
+	case []interface{}:
+		identity = valueIdentity{reflect.Slice, reflect.ValueOf(val).Pointer(), len(val)}
+	default:
+		return hashScalar(v)
+	}
+	if cached, ok := c[identity]; ok {
+		return cached
+	}
+
+	hash := subtreeHash{size: 1}
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		hash.sum = mixHash(uint64(len(val)) + 'm')
+		hash.check = mixHash(uint64(len(val)) ^ checkSeed)
+		for key, child := range val {
+			keyHash, childHash := c.hash(key), c.hash(child)
+			hash.sum += mixHash(keyHash.sum*31 + childHash.sum)
+			hash.check ^= mixHash(keyHash.check*37 ^ childHash.check)
+			hash.size += childHash.size
+		}
+	case []interface{}:
+		hash.sum = mixHash(uint64(len(val)) + 'l')
+		hash.check = mixHash(uint64(len(val)) ^ checkSeed)
+		for _, child := range val {
+			childHash := c.hash(child)
+			hash.sum = mixHash(hash.sum*31 + childHash.sum)
+			hash.check = mixHash(hash.check*37 ^ childHash.check)
+			hash.size += childHash.size
+		}
+	}
+	if c != nil {
+		c[identity] = hash
+	}
+	return hash
+}
+
+// deepEqual compares two normalized values entry by entry. Times are equal
+// when written the same, as they are hashed.
+func deepEqual(a, b interface{}) bool {
+	switch valA := a.(type) {
+	case map[interface{}]interface{}:
+		valB, ok := b.(map[interface{}]interface{})
+		if !ok || len(valA) != len(valB) {
+			return false
+		}
+		for key, childA := range valA {
+			childB, exists := valB[key]
+			if !exists || !deepEqual(childA, childB) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		valB, ok := b.([]interface{})
+		if !ok || len(valA) != len(valB) {
+			return false
+		}
+		for i := range valA {
+			if !deepEqual(valA[i], valB[i]) {
+				return false
+			}
+		}
+		return true
+	case time.Time:
+		valB, ok := b.(time.Time)
+		return ok && valA.Format(time.RFC3339Nano) == valB.Format(time.RFC3339Nano)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// checkSeed sets the second hashes of maps and lists apart from the first ones
+const checkSeed = 0x9e3779b97f4a7c15
+
+// The offset basis and prime of 64-bit FNV hashes
+const (
+	fnvOffset = 14695981039346656037
+	fnvPrime  = 1099511628211
+)
+
+// hashScalar hashes a scalar value along with its type, so 1 and "1" differ
+func hashScalar(v interface{}) subtreeHash {
+	var buf [32]byte
+	switch val := v.(type) {
+	case nil:
+		return hashText(0, "")
+	case string:
+		return hashText(1, val)
+	case int:
+		return hashText(2, string(strconv.AppendInt(buf[:0], int64(val), 10)))
+	case float64:
+		return hashText(3, string(strconv.AppendUint(buf[:0], math.Float64bits(val), 16)))
+	case bool:
+		return hashText(4, strconv.FormatBool(val))
+	case time.Time:
+		return hashText(5, val.Format(time.RFC3339Nano))
+	default:
+		return hashText(6, fmt.Sprintf("%T:%v", val, val))
+	}
+}
+
+// hashText hashes the text of a scalar of a kind with FNV-1a, and computes
+// its second hash with FNV-1
+func hashText(kind byte, text string) subtreeHash {
+	sum, check := uint64(fnvOffset), uint64(fnvOffset)
+	sum = (sum ^ uint64(kind)) * fnvPrime
+	check = check*fnvPrime ^ uint64(kind)
+	for i := 0; i < len(text); i++ {
+		sum = (sum ^ uint64(text[i])) * fnvPrime
+		check = check*fnvPrime ^ uint64(text[i])
+	}
+	return subtreeHash{sum: sum, check: check, size: 1}
+}
+
+// mixHash scrambles the bits of a hash (the splitmix64 finalizer), so sums
+// of entry hashes don't cancel out
+func mixHash(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
@@ -0,0 +1,30 @@
+package ymldiff
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ignoredValuesEqual checks if two scalars are the same once the parts
+// matching the IgnoreValues patterns are left out, e.g. two image references
+// differing only in their digest. Values without such parts aren't compared.
+func (o Options) ignoredValuesEqual(a, b interface{}) bool {
+	for _, v := range []interface{}{a, b} {
+		switch v.(type) {
+		case nil, map[interface{}]interface{}, []interface{}:
+			return false
+		}
+	}
+	textA, textB := fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)
+	maskedA, maskedB := maskPatterns(o.IgnoreValues, textA), maskPatterns(o.IgnoreValues, textB)
+	return maskedA == maskedB && maskedA != textA
+}
+
+// maskPatterns replaces the parts of a text matching any of the patterns
+// with a marker that can't appear in YAML text
+func maskPatterns(patterns []*regexp.Regexp, text string) string {
+	for _, pattern := range patterns {
+		text = pattern.ReplaceAllLiteralString(text, "\x00")
+	}
+	return text
+}
@@ -0,0 +1,53 @@
+package ymldiff
+
+import (
+	"fmt"
+	"sort"
+)
+
+// isSliceOfScalars checks if a list holds no maps or lists
+func isSliceOfScalars(slice []interface{}) bool {
+	for _, item := range slice {
+		switch item.(type) {
+		case map[interface{}]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// diffMultiset compares two lists of scalars as bags, reporting each value
+// whose number of occurrences changed once, with the count of items added or
+// removed. The changes are labelled with the list path followed by "[]".
+func diffMultiset(oldSlice, newSlice []interface{}, path string) []Change {
+	counts := make(map[interface{}]int)
+	var values []interface{}
+	for _, item := range oldSlice {
+		if _, seen := counts[item]; !seen {
+			values = append(values, item)
+		}
+		counts[item]--
+	}
+	for _, item := range newSlice {
+		if _, seen := counts[item]; !seen {
+			values = append(values, item)
+		}
+		counts[item]++
+	}
+
+	// Values are reported in a stable order, as they all share the same path
+	sort.SliceStable(values, func(i, j int) bool {
+		return fmt.Sprintf("%v", values[i]) < fmt.Sprintf("%v", values[j])
+	})
+
+	var changes []Change
+	for _, value := range values {
+		switch count := counts[value]; {
+		case count > 0:
+			changes = append(changes, Change{Type: Addition, Path: path + "[]", NewValue: value, Count: count})
+		case count < 0:
+			changes = append(changes, Change{Type: Deletion, Path: path + "[]", OldValue: value, Count: -count})
+		}
+	}
+	return changes
+}
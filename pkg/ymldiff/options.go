@@ -0,0 +1,104 @@
+package ymldiff
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// DefaultIDKeys lists the identifier fields used to match list items
+var DefaultIDKeys = []string{"name", "key", "id"}
+
+// DefaultItemSimilarity is the default similarity from which two items of a
+// list without identifier fields are considered the same item
+const DefaultItemSimilarity = 0.5
+
+// Options controls how documents are parsed and compared
+type Options struct {
+	// Path limits the comparison of documents to the values at this change
+	// path, such as .spec.template; empty compares whole documents
+	Path string
+	// IDKeys are the identifier fields of list items, in priority order
+	IDKeys []string
+	// Raw disables all normalization: lists are compared by position
+	Raw bool
+	// ItemSimilarity is the similarity from which items of lists of maps
+	// without identifier fields are paired; 0 compares them by position
+	ItemSimilarity float64
+	// Multiset compares lists of scalars as bags, with counts
+	Multiset bool
+	// DetectReorders reports keyed list items that changed position
+	DetectReorders bool
+	// Strict fails parsing on unknown tags and map keys that are maps or lists
+	Strict bool
+	// MaxDepth reports changed maps and lists nested this many path segments
+	// deep as a single modification; 0 reports every change
+	MaxDepth int
+	// ParseEmbedded compares strings that both hold YAML or JSON maps or
+	// lists structurally, reporting changes below EmbeddedSeparator
+	ParseEmbedded bool
+	// DateRules and Equivalences make differently written values equal
+	DateRules    []DateRule
+	Equivalences []EquivalenceRule
+	// Epsilon is the difference up to which numbers are equal, unless a
+	// matching tolerance rule sets another
+	Epsilon    float64
+	Tolerances []ToleranceRule
+	// NormalizeQuantities makes quantities of the same amount equal, such as
+	// 1Gi and 1024Mi or 0.5 and 500m
+	NormalizeQuantities bool
+	// NormalizeDurations makes durations of the same length equal, such as
+	// 60s, 1m and PT1M
+	NormalizeDurations bool
+	// YAML11Booleans reads booleans as YAML 1.1 does, so yes, on and true
+	// are equal, and no, off and false; by default they differ as in YAML 1.2
+	YAML11Booleans bool
+	// LooseNumbers makes numbers equal to strings holding them, such as
+	// 8080 and "8080"
+	LooseNumbers bool
+	// TrimStrings makes strings differing only in leading and trailing
+	// whitespace equal, and CollapseWhitespace ones differing only in the
+	// length of runs of whitespace
+	TrimStrings        bool
+	CollapseWhitespace bool
+	// IgnoreValues are left out of scalars before comparing them, so values
+	// differing only in digests, UUIDs or build numbers are equal
+	IgnoreValues []*regexp.Regexp
+	// NullIsAbsent makes a key set to null equal to a missing one, and
+	// EmptyIsAbsent a key set to an empty map or list
+	NullIsAbsent  bool
+	EmptyIsAbsent bool
+	// Warn receives questionable comparison decisions, such as fallbacks
+	Warn func(path, message string)
+	// Debug receives a trace of the matching decisions
+	Debug func(message string)
+
+	// hashes memoizes subtree hashes during one comparison
+	hashes hashCache
+}
+
+// DefaultOptions returns the options used unless configured otherwise
+func DefaultOptions() Options {
+	return Options{IDKeys: DefaultIDKeys, ItemSimilarity: DefaultItemSimilarity}
+}
+
+// debugf writes a line to the debug trace, if there is one
+func (o Options) debugf(format string, args ...interface{}) {
+	if o.Debug != nil {
+		o.Debug(fmt.Sprintf(format, args...))
+	}
+}
+
+// warnf reports a warning about a path, if anyone listens
+func (o Options) warnf(path, format string, args ...interface{}) {
+	if o.Warn != nil {
+		o.Warn(path, fmt.Sprintf(format, args...))
+	}
+}
+
+// DisplayPath formats a change path for messages, naming the document root
+func DisplayPath(path string) string {
+	if path == "" {
+		return "(document)"
+	}
+	return path
+}
@@ -0,0 +1,195 @@
+package ymldiff
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Document holds a parsed document with its comments
+type Document struct {
+	Data     interface{}
+	Comments []string
+	Node     *yaml.Node
+}
+
+// Parse parses and normalizes the documents of YAML content
+func Parse(data []byte, opts Options) ([]Document, error) {
+	var documents []Document
+	var strictErrs []error
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, documentErrors(data, err)
+		}
+
+		// In strict mode, all documents are checked before failing
+		if opts.Strict {
+			if err := strictErrors(&node); err != nil {
+				strictErrs = append(strictErrs, err)
+				continue
+			}
+		}
+
+		// Extract comments from the node
+		comments := extractComments(&node)
+
+		// Convert node to interface{}
+		var doc interface{}
+		if err := node.Decode(&doc); err != nil {
+			return nil, decodeError(data, &node, err)
+		}
+
+		documents = append(documents, Document{
+			Data:     Normalize(doc, opts),
+			Comments: comments,
+			Node:     &node,
+		})
+	}
+
+	if err := errors.Join(strictErrs...); err != nil {
+		return nil, err
+	}
+	return documents, nil
+}
+
+// decodeError locates an error decoding a parsed document: duplicate keys and
+// other errors listed by line, or map keys that are maps or lists
+func decodeError(data []byte, node *yaml.Node, err error) error {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		var errs []error
+		for _, located := range locateSyntaxErrors(string(data), err) {
+			errs = append(errs, located)
+		}
+		return errors.Join(errs...)
+	}
+	located := Locate("", err).(*ParseError)
+	located.Line, located.Column = node.Line, node.Column
+	if key := complexKey(node); key != nil {
+		located.Line, located.Column = key.Line, key.Column
+	}
+	return located
+}
+
+// complexKey finds the first map key of a node that is a map or list
+func complexKey(node *yaml.Node) *yaml.Node {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if key := node.Content[i]; key.Kind == yaml.MappingNode || key.Kind == yaml.SequenceNode {
+				return key
+			}
+		}
+	}
+	for _, child := range node.Content {
+		if key := complexKey(child); key != nil {
+			return key
+		}
+	}
+	return nil
+}
+
+// extractComments recursively extracts all comments from a YAML node
+func extractComments(node *yaml.Node) []string {
+	var comments []string
+
+	if node.HeadComment != "" {
+		lines := strings.Split(strings.TrimSpace(node.HeadComment), "\n")
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				if !strings.HasPrefix(line, "#") {
+					line = "# " + line
+				}
+				comments = append(comments, line)
+			}
+		}
+	}
+
+	if node.LineComment != "" {
+		line := strings.TrimSpace(node.LineComment)
+		if !strings.HasPrefix(line, "#") {
+			line = "# " + line
+		}
+		comments = append(comments, line)
+	}
+
+	if node.FootComment != "" {
+		lines := strings.Split(strings.TrimSpace(node.FootComment), "\n")
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				if !strings.HasPrefix(line, "#") {
+					line = "# " + line
+				}
+				comments = append(comments, line)
+			}
+		}
+	}
+
+	// Recursively extract from children
+	for _, child := range node.Content {
+		comments = append(comments, extractComments(child)...)
+	}
+
+	return comments
+}
+
+// standardTags are the tags whose values are decoded as written
+var standardTags = map[string]bool{
+	"!!str": true, "!!int": true, "!!float": true, "!!bool": true, "!!null": true,
+	"!!map": true, "!!seq": true, "!!timestamp": true, "!!binary": true, "!!merge": true,
+}
+
+// strictErrors lists the nodes of a document that would be coerced when
+// decoded, in source order: values with tags other than the standard ones,
+// whose tag is dropped, and map keys that are maps or lists
+func strictErrors(node *yaml.Node) error {
+	var errs []*ParseError
+	var check func(node *yaml.Node)
+	check = func(node *yaml.Node) {
+		if node.Kind == yaml.AliasNode {
+			// The anchored node was checked where it is defined
+			return
+		}
+		if node.Kind != yaml.DocumentNode && !standardTags[node.ShortTag()] {
+			errs = append(errs, &ParseError{Line: node.Line, Column: node.Column,
+				Message: fmt.Sprintf("unknown tag %s would be dropped", node.Tag)})
+		}
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if key := node.Content[i]; key.Kind == yaml.MappingNode || key.Kind == yaml.SequenceNode {
+					errs = append(errs, &ParseError{Line: key.Line, Column: key.Column,
+						Message: "map key is a map or list and can't be compared"})
+				}
+			}
+		}
+		for _, child := range node.Content {
+			check(child)
+		}
+	}
+	check(node)
+
+	// Map keys are checked along with their map, before the values above them
+	sort.SliceStable(errs, func(i, j int) bool {
+		if errs[i].Line != errs[j].Line {
+			return errs[i].Line < errs[j].Line
+		}
+		return errs[i].Column < errs[j].Column
+	})
+	var joined []error
+	for _, err := range errs {
+		joined = append(joined, err)
+	}
+	return errors.Join(joined...)
+}
@@ -0,0 +1,35 @@
+package ymldiff
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TestExtractComments tests comment extraction from YAML nodes
+func TestExtractComments(t *testing.T) {
+	yamlContent := `# Header comment
+name: John # inline
+age: 30
+# Footer comment`
+
+	var node yaml.Node
+	err := yaml.Unmarshal([]byte(yamlContent), &node)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal YAML: %v", err)
+	}
+
+	comments := extractComments(&node)
+
+	if len(comments) == 0 {
+		t.Error("Expected to extract comments, but got none")
+	}
+
+	// Check that extracted comments have # prefix
+	for _, comment := range comments {
+		if !strings.HasPrefix(comment, "#") {
+			t.Errorf("Expected comment to start with #, got: %s", comment)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package ymldiff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// compiledPatterns caches path patterns translated to regular expressions
+var compiledPatterns = map[string]*regexp.Regexp{}
+
+// compiledPatternsMu guards compiledPatterns, since documents may be diffed concurrently
+var compiledPatternsMu sync.Mutex
+
+// compilePathPattern translates a path glob into a regular expression.
+// "**" matches anything, and as a whole segment also no segment at all, so
+// ".**.password" matches ".password". "*" matches within a single key or list
+// identifier, except at the start of a pattern, where it stands for any number
+// of keys: "*.token" is ".**.token". A pattern also matches everything nested
+// below the path it names.
+func compilePathPattern(pattern string) *regexp.Regexp {
+	compiledPatternsMu.Lock()
+	defer compiledPatternsMu.Unlock()
+	if re, ok := compiledPatterns[pattern]; ok {
+		return re
+	}
+
+	normalized := pattern
+	switch {
+	case strings.HasPrefix(normalized, "*"):
+		normalized = ".**" + strings.TrimLeft(normalized, "*")
+	case !strings.HasPrefix(normalized, ".") && !strings.HasPrefix(normalized, "["):
+		normalized = "." + normalized
+	}
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i := 0; i < len(normalized); i++ {
+		if strings.HasPrefix(normalized[i:], ".**") && i+3 < len(normalized) && (normalized[i+3] == '.' || normalized[i+3] == '[') {
+			expr.WriteString(`(?:[.\[].*)?`)
+			i += 2
+			continue
+		}
+		if normalized[i] == '*' {
+			if i+1 < len(normalized) && normalized[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString(`[^.\[\]]*`)
+			}
+			continue
+		}
+		expr.WriteString(regexp.QuoteMeta(string(normalized[i])))
+	}
+	expr.WriteString(`(?:[.\[].*)?$`)
+
+	re := regexp.MustCompile(expr.String())
+	compiledPatterns[pattern] = re
+	return re
+}
+
+// MatchPath checks if a change path matches a path pattern such as
+// ".metadata.*" or ".status.**"
+func MatchPath(pattern, path string) bool {
+	return compilePathPattern(pattern).MatchString(path)
+}
+
+// pathDepth counts the segments of a path: keys and list items
+func pathDepth(path string) int {
+	depth := 0
+	inBrackets := false
+	for _, r := range path {
+		switch {
+		case r == '[' && !inBrackets:
+			depth++
+			inBrackets = true
+		case r == ']':
+			inBrackets = false
+		case r == '.' && !inBrackets:
+			depth++
+		}
+	}
+	return depth
+}
+
+// IsPathSegment checks if a path starts with the given segments, ending
+// where a key, list item or embedded document ends
+func IsPathSegment(path, segment string) bool {
+	if !strings.HasPrefix(path, segment) {
+		return false
+	}
+	rest := path[len(segment):]
+	return rest == "" || rest[0] == '.' || rest[0] == '[' || strings.HasPrefix(rest, EmbeddedSeparator)
+}
+
+// Lookup returns the value at a change path of a normalized document,
+// following list items and embedded documents as they are compared
+func Lookup(data interface{}, path string, opts Options) (interface{}, bool) {
+	if path == "" {
+		return data, true
+	}
+
+	switch value := data.(type) {
+	case map[interface{}]interface{}:
+		if !strings.HasPrefix(path, ".") {
+			return nil, false
+		}
+		// Pick the longest key matching the start of the path, since keys may contain dots
+		var match interface{}
+		matchLen := -1
+		for key := range value {
+			keyStr := fmt.Sprintf("%v", key)
+			if len(keyStr) > matchLen && IsPathSegment(path[1:], keyStr) {
+				match, matchLen = key, len(keyStr)
+			}
+		}
+		if matchLen < 0 {
+			return nil, false
+		}
+		return Lookup(value[match], path[1+matchLen:], opts)
+
+	case []interface{}:
+		if !strings.HasPrefix(path, "[") {
+			return nil, false
+		}
+		if !opts.Raw && IsKeyedList(value, opts.IDKeys) {
+			for _, item := range value {
+				if _, id, ok := ItemIdentifier(item, opts.IDKeys); ok && IsPathSegment(path[1:], id+"]") {
+					return Lookup(item, path[len(id)+2:], opts)
+				}
+			}
+			return nil, false
+		}
+		end := strings.Index(path, "]")
+		if end < 0 {
+			return nil, false
+		}
+		index, err := strconv.Atoi(path[1:end])
+		if err != nil || index < 0 || index >= len(value) {
+			return nil, false
+		}
+		return Lookup(value[index], path[end+1:], opts)
+
+	case string:
+		// Strings holding YAML are looked into as they are compared
+		if !opts.ParseEmbedded || !strings.HasPrefix(path, EmbeddedSeparator) {
+			return nil, false
+		}
+		embedded, ok := DecodeEmbedded(value, opts)
+		if !ok {
+			return nil, false
+		}
+		return Lookup(embedded, path[len(EmbeddedSeparator):], opts)
+	}
+
+	return nil, false
+}
@@ -0,0 +1,91 @@
+package ymldiff
+
+import (
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// quantityPattern matches a number with an optional exponent and unit, as in
+// Kubernetes resource quantities (500m, 1.5Gi, 1e3) and byte sizes (512MiB, 2GB)
+var quantityPattern = regexp.MustCompile(`^\s*([+-]?(?:[0-9]+\.?[0-9]*|\.[0-9]+)(?:[eE][+-]?[0-9]+)?)\s*([a-zA-Z]*)\s*$`)
+
+// quantityUnits are the multipliers of the units a quantity may end with
+var quantityUnits = map[string]*big.Rat{
+	"":   power(10, 0),
+	"n":  new(big.Rat).Inv(power(10, 9)),
+	"u":  new(big.Rat).Inv(power(10, 6)),
+	"m":  new(big.Rat).Inv(power(10, 3)),
+	"k":  power(10, 3),
+	"K":  power(10, 3),
+	"M":  power(10, 6),
+	"G":  power(10, 9),
+	"T":  power(10, 12),
+	"P":  power(10, 15),
+	"E":  power(10, 18),
+	"Ki": power(2, 10),
+	"Mi": power(2, 20),
+	"Gi": power(2, 30),
+	"Ti": power(2, 40),
+	"Pi": power(2, 50),
+	"Ei": power(2, 60),
+}
+
+// power returns base to the power of exp
+func power(base, exp int64) *big.Rat {
+	return new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(base), big.NewInt(exp), nil))
+}
+
+// parseQuantity reads a number or a string holding a quantity, such as 500m,
+// 1Gi or 512MiB, as an exact value. Byte units may end with B, so 1GiB is 1Gi
+// and 1GB is 1G.
+func parseQuantity(v interface{}) (*big.Rat, bool) {
+	var text string
+	switch n := v.(type) {
+	case int:
+		return new(big.Rat).SetInt64(int64(n)), true
+	case int64:
+		return new(big.Rat).SetInt64(n), true
+	case uint64:
+		return new(big.Rat).SetUint64(n), true
+	case float64:
+		// Read floats as written, so 0.1 equals 100m
+		text = strconv.FormatFloat(n, 'g', -1, 64)
+	case string:
+		text = n
+	default:
+		return nil, false
+	}
+
+	match := quantityPattern.FindStringSubmatch(text)
+	if match == nil {
+		return nil, false
+	}
+	unit := match[2]
+	multiplier, ok := quantityUnits[unit]
+	if !ok && strings.HasSuffix(unit, "B") {
+		multiplier, ok = quantityUnits[strings.TrimSuffix(unit, "B")]
+	}
+	if !ok {
+		return nil, false
+	}
+	value, ok := new(big.Rat).SetString(match[1])
+	if !ok {
+		return nil, false
+	}
+	return value.Mul(value, multiplier), true
+}
+
+// quantitiesEqual checks if two values, at least one of them a string, are
+// quantities of the same amount
+func quantitiesEqual(a, b interface{}) bool {
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if !aString && !bString {
+		return false
+	}
+	quantityA, okA := parseQuantity(a)
+	quantityB, okB := parseQuantity(b)
+	return okA && okB && quantityA.Cmp(quantityB) == 0
+}
@@ -0,0 +1,77 @@
+package ymldiff
+
+// reorderedItems reports the keyed list items that changed position. Items in
+// the longest common subsequence of both orders are considered in place, so
+// moving one item reports that item rather than every item it passed.
+func reorderedItems(oldOrder, newOrder []string, path string) []Change {
+	oldPositions := make(map[string]int, len(oldOrder))
+	for i, id := range oldOrder {
+		oldPositions[id] = i
+	}
+	newPositions := make(map[string]int, len(newOrder))
+	for i, id := range newOrder {
+		newPositions[id] = i
+	}
+
+	// Only items present in both lists can move
+	var oldCommon, newCommon []string
+	for _, id := range oldOrder {
+		if _, ok := newPositions[id]; ok {
+			oldCommon = append(oldCommon, id)
+		}
+	}
+	for _, id := range newOrder {
+		if _, ok := oldPositions[id]; ok {
+			newCommon = append(newCommon, id)
+		}
+	}
+
+	inPlace := longestCommonSubsequence(oldCommon, newCommon)
+	var changes []Change
+	for _, id := range newCommon {
+		if inPlace[id] {
+			continue
+		}
+		changes = append(changes, Change{
+			Type:     Move,
+			Path:     path + "[" + id + "]",
+			OldValue: oldPositions[id] + 1,
+			NewValue: newPositions[id] + 1,
+		})
+	}
+	return changes
+}
+
+// longestCommonSubsequence returns the items of a longest common subsequence of two sequences
+func longestCommonSubsequence(a, b []string) map[string]bool {
+	lengths := make([][]int, len(a)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	result := make(map[string]bool)
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			result[a[i]] = true
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return result
+}
@@ -0,0 +1,142 @@
+package ymldiff
+
+// Result holds everything the comparison of two sets of documents produced:
+// the parsed documents, the changes of every document pair with their totals
+// and the warnings given along the way
+type Result struct {
+	// Inputs describes the compared files, filled in by callers that read them
+	Inputs           []Input
+	Old              []Document
+	New              []Document
+	Documents        []DocumentResult
+	Counts           Counts
+	ChangedDocuments int
+	TotalDocuments   int
+	Warnings         []Warning
+}
+
+// Input describes a compared file
+type Input struct {
+	Label    string `json:"label"`
+	Name     string `json:"name,omitempty"`
+	File     string `json:"file"`
+	Modified string `json:"modified,omitempty"`
+}
+
+// DocumentResult holds the comparison of one pair of documents
+type DocumentResult struct {
+	Index   int // position of the pair, from 0
+	Pair    DocumentPair
+	OldData interface{}
+	NewData interface{}
+	Changes []Change
+	Counts  Counts
+}
+
+// DocumentPair links a document of the first file to its counterpart in the
+// second file. An index of -1 means the document has no counterpart.
+type DocumentPair struct {
+	Old int
+	New int
+}
+
+// IsMoved checks if a pair links documents at different positions of both files
+func (p DocumentPair) IsMoved() bool {
+	return p.Old >= 0 && p.New >= 0 && p.Old != p.New
+}
+
+// Counts holds the number of changes per change type
+type Counts struct {
+	Additions     int
+	Deletions     int
+	Modifications int
+	Moves         int
+}
+
+// Add counts the given changes
+func (c *Counts) Add(changes []Change) {
+	for _, change := range changes {
+		c.AddType(change.Type)
+	}
+}
+
+// AddType counts one change of the given type
+func (c *Counts) AddType(t ChangeType) {
+	switch t {
+	case Addition:
+		c.Additions++
+	case Deletion:
+		c.Deletions++
+	case Modification:
+		c.Modifications++
+	case Move:
+		c.Moves++
+	}
+}
+
+// Total returns the number of changes of all types
+func (c Counts) Total() int {
+	return c.Additions + c.Deletions + c.Modifications + c.Moves
+}
+
+// Warning is a questionable comparison decision, such as a fallback strategy
+type Warning struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// Add appends the comparison of a document pair, counting its changes into
+// the totals
+func (r *Result) Add(document DocumentResult) {
+	document.Counts = Counts{}
+	document.Counts.Add(document.Changes)
+	if len(document.Changes) > 0 {
+		r.Counts.Add(document.Changes)
+		r.ChangedDocuments++
+	}
+	r.Documents = append(r.Documents, document)
+}
+
+// Changes returns the changes of all documents in order
+func (r Result) Changes() []Change {
+	var changes []Change
+	for _, document := range r.Documents {
+		changes = append(changes, document.Changes...)
+	}
+	return changes
+}
+
+// Compare pairs the parsed documents of two files by position and compares
+// each pair. Warnings are collected in the result, each once, and still
+// passed on to Options.Warn.
+func Compare(oldDocs, newDocs []Document, opts Options) Result {
+	result := Result{Old: oldDocs, New: newDocs, TotalDocuments: max(len(oldDocs), len(newDocs))}
+	warn := opts.Warn
+	opts.Warn = func(path, message string) {
+		w := Warning{Path: path, Message: message}
+		for _, existing := range result.Warnings {
+			if existing == w {
+				return
+			}
+		}
+		result.Warnings = append(result.Warnings, w)
+		if warn != nil {
+			warn(path, message)
+		}
+	}
+
+	for i := 0; i < result.TotalDocuments; i++ {
+		document := DocumentResult{Index: i, Pair: DocumentPair{Old: -1, New: -1}}
+		if i < len(oldDocs) {
+			document.Pair.Old = i
+			document.OldData = oldDocs[i].Data
+		}
+		if i < len(newDocs) {
+			document.Pair.New = i
+			document.NewData = newDocs[i].Data
+		}
+		document.Changes = Diff(document.OldData, document.NewData, opts)
+		result.Add(document)
+	}
+	return result
+}
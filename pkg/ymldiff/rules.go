@@ -0,0 +1,229 @@
+package ymldiff
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateRule makes the dates at the paths matching a pattern compare equal
+// whatever format of the rule they are written in
+type DateRule struct {
+	Path    string   `yaml:"path"`
+	Formats []string `yaml:"formats"`
+}
+
+// dateLayouts maps the names of date formats to Go time layouts
+var dateLayouts = map[string]string{
+	"rfc3339":  time.RFC3339Nano,
+	"date":     time.DateOnly,
+	"datetime": time.DateTime,
+}
+
+// ValidateDateRules checks that every date rule has a path and formats
+func ValidateDateRules(rules []DateRule) error {
+	for i, rule := range rules {
+		if rule.Path == "" {
+			return fmt.Errorf("date rule %d: missing path", i+1)
+		}
+		if len(rule.Formats) == 0 {
+			return fmt.Errorf("date rule %s: no formats", rule.Path)
+		}
+	}
+	return nil
+}
+
+// datesEqual checks if two values at a path are the same instant written in
+// formats of a matching date rule
+func datesEqual(rules []DateRule, path string, a, b interface{}) bool {
+	for _, rule := range rules {
+		if !MatchPath(rule.Path, path) {
+			continue
+		}
+		timeA, okA := parseDate(a, rule.Formats)
+		timeB, okB := parseDate(b, rule.Formats)
+		if okA && okB {
+			return timeA.Equal(timeB)
+		}
+	}
+	return false
+}
+
+// parseDate reads a value as a date in any of the formats: named formats,
+// "epoch" and "epoch-millis" for Unix timestamps, or Go time layouts such as
+// "01/02/2006". Timestamps the YAML parser already decoded are taken as is.
+func parseDate(v interface{}, formats []string) (time.Time, bool) {
+	if t, ok := v.(time.Time); ok {
+		return t, true
+	}
+	for _, format := range formats {
+		switch format {
+		case "epoch", "epoch-millis":
+			var seconds float64
+			switch val := v.(type) {
+			case int:
+				seconds = float64(val)
+			case float64:
+				seconds = val
+			case string:
+				parsed, err := strconv.ParseFloat(val, 64)
+				if err != nil {
+					continue
+				}
+				seconds = parsed
+			default:
+				continue
+			}
+			if format == "epoch-millis" {
+				seconds /= 1000
+			}
+			return time.Unix(0, int64(seconds*float64(time.Second))), true
+		default:
+			s, ok := v.(string)
+			if !ok {
+				continue
+			}
+			layout := format
+			if named, ok := dateLayouts[format]; ok {
+				layout = named
+			}
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// EquivalenceRule makes any two values at the paths matching a pattern equal
+// when both match a regular expression, e.g. rotating digests
+type EquivalenceRule struct {
+	Path    string `yaml:"path"`
+	Pattern string `yaml:"pattern"`
+
+	compiled *regexp.Regexp
+}
+
+// CompileEquivalences checks every equivalence rule and compiles its
+// pattern. Rules are only applied once compiled.
+func CompileEquivalences(rules []EquivalenceRule) error {
+	for i := range rules {
+		if rules[i].Path == "" {
+			return fmt.Errorf("equivalence rule %d: missing path", i+1)
+		}
+		compiled, err := regexp.Compile(rules[i].Pattern)
+		if err != nil {
+			return fmt.Errorf("equivalence rule %s: %w", rules[i].Path, err)
+		}
+		rules[i].compiled = compiled
+	}
+	return nil
+}
+
+// equivalentValues checks if two scalars at a path both match the pattern of
+// a matching equivalence rule
+func equivalentValues(rules []EquivalenceRule, path string, a, b interface{}) bool {
+	for _, v := range []interface{}{a, b} {
+		switch v.(type) {
+		case nil, map[interface{}]interface{}, []interface{}:
+			return false
+		}
+	}
+	textA, textB := fmt.Sprintf("%v", a), fmt.Sprintf("%v", b)
+	for _, rule := range rules {
+		if rule.compiled != nil && MatchPath(rule.Path, path) &&
+			rule.compiled.MatchString(textA) && rule.compiled.MatchString(textB) {
+			return true
+		}
+	}
+	return false
+}
+
+// ToleranceRule makes numbers at the paths matching a pattern equal when they
+// differ by at most Epsilon
+type ToleranceRule struct {
+	Path    string  `yaml:"path"`
+	Epsilon float64 `yaml:"epsilon"`
+}
+
+// ValidateTolerances checks that every tolerance rule has a path and a
+// tolerance that isn't negative
+func ValidateTolerances(rules []ToleranceRule) error {
+	for i, rule := range rules {
+		if rule.Path == "" {
+			return fmt.Errorf("tolerance rule %d: missing path", i+1)
+		}
+		if rule.Epsilon < 0 {
+			return fmt.Errorf("tolerance rule %s: epsilon must not be negative", rule.Path)
+		}
+	}
+	return nil
+}
+
+// numbersClose checks if two numbers at a path differ by at most the
+// tolerance of the first matching tolerance rule, or Epsilon if none matches
+func (o Options) numbersClose(path string, a, b interface{}) bool {
+	numberA, okA := toFloat(a)
+	numberB, okB := toFloat(b)
+	if !okA || !okB {
+		return false
+	}
+	epsilon := o.Epsilon
+	for _, rule := range o.Tolerances {
+		if MatchPath(rule.Path, path) {
+			epsilon = rule.Epsilon
+			break
+		}
+	}
+	return math.Abs(numberA-numberB) <= epsilon
+}
+
+// toFloat converts the numbers of parsed documents to float64
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	default:
+		return 0, false
+	}
+}
+
+// looseNumbersEqual checks if a number and a string holding the same number,
+// such as 8080 and "8080", are equal
+func looseNumbersEqual(a, b interface{}) bool {
+	if _, ok := a.(string); ok {
+		a, b = b, a
+	}
+	text, ok := b.(string)
+	if !ok {
+		return false
+	}
+	number, ok := toFloat(a)
+	if !ok {
+		return false
+	}
+	text = strings.TrimSpace(text)
+
+	// Integers are compared exactly, as large ones don't fit a float64
+	switch n := a.(type) {
+	case int:
+		if parsed, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return parsed == int64(n)
+		}
+	case int64:
+		if parsed, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return parsed == n
+		}
+	}
+	parsed, err := strconv.ParseFloat(text, 64)
+	return err == nil && parsed == number
+}
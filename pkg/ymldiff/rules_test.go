@@ -0,0 +1,336 @@
+package ymldiff
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDatesEqual tests comparing dates written in different formats
+func TestDatesEqual(t *testing.T) {
+	rules := []DateRule{
+		{Path: ".created", Formats: []string{"date", "01/02/2006"}},
+		{Path: ".jobs.*.lastRun", Formats: []string{"rfc3339", "epoch"}},
+		{Path: ".expires", Formats: []string{"epoch-millis"}},
+	}
+	newYear := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		path     string
+		a, b     interface{}
+		expected bool
+	}{
+		{".created", "2024-01-01", "01/01/2024", true},
+		{".created", newYear, "01/01/2024", true},
+		{".created", "2024-01-01", "01/02/2024", false},
+		{".created", "2024-01-01", "soon", false},
+		{".jobs.backup.lastRun", "2024-01-01T00:00:00Z", 1704067200, true},
+		{".jobs.backup.lastRun", "2024-01-01T01:00:00+01:00", "1704067200", true},
+		{".jobs.backup.lastRun", "2024-01-01T00:00:00Z", 1704067201, false},
+		{".expires", newYear, 1704067200000, true},
+		{".updated", "2024-01-01", "01/01/2024", false},
+	}
+	for _, tt := range tests {
+		if got := datesEqual(rules, tt.path, tt.a, tt.b); got != tt.expected {
+			t.Errorf("datesEqual(%s, %v, %v) = %v, expected %v", tt.path, tt.a, tt.b, got, tt.expected)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.DateRules = rules
+	changes := Diff(
+		map[interface{}]interface{}{"created": "2024-01-01", "updated": "2024-01-01"},
+		map[interface{}]interface{}{"created": "01/01/2024", "updated": "01/01/2024"}, opts)
+	if len(changes) != 1 || changes[0].Path != ".updated" {
+		t.Errorf("Expected only .updated to change, got %v", changes)
+	}
+}
+
+// TestEquivalentValues tests treating values matching a pattern as equal
+func TestEquivalentValues(t *testing.T) {
+	rules := []EquivalenceRule{
+		{Path: ".containers[*].image", Pattern: "@sha256:[a-f0-9]+$"},
+		{Path: ".revision", Pattern: "^[0-9]+$"},
+	}
+	if err := CompileEquivalences(rules); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path     string
+		a, b     interface{}
+		expected bool
+	}{
+		{".containers[web].image", "nginx@sha256:abc", "nginx@sha256:def", true},
+		{".containers[web].image", "nginx@sha256:abc", "nginx:1.25", false},
+		{".sidecar.image", "nginx@sha256:abc", "nginx@sha256:def", false},
+		{".revision", 41, "42", true},
+		{".revision", 41, nil, false},
+		{".revision", []interface{}{1}, []interface{}{2}, false},
+	}
+	for _, tt := range tests {
+		if got := equivalentValues(rules, tt.path, tt.a, tt.b); got != tt.expected {
+			t.Errorf("equivalentValues(%s, %v, %v) = %v, expected %v", tt.path, tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+// TestNumericTolerance tests treating numbers within a tolerance as equal
+func TestNumericTolerance(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Epsilon = 0.001
+	opts.Tolerances = []ToleranceRule{
+		{Path: ".weights.*", Epsilon: 0.1},
+		{Path: ".exact", Epsilon: 0},
+	}
+
+	changes := Diff(
+		map[interface{}]interface{}{
+			"ratio": 0.5, "count": 3, "weights": map[interface{}]interface{}{"a": 1.0},
+			"exact": 1.0, "name": "x", "far": 2.0,
+		},
+		map[interface{}]interface{}{
+			"ratio": 0.5004, "count": 3.0005, "weights": map[interface{}]interface{}{"a": 1.05},
+			"exact": 1.0001, "name": "y", "far": 2.5,
+		}, opts)
+
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+	sort.Strings(paths)
+	expected := []string{".exact", ".far", ".name"}
+	if strings.Join(paths, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected changes at %v, got %v", expected, paths)
+	}
+}
+
+// TestToleranceValidation tests that invalid tolerance rules are rejected
+func TestToleranceValidation(t *testing.T) {
+	if err := ValidateTolerances([]ToleranceRule{{Epsilon: 1}}); err == nil {
+		t.Error("Expected an error for a rule without a path")
+	}
+	if err := ValidateTolerances([]ToleranceRule{{Path: ".a", Epsilon: -1}}); err == nil {
+		t.Error("Expected an error for a negative epsilon")
+	}
+}
+
+// TestLooseNumbers tests treating numbers and strings holding them as equal
+func TestLooseNumbers(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected bool
+	}{
+		{"8080", 8080, true},
+		{8080, "8080", true},
+		{" 8080 ", 8080, true},
+		{"1.5", 1.5, true},
+		{"1e3", 1000, true},
+		{"8080.0", 8080, true},
+		{"9007199254740993", int64(9007199254740992), false},
+		{"8081", 8080, false},
+		{"8080", "8080.0", false},
+		{"port", 8080, false},
+		{8080, 8080.5, false},
+	}
+	for _, tt := range tests {
+		if got := looseNumbersEqual(tt.a, tt.b); got != tt.expected {
+			t.Errorf("looseNumbersEqual(%v, %v) = %v, expected %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+// TestYAML11Booleans tests comparing booleans as YAML 1.1 and YAML 1.2 read them
+func TestYAML11Booleans(t *testing.T) {
+	oldDoc := map[interface{}]interface{}{"a": "yes", "b": "NO", "c": "on", "d": true, "e": "yes"}
+	newDoc := map[interface{}]interface{}{"a": true, "b": false, "c": "ON", "d": "Y", "e": "off"}
+
+	opts := DefaultOptions()
+	if changes := Diff(oldDoc, newDoc, opts); len(changes) != 5 {
+		t.Errorf("Expected all 5 values to differ as in YAML 1.2, got %v", changes)
+	}
+
+	opts.YAML11Booleans = true
+	changes := Diff(oldDoc, newDoc, opts)
+	if len(changes) != 1 || changes[0].Path != ".e" {
+		t.Errorf("Expected only .e to change as in YAML 1.1, got %v", changes)
+	}
+}
+
+// TestParseDuration tests reading durations written as in Go and in ISO 8601
+func TestParseDuration(t *testing.T) {
+	valid := map[string]time.Duration{
+		"60s":      time.Minute,
+		"1m":       time.Minute,
+		"PT1M":     time.Minute,
+		"1h30m":    90 * time.Minute,
+		"1.5h":     90 * time.Minute,
+		"P1DT12H":  36 * time.Hour,
+		"2d":       48 * time.Hour,
+		"P1W":      7 * 24 * time.Hour,
+		"PT0.5S":   500 * time.Millisecond,
+		"500ms":    500 * time.Millisecond,
+		"-30s":     -30 * time.Second,
+		" 10m ":    10 * time.Minute,
+		"1h0m0.5s": time.Hour + 500*time.Millisecond,
+	}
+	for s, expected := range valid {
+		if got, ok := parseDuration(s); !ok || got != expected {
+			t.Errorf("parseDuration(%q) = %v, %v, expected %v", s, got, ok, expected)
+		}
+	}
+
+	for _, s := range []string{"", "60", "P", "PT", "P1M", "1y", "s", "1h foo", "abc"} {
+		if _, ok := parseDuration(s); ok {
+			t.Errorf("parseDuration(%q): expected it not to be a duration", s)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.NormalizeDurations = true
+	changes := Diff(
+		map[interface{}]interface{}{"timeout": "60s", "interval": "PT5M", "retry": "10s", "count": "60"},
+		map[interface{}]interface{}{"timeout": "1m", "interval": "300s", "retry": "20s", "count": "1m"}, opts)
+	var paths []string
+	for _, change := range changes {
+		paths = append(paths, change.Path)
+	}
+	sort.Strings(paths)
+	if strings.Join(paths, " ") != ".count .retry" {
+		t.Errorf("Expected .count and .retry to change, got %v", paths)
+	}
+}
+
+// TestQuantitiesEqual tests comparing quantities written with different units
+func TestQuantitiesEqual(t *testing.T) {
+	tests := []struct {
+		a, b     interface{}
+		expected bool
+	}{
+		{"1Gi", "1024Mi", true},
+		{0.5, "500m", true},
+		{"0.1", "100m", true},
+		{"1GiB", "1Gi", true},
+		{"1GB", "1000MB", true},
+		{"512B", 512, true},
+		{"1e3", "1k", true},
+		{"2", 2, true},
+		{"1Gi", "1G", false},
+		{"1Gi", "1000Mi", false},
+		{"500m", "0.6", false},
+		{"1Xi", "1Xi0", false},
+		{"abc", "abc ", false},
+		{1, 1.0, false},
+	}
+	for _, tt := range tests {
+		if got := quantitiesEqual(tt.a, tt.b); got != tt.expected {
+			t.Errorf("quantitiesEqual(%v, %v) = %v, expected %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.NormalizeQuantities = true
+	changes := Diff(
+		map[interface{}]interface{}{"memory": "1Gi", "cpu": 0.5, "disk": "10Gi"},
+		map[interface{}]interface{}{"memory": "1024Mi", "cpu": "500m", "disk": "20Gi"}, opts)
+	if len(changes) != 1 || changes[0].Path != ".disk" {
+		t.Errorf("Expected only .disk to change, got %v", changes)
+	}
+}
+
+// TestWhitespaceEqual tests comparing strings without some of their whitespace
+func TestWhitespaceEqual(t *testing.T) {
+	tests := []struct {
+		trim, collapse bool
+		a, b           string
+		expected       bool
+	}{
+		{true, false, "  app ", "app", true},
+		{true, false, "app\n", "app", true},
+		{true, false, "a  b", "a b", false},
+		{false, true, "a  b", "a b", true},
+		{false, true, "a\n\tb", "a b", true},
+		{false, true, " a", "a", false},
+		{true, true, "  a \n b  ", "a b", true},
+		{true, true, "a b", "ab", false},
+	}
+	for _, tt := range tests {
+		opts := Options{TrimStrings: tt.trim, CollapseWhitespace: tt.collapse}
+		if got := opts.whitespaceEqual(tt.a, tt.b); got != tt.expected {
+			t.Errorf("whitespaceEqual(%q, %q) with trim %v, collapse %v = %v, expected %v",
+				tt.a, tt.b, tt.trim, tt.collapse, got, tt.expected)
+		}
+	}
+}
+
+// TestIgnoredValuesEqual tests comparing values without the parts matching patterns
+func TestIgnoredValuesEqual(t *testing.T) {
+	opts := Options{IgnoreValues: []*regexp.Regexp{
+		regexp.MustCompile(`sha256:[0-9a-f]+`),
+		regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`),
+	}}
+	tests := []struct {
+		a, b     interface{}
+		expected bool
+	}{
+		{"nginx@sha256:abc123", "nginx@sha256:def456", true},
+		{"nginx@sha256:abc123", "redis@sha256:def456", false},
+		{"nginx@sha256:abc123", "nginx:1.25", false},
+		{"0e4b1c2d-1111-2222-3333-444455556666", "9f8e7d6c-aaaa-bbbb-cccc-ddddeeeeffff", true},
+		{"1", 1, false},
+		{nil, "sha256:abc", false},
+	}
+	for _, tt := range tests {
+		if got := opts.ignoredValuesEqual(tt.a, tt.b); got != tt.expected {
+			t.Errorf("ignoredValuesEqual(%v, %v) = %v, expected %v", tt.a, tt.b, got, tt.expected)
+		}
+	}
+}
+
+// TestParseEmbedded tests comparing strings holding YAML or JSON structurally,
+// including JSON indented with tabs
+func TestParseEmbedded(t *testing.T) {
+	opts := DefaultOptions()
+	opts.ParseEmbedded = true
+	oldDoc := Normalize(map[string]interface{}{
+		"app.yaml":         "server:\n  port: 80\n  host: a\n",
+		"reformatted.yaml": "a: 1\nb: [x, y]\n",
+		"settings":         `{"debug": false}`,
+		"pretty.json":      "{\n\t\"replicas\": 2,\n\t\"zones\": [\n\t\t\"a\"\n\t]\n}",
+		"note":             "level: low",
+		"script":           "echo a\necho b\n",
+	}, opts)
+	newDoc := Normalize(map[string]interface{}{
+		"app.yaml":         "server:\n  host: a\n  port: 8080\n",
+		"reformatted.yaml": "b:\n  - y\n  - x\na: 1\n",
+		"settings":         `{"debug": true}`,
+		"pretty.json":      "{\"zones\": [\"a\", \"b\"], \"replicas\": 2}",
+		"note":             "level: high",
+		"script":           "echo a\necho c\n",
+	}, opts)
+
+	changes := Diff(oldDoc, newDoc, opts)
+	expected := map[string]bool{
+		".app.yaml|.server.port": true,
+		".note":                  true,
+		".pretty.json|.zones[1]": true,
+		".script":                true,
+		".settings|.debug":       true,
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("Expected %d changes, got %v", len(expected), changes)
+	}
+	for _, change := range changes {
+		if !expected[change.Path] {
+			t.Errorf("Unexpected change %s at %s", change.Type, change.Path)
+		}
+	}
+
+	// Without the option, embedded YAML is compared as text
+	opts.ParseEmbedded = false
+	if changes := Diff(oldDoc, newDoc, opts); len(changes) != 6 {
+		t.Errorf("Expected 6 changed strings, got %v", changes)
+	}
+}
@@ -0,0 +1,161 @@
+package ymldiff
+
+import (
+	"sort"
+	"strconv"
+
+	"ymldiff/internal/intmath"
+)
+
+// SimilarityMatch links an item of the old side with an item of the new side
+type SimilarityMatch struct {
+	Old, New   int
+	Similarity float64
+}
+
+// MatchBySimilarity pairs the items of two sides, best matches first and
+// preferring items at the same position on ties. Pairs less similar than
+// threshold are left unmatched.
+func MatchBySimilarity(oldCount, newCount int, score func(i, j int) float64, threshold float64) []SimilarityMatch {
+	var candidates []SimilarityMatch
+	for i := 0; i < oldCount; i++ {
+		for j := 0; j < newCount; j++ {
+			if similarity := score(i, j); similarity >= threshold {
+				candidates = append(candidates, SimilarityMatch{i, j, similarity})
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(a, b int) bool {
+		if candidates[a].Similarity != candidates[b].Similarity {
+			return candidates[a].Similarity > candidates[b].Similarity
+		}
+		return intmath.Abs(candidates[a].Old-candidates[a].New) < intmath.Abs(candidates[b].Old-candidates[b].New)
+	})
+
+	oldTaken := make(map[int]bool)
+	newTaken := make(map[int]bool)
+	var matches []SimilarityMatch
+	for _, c := range candidates {
+		if oldTaken[c.Old] || newTaken[c.New] {
+			continue
+		}
+		oldTaken[c.Old] = true
+		newTaken[c.New] = true
+		matches = append(matches, c)
+	}
+	return matches
+}
+
+// Similarity scores how similar two normalized values are, from 0 for
+// unrelated values to 1 for equal ones, by the share of unchanged leaf values
+func Similarity(a, b interface{}, opts Options) float64 {
+	opts.hashes = make(hashCache)
+	return opts.similarity(a, b, "")
+}
+
+// similarity scores how similar two values at path are
+func (o Options) similarity(a, b interface{}, path string) float64 {
+	if o.hashes.equal(a, b) {
+		return 1
+	}
+	leaves := countLeaves(a)
+	if other := countLeaves(b); other > leaves {
+		leaves = other
+	}
+	if leaves == 0 {
+		return 0
+	}
+
+	changed := 0
+	for _, change := range o.diffValues(a, b, path) {
+		switch change.Type {
+		case Addition:
+			changed += countLeaves(change.NewValue)
+		case Deletion:
+			changed += countLeaves(change.OldValue)
+		case Move:
+			// Position changes leave the values alike
+		default:
+			changed++
+		}
+	}
+	if changed >= leaves {
+		return 0
+	}
+	return 1 - float64(changed)/float64(leaves)
+}
+
+// countLeaves counts the scalar values of a value
+func countLeaves(v interface{}) int {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		count := 0
+		for _, child := range val {
+			count += countLeaves(child)
+		}
+		return count
+	case []interface{}:
+		count := 0
+		for _, child := range val {
+			count += countLeaves(child)
+		}
+		return count
+	default:
+		return 1
+	}
+}
+
+// isSliceOfDicts checks if every item of a slice is a dictionary
+func isSliceOfDicts(slice []interface{}) bool {
+	for _, item := range slice {
+		if _, ok := item.(map[interface{}]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// diffSliceBySimilarity compares slices of dictionaries without identifier
+// fields by pairing each item with the most similar item of the other slice.
+// Paired and added items are reported at their position in the new slice,
+// removed items at their position in the old one.
+func (o Options) diffSliceBySimilarity(oldSlice, newSlice []interface{}, path string) []Change {
+	var changes []Change
+
+	score := func(i, j int) float64 {
+		return o.similarity(oldSlice[i], newSlice[j], path+"["+strconv.Itoa(j)+"]")
+	}
+	oldMatched := make(map[int]bool)
+	newMatched := make(map[int]bool)
+	for _, match := range MatchBySimilarity(len(oldSlice), len(newSlice), score, o.ItemSimilarity) {
+		oldMatched[match.Old] = true
+		newMatched[match.New] = true
+		o.debugf("%s: item %d of the first file matched item %d of the second file (similarity %.2f)",
+			DisplayPath(path), match.Old, match.New, match.Similarity)
+		changes = append(changes, o.diffValues(oldSlice[match.Old], newSlice[match.New], path+"["+strconv.Itoa(match.New)+"]")...)
+	}
+
+	for i, oldItem := range oldSlice {
+		if !oldMatched[i] {
+			changes = append(changes, Change{
+				Type:     Deletion,
+				Path:     path + "[" + strconv.Itoa(i) + "]",
+				OldValue: oldItem,
+				NewValue: nil,
+			})
+		}
+	}
+	for j, newItem := range newSlice {
+		if !newMatched[j] {
+			changes = append(changes, Change{
+				Type:     Addition,
+				Path:     path + "[" + strconv.Itoa(j) + "]",
+				OldValue: nil,
+				NewValue: newItem,
+			})
+		}
+	}
+
+	return changes
+}
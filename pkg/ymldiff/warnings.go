@@ -0,0 +1,80 @@
+package ymldiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// warnUnkeyedMaps warns when the items of a list of maps are matched by the
+// fallback strategy because they have no identifier field, suggesting fields
+// that could serve as one
+func (o Options) warnUnkeyedMaps(path, strategy string, slices ...[]interface{}) {
+	var items []map[interface{}]interface{}
+	for _, slice := range slices {
+		for _, item := range slice {
+			m, ok := item.(map[interface{}]interface{})
+			if !ok {
+				return
+			}
+			items = append(items, m)
+		}
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	message := fmt.Sprintf("list items have no identifier field (%s) and are %s", strings.Join(o.IDKeys, ", "), strategy)
+	if candidates := candidateIDKeys(slices...); len(candidates) > 0 {
+		message += fmt.Sprintf("; try --id-key %s", candidates[0])
+	} else {
+		message += "; add --id-key to match them by a field"
+	}
+	o.warnf(path, "%s", message)
+}
+
+// candidateIDKeys returns the fields present in every item of each list with
+// a distinct scalar value per item, in sorted order
+func candidateIDKeys(slices ...[]interface{}) []string {
+	counts := make(map[string]int)
+	for _, slice := range slices {
+		for _, item := range slice {
+			m := item.(map[interface{}]interface{})
+			for key := range m {
+				counts[fmt.Sprintf("%v", key)]++
+			}
+		}
+	}
+
+	total := 0
+	for _, slice := range slices {
+		total += len(slice)
+	}
+	var candidates []string
+	for key, count := range counts {
+		if count != total {
+			continue
+		}
+		unique := true
+		for _, slice := range slices {
+			seen := make(map[string]bool)
+			for _, item := range slice {
+				value := item.(map[interface{}]interface{})[key]
+				switch value.(type) {
+				case map[interface{}]interface{}, []interface{}, nil:
+					unique = false
+				}
+				formatted := fmt.Sprintf("%v", value)
+				if seen[formatted] {
+					unique = false
+				}
+				seen[formatted] = true
+			}
+		}
+		if unique {
+			candidates = append(candidates, key)
+		}
+	}
+	sort.Strings(candidates)
+	return candidates
+}
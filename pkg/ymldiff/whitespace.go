@@ -0,0 +1,30 @@
+package ymldiff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// whitespaceRun matches a run of whitespace, including line breaks
+var whitespaceRun = regexp.MustCompile(`\s+`)
+
+// normalizeWhitespace rewrites a string as TrimStrings and CollapseWhitespace
+// compare it: without leading and trailing whitespace, and with every run of
+// whitespace as one space
+func (o Options) normalizeWhitespace(s string) string {
+	if o.TrimStrings {
+		s = strings.TrimSpace(s)
+	}
+	if o.CollapseWhitespace {
+		s = whitespaceRun.ReplaceAllString(s, " ")
+	}
+	return s
+}
+
+// whitespaceEqual checks if two strings only differ in the whitespace left
+// out by TrimStrings and CollapseWhitespace
+func (o Options) whitespaceEqual(a, b interface{}) bool {
+	stringA, okA := a.(string)
+	stringB, okB := b.(string)
+	return okA && okB && o.normalizeWhitespace(stringA) == o.normalizeWhitespace(stringB)
+}
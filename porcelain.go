@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// formatPorcelain renders changes in the porcelain format, which is kept
+// stable across releases for scripts and golden-file tests. Each change is one
+// line of five tab-separated fields:
+//
+//	TYPE DOCUMENT PATH OLD NEW
+//
+// TYPE is addition, deletion, modification or move and DOCUMENT the 1-based
+// number of the document pair. Tabs, newlines, carriage returns and
+// backslashes in PATH are escaped as \t, \n, \r and \\. OLD and NEW are the
+// values as compact JSON, or empty for the missing side of an addition or
+// deletion. Items added or removed several times with --multiset get a line
+// each. Lines are ordered by document, then path; there is no other output.
+func formatPorcelain(changes []jsonChange) (string, error) {
+	var result strings.Builder
+	for _, change := range changes {
+		oldValue, newValue := "", ""
+		var err error
+		if change.Type != Addition.String() {
+			if oldValue, err = porcelainValue(change.Old); err != nil {
+				return "", err
+			}
+		}
+		if change.Type != Deletion.String() {
+			if newValue, err = porcelainValue(change.New); err != nil {
+				return "", err
+			}
+		}
+		line := strings.Join([]string{
+			change.Type,
+			strconv.Itoa(change.Document),
+			porcelainEscaper.Replace(change.Path),
+			oldValue,
+			newValue,
+		}, "\t") + "\n"
+		result.WriteString(line)
+		for i := 1; i < change.Count; i++ {
+			result.WriteString(line)
+		}
+	}
+	return result.String(), nil
+}
+
+// porcelainEscaper escapes the characters that would break a porcelain line
+var porcelainEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// porcelainValue encodes a value as compact JSON, which never contains tabs
+// or newlines
+func porcelainValue(v interface{}) (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
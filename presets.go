@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Preset bundles comparison settings for a family of YAML files
+type Preset struct {
+	Description    string   `yaml:"description"`
+	IDKeys         []string `yaml:"idKeys"`
+	Ignore         []string `yaml:"ignore"`
+	Normalizations []string `yaml:"normalizations"`
+	DocumentMatch  string   `yaml:"documentMatch"`
+}
+
+// builtinPresets holds the presets shipped with ymldiff
+var builtinPresets = map[string]Preset{
+	"k8s-noise": {
+		Description: "Ignore fields managed by the Kubernetes API server and kubectl",
+		Ignore:      k8sNoiseIgnores,
+	},
+	"kubernetes": {
+		Description:   "Kubernetes manifests: match documents by kind/namespace/name, ignore server-managed fields",
+		IDKeys:        []string{"name", "containerPort", "key"},
+		Ignore:        k8sNoiseIgnores,
+		DocumentMatch: "kubernetes",
+	},
+	"compose": {
+		Description:    "Docker Compose files: treat list and map environment/labels forms as equal",
+		IDKeys:         []string{"name"},
+		Ignore:         []string{".version"},
+		Normalizations: []string{"compose-environment"},
+	},
+	"ansible": {
+		Description: "Ansible playbooks and roles: match plays, tasks and handlers by name",
+		IDKeys:      []string{"name"},
+	},
+	"github-actions": {
+		Description:    "GitHub Actions workflows: match steps by id/name, treat trigger shorthands as equal",
+		IDKeys:         []string{"id", "name", "uses"},
+		Normalizations: []string{"github-actions-triggers"},
+	},
+}
+
+// documentMatchers lists the supported document matching rules
+var documentMatchers = map[string]bool{
+	"index":      true,
+	"kubernetes": true,
+	"similarity": true,
+}
+
+// documentNormalizers maps normalization names to the functions applying them to a document
+var documentNormalizers = map[string]func(interface{}) interface{}{
+	"compose-environment":     normalizeComposeEnvironment,
+	"github-actions-triggers": normalizeGitHubActionsTriggers,
+}
+
+// presetNames returns the names of all available presets in sorted order
+func presetNames(config *Config) []string {
+	names := make([]string, 0, len(builtinPresets))
+	for name := range builtinPresets {
+		names = append(names, name)
+	}
+	if config != nil {
+		for name := range config.Presets {
+			if _, builtin := builtinPresets[name]; !builtin {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// resolvePreset looks up a preset by name, preferring user-defined presets from the config file
+func resolvePreset(name string, config *Config) (Preset, error) {
+	preset, ok := Preset{}, false
+	if config != nil {
+		preset, ok = config.Presets[name]
+	}
+	if !ok {
+		preset, ok = builtinPresets[name]
+	}
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(presetNames(config), ", "))
+	}
+
+	for _, normalization := range preset.Normalizations {
+		if _, known := documentNormalizers[normalization]; !known {
+			return Preset{}, fmt.Errorf("preset %q: unknown normalization %q", name, normalization)
+		}
+	}
+	if preset.DocumentMatch != "" && !documentMatchers[preset.DocumentMatch] {
+		return Preset{}, fmt.Errorf("preset %q: unknown document matching rule %q", name, preset.DocumentMatch)
+	}
+	return preset, nil
+}
+
+// applyPreset merges a preset into the global configuration
+func applyPreset(preset Preset) {
+	ignorePatterns = append(ignorePatterns, preset.Ignore...)
+
+	// Preset identifier keys take priority over the ones already configured
+	if len(preset.IDKeys) > 0 {
+		merged := append([]string{}, preset.IDKeys...)
+		for _, key := range idKeys {
+			if !containsString(merged, key) {
+				merged = append(merged, key)
+			}
+		}
+		idKeys = merged
+	}
+
+	for _, normalization := range preset.Normalizations {
+		if !containsString(normalizations, normalization) {
+			normalizations = append(normalizations, normalization)
+		}
+	}
+
+	if preset.DocumentMatch != "" {
+		documentMatch = preset.DocumentMatch
+	}
+}
+
+// containsString checks if a slice contains the given string
+func containsString(slice []string, s string) bool {
+	for _, item := range slice {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// applyNormalizations runs the configured preset normalizations on a document
+func applyNormalizations(doc interface{}) interface{} {
+	for _, normalization := range normalizations {
+		debugf("applying normalization %s", normalization)
+		doc = documentNormalizers[normalization](doc)
+	}
+	return doc
+}
+
+// normalizeComposeEnvironment converts "KEY=value" lists under services' environment
+// and labels into maps, so both Compose notations compare equal
+func normalizeComposeEnvironment(doc interface{}) interface{} {
+	m, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		return doc
+	}
+	services, ok := m["services"].(map[interface{}]interface{})
+	if !ok {
+		return doc
+	}
+
+	for _, service := range services {
+		serviceMap, ok := service.(map[interface{}]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range []string{"environment", "labels"} {
+			converted := make(map[interface{}]interface{})
+			switch entries := serviceMap[field].(type) {
+			case []interface{}:
+				for _, item := range entries {
+					entry := fmt.Sprintf("%v", item)
+					if key, value, found := strings.Cut(entry, "="); found {
+						converted[key] = value
+					} else {
+						converted[entry] = nil
+					}
+				}
+			case map[interface{}]interface{}:
+				// Values are passed to the container as strings either way
+				for key, value := range entries {
+					if value != nil {
+						value = fmt.Sprintf("%v", value)
+					}
+					converted[fmt.Sprintf("%v", key)] = value
+				}
+			default:
+				continue
+			}
+			serviceMap[field] = converted
+		}
+	}
+	return doc
+}
+
+// normalizeGitHubActionsTriggers expands the string and list shorthands of a
+// workflow's "on" key into the equivalent map form
+func normalizeGitHubActionsTriggers(doc interface{}) interface{} {
+	m, ok := doc.(map[interface{}]interface{})
+	if !ok {
+		return doc
+	}
+
+	switch triggers := m["on"].(type) {
+	case string:
+		m["on"] = map[interface{}]interface{}{triggers: nil}
+	case []interface{}:
+		expanded := make(map[interface{}]interface{})
+		for _, trigger := range triggers {
+			expanded[fmt.Sprintf("%v", trigger)] = nil
+		}
+		m["on"] = expanded
+	}
+	return doc
+}
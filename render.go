@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// templateFuncs are the helper functions available to rendered templates
+var templateFuncs = template.FuncMap{
+	"default": func(fallback, value interface{}) interface{} {
+		if value == nil || value == "" {
+			return fallback
+		}
+		return value
+	},
+	"required": func(message string, value interface{}) (interface{}, error) {
+		if value == nil || value == "" {
+			return nil, fmt.Errorf("%s", message)
+		}
+		return value, nil
+	},
+	"quote": func(value interface{}) string {
+		return fmt.Sprintf("%q", fmt.Sprintf("%v", value))
+	},
+	"upper": func(value interface{}) string {
+		return strings.ToUpper(fmt.Sprintf("%v", value))
+	},
+	"lower": func(value interface{}) string {
+		return strings.ToLower(fmt.Sprintf("%v", value))
+	},
+	"toYaml": func(value interface{}) (string, error) {
+		data, err := yaml.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(string(data), "\n"), nil
+	},
+	"indent": indentLines,
+	"nindent": func(spaces int, s string) string {
+		return "\n" + indentLines(spaces, s)
+	},
+}
+
+// indentLines indents every line of s by the given number of spaces
+func indentLines(spaces int, s string) string {
+	padding := strings.Repeat(" ", spaces)
+	return padding + strings.ReplaceAll(s, "\n", "\n"+padding)
+}
+
+// loadTemplateValues reads and merges values files; later files override earlier ones
+func loadTemplateValues(filenames []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return nil, err
+		}
+		var fileValues map[string]interface{}
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			return nil, fmt.Errorf("parsing values file %s: %w", filename, err)
+		}
+		for key, value := range fileValues {
+			values[key] = value
+		}
+	}
+	return values, nil
+}
+
+// renderTemplate renders a Go-templated input with the loaded values as the template data
+func renderTemplate(filename string, data []byte) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(filename)).
+		Funcs(templateFuncs).
+		Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, templateValues); err != nil {
+		return nil, fmt.Errorf("rendering template: %w", err)
+	}
+	// Missing values render as empty, like they do in Helm
+	return bytes.ReplaceAll(buf.Bytes(), []byte("<no value>"), nil), nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+)
+
+// Build information, set by goreleaser through -ldflags
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+	builtBy = "unknown"
+)
+
+// usedOptions lists the command line options that were explicitly set
+func usedOptions(flags *flag.FlagSet) []string {
+	var options []string
+	flags.Visit(func(f *flag.Flag) {
+		switch value := f.Value.(type) {
+		case flag.SliceValue:
+			for _, item := range value.GetSlice() {
+				options = append(options, fmt.Sprintf("--%s=%s", f.Name, item))
+			}
+		default:
+			if f.Value.Type() == "bool" {
+				options = append(options, "--"+f.Name)
+			} else {
+				options = append(options, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+			}
+		}
+	})
+	return options
+}
+
+// describeInput describes an input file with its modification time
+func describeInput(filename string) string {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return filename
+	}
+	return fmt.Sprintf("%s (modified %s)", filename, info.ModTime().UTC().Format(time.RFC3339))
+}
+
+// formatReportHeader formats the report header describing the tool, inputs and options
+func formatReportHeader(labels, files, options []string, generated time.Time) string {
+	var result strings.Builder
+	result.WriteString("# ymldiff report\n")
+	result.WriteString(fmt.Sprintf("# Version:   %s (commit %s, built %s by %s)\n", version, commit, date, builtBy))
+	result.WriteString(fmt.Sprintf("# Generated: %s\n", generated.UTC().Format(time.RFC3339)))
+	for i, file := range files {
+		description := describeInput(file)
+		if name := inputName(labels[i], file); name != file {
+			description = name + ", " + description
+		}
+		result.WriteString(fmt.Sprintf("# %-10s %s\n", labels[i]+":", description))
+	}
+	if len(options) == 0 {
+		result.WriteString("# Options:   (none)\n")
+	} else {
+		result.WriteString(fmt.Sprintf("# Options:   %s\n", strings.Join(options, " ")))
+	}
+	return result.String()
+}
+
+// formatReportFooter formats the report footer with the total change counts
+func formatReportFooter(counts changeCounts, changedDocuments, totalDocuments int) string {
+	moves := ""
+	if counts.Moves > 0 {
+		moves = fmt.Sprintf(", %d %s", counts.Moves, pluralize(counts.Moves, "move", "moves"))
+	}
+	return fmt.Sprintf("# Total: %d %s (%d %s, %d %s, %d %s%s) in %d of %d %s\n",
+		counts.Total(), pluralize(counts.Total(), "change", "changes"),
+		counts.Additions, pluralize(counts.Additions, "addition", "additions"),
+		counts.Deletions, pluralize(counts.Deletions, "deletion", "deletions"),
+		counts.Modifications, pluralize(counts.Modifications, "modification", "modifications"), moves,
+		changedDocuments, totalDocuments, pluralize(totalDocuments, "document", "documents"))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// comparison is the Result of comparing two files, with what the output needs
+// besides it
+type comparison struct {
+	Result
+	// Sources holds the content read from the old and new files, as parsed,
+	// since standard input can only be read once
+	Sources [2][]byte
+	// decoded holds the paths of the base64-decoded values of each document
+	decoded []map[string]bool
+}
+
+// documentChanges returns the changes of the i-th compared document, marking
+// those to decoded values
+func (c comparison) documentChanges(i int) []Change {
+	changes := newChanges(c.Documents[i].Changes)
+	if i < len(c.decoded) {
+		markDecoded(changes, c.decoded[i])
+	}
+	return changes
+}
+
+// compareFiles parses two files and compares their documents
+func compareFiles(file1, file2 string) (comparison, error) {
+	// Both files are parsed before failing, so all their errors are reported
+	var documents1, documents2 []YAMLDocument
+	source1, err1 := readInput(file1)
+	if err1 == nil {
+		documents1, err1 = parseSource(file1, leftFormat, source1)
+	}
+	source2, err2 := readInput(file2)
+	if err2 == nil {
+		documents2, err2 = parseSource(file2, rightFormat, source2)
+	}
+	if err := errors.Join(err1, err2); err != nil {
+		return comparison{}, err
+	}
+
+	result := compareDocuments(documents1, documents2)
+	result.Inputs = newJSONInputs([]string{"Old", "New"}, []string{file1, file2})
+	result.Sources = [2][]byte{source1, source2}
+	return result, nil
+}
+
+// compareDocuments pairs the documents of two files and compares each pair,
+// applying the preset normalizations and ignore patterns
+func compareDocuments(documents1, documents2 []YAMLDocument) comparison {
+	normalizeDocuments(documents1)
+	normalizeDocuments(documents2)
+	mapDocuments(documents1)
+	return comparePairs(documents1, documents2, pairDocuments(documents1, documents2))
+}
+
+// normalizeDocuments applies the preset normalizations to documents, unless
+// comparing them raw
+func normalizeDocuments(documents []YAMLDocument) {
+	if rawMode {
+		return
+	}
+	for i := range documents {
+		documents[i].Data = applyNormalizations(documents[i].Data)
+	}
+}
+
+// mapDocuments moves the values at mapped paths of documents of the old file
+// to their new paths, so they are compared under their new names
+func mapDocuments(documents []YAMLDocument) {
+	if len(pathMappings) == 0 {
+		return
+	}
+	for i := range documents {
+		documents[i].Data = applyPathMappings(documents[i].Data)
+	}
+}
+
+// comparePairs compares paired documents of two files, already normalized
+// and mapped. Every comparison goes through it: it prepares each selected pair
+// as the command line asks, with secrets, base64 and certificates compared as
+// the values they hold, leaves the comparison to ymldiff.Compare and then
+// drops the changes at ignored paths.
+func comparePairs(documents1, documents2 []YAMLDocument, pairs []documentPair) comparison {
+	warningsMu.Lock()
+	warningsBefore := len(warnings)
+	warningsMu.Unlock()
+
+	// Compare pairs documents by position, so the prepared documents of each
+	// pair are lined up at the same position of both sides
+	var selected []int
+	var prepared1, prepared2 []YAMLDocument
+	var decoded []map[string]bool
+	for i, pair := range pairs {
+		var oldData, newData interface{}
+		if pair.Old >= 0 {
+			oldData = documents1[pair.Old].Data
+		}
+		if pair.New >= 0 {
+			newData = documents2[pair.New].Data
+		}
+
+		// Skip documents left out by --docs and empty documents
+		if !selectedDocuments.selects(pair, documents1, documents2) {
+			continue
+		}
+		if oldData == nil && newData == nil {
+			continue
+		}
+
+		// Treat Secret stringData and its base64 form in data as equal
+		if !rawMode {
+			oldData, newData = reconcileSecrets(oldData, newData)
+		}
+
+		// Base64-encoded values are compared as the text they hold
+		pairDecoded := make(map[string]bool)
+		if len(base64Patterns) > 0 {
+			oldData = normalizeValue(decodeBase64Values(oldData, "", pairDecoded))
+			newData = normalizeValue(decodeBase64Values(newData, "", pairDecoded))
+		}
+		if parseCerts {
+			oldData = describeCertificates(oldData)
+			newData = describeCertificates(newData)
+		}
+
+		selected = append(selected, i)
+		prepared1 = append(prepared1, YAMLDocument{Data: oldData})
+		prepared2 = append(prepared2, YAMLDocument{Data: newData})
+		decoded = append(decoded, pairDecoded)
+	}
+
+	// The documents are renumbered back to their pairs and recounted without
+	// the ignored changes
+	engine := ymldiff.Compare(prepared1, prepared2, engineOptions())
+	result := comparison{Result: Result{Old: documents1, New: documents2, TotalDocuments: len(pairs)}, decoded: decoded}
+	for i, document := range engine.Documents {
+		document.Index, document.Pair = selected[i], pairs[selected[i]]
+		document.Changes = engineChanges(filterIgnored(newChanges(document.Changes), ignorePatterns))
+		result.Add(document)
+	}
+
+	warningsMu.Lock()
+	result.Warnings = append([]warning{}, warnings[warningsBefore:]...)
+	warningsMu.Unlock()
+	return result
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/fatih/color"
+)
+
+// TestCompareFiles tests that the result holds the changes, totals, warnings and inputs of a comparison
+func TestCompareFiles(t *testing.T) {
+	originalIgnore, originalOutput, originalWarnings := ignorePatterns, warningOutput, warnings
+	defer func() { ignorePatterns, warningOutput, warnings = originalIgnore, originalOutput, originalWarnings }()
+	ignorePatterns = []string{".status"}
+	warningOutput = &strings.Builder{}
+	warnings = nil
+
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "old.yaml")
+	file2 := filepath.Join(dir, "new.yaml")
+	writeTestFile(t, file1, "a: 1\nstatus: old\n---\nb: 1\n---\nrules: [{port: 1}]\n")
+	writeTestFile(t, file2, "a: 2\nstatus: new\n---\nb: 1\n---\nrules: [{port: 2}]\nc: true\n")
+
+	result, err := compareFiles(file1, file2)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.TotalDocuments != 3 || len(result.Documents) != 3 || result.ChangedDocuments != 2 {
+		t.Errorf("Unexpected document totals: %d documents, %d changed", result.TotalDocuments, result.ChangedDocuments)
+	}
+	if len(result.Documents[0].Changes) != 1 || result.Documents[0].Changes[0].Path != ".a" {
+		t.Errorf("Expected only .a to change in the first document, got %+v", result.Documents[0].Changes)
+	}
+	if result.Counts.Modifications != 1 || result.Counts.Additions != 2 || result.Counts.Deletions != 1 || len(result.Changes()) != 4 {
+		t.Errorf("Unexpected counts %+v", result.Counts)
+	}
+	if len(result.Warnings) != 1 || result.Warnings[0].Path != ".rules" {
+		t.Errorf("Expected a warning for .rules, got %+v", result.Warnings)
+	}
+	if len(result.Inputs) != 2 || result.Inputs[1].File != file2 || result.Inputs[1].Modified == "" {
+		t.Errorf("Unexpected inputs %+v", result.Inputs)
+	}
+
+	if _, err := compareFiles(filepath.Join(dir, "missing.yaml"), file2); err == nil || errorOutcome(err) != outcomeFileNotFound {
+		t.Errorf("Expected a file not found error, got %v", err)
+	}
+}
+
+// TestCompareFilesStdin tests that the content read from standard input is
+// kept, since it can't be read again
+func TestCompareFilesStdin(t *testing.T) {
+	defer func(stdin *os.File) { os.Stdin = stdin }(os.Stdin)
+	color.NoColor = true
+
+	dir := t.TempDir()
+	file1 := filepath.Join(dir, "old.yaml")
+	stdin := filepath.Join(dir, "stdin.yaml")
+	writeTestFile(t, file1, "a: 1\n")
+	writeTestFile(t, stdin, "a: 2\n")
+	input, err := os.Open(stdin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer input.Close()
+	os.Stdin = input
+
+	result, err := compareFiles(file1, "-")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(result.Sources[0]) != "a: 1\n" || string(result.Sources[1]) != "a: 2\n" {
+		t.Errorf("Unexpected sources %q", result.Sources)
+	}
+
+	a := newAnnotations()
+	a.addChanges(result.documentChanges(0), result.New[0].Node, result.Documents[0].NewData)
+	if output := generateAnnotated(result.Sources[1], a); output != "a: 2  # ymldiff: changed from 1\n" {
+		t.Errorf("Unexpected annotated output %q", output)
+	}
+}
+
+// TestPairDocumentsBySimilarity tests matching reordered documents by content
+func TestPairDocumentsBySimilarity(t *testing.T) {
+	doc := func(v map[string]interface{}) YAMLDocument {
+		return YAMLDocument{Data: normalizeValue(v)}
+	}
+	documents1 := []YAMLDocument{
+		doc(map[string]interface{}{"kind": "Deployment", "replicas": 1, "image": "app:1"}),
+		doc(map[string]interface{}{"kind": "Service", "port": 80, "selector": "app"}),
+		doc(map[string]interface{}{"kind": "Legacy"}),
+	}
+	documents2 := []YAMLDocument{
+		doc(map[string]interface{}{"kind": "Service", "port": 80, "selector": "app"}),
+		doc(map[string]interface{}{"kind": "Deployment", "replicas": 3, "image": "app:1"}),
+		doc(map[string]interface{}{"kind": "ConfigMap"}),
+	}
+
+	expected := []documentPair{{Old: 1, New: 0}, {Old: 0, New: 1}, {Old: -1, New: 2}, {Old: 2, New: -1}}
+	pairs := pairDocumentsBySimilarity(documents1, documents2)
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Errorf("Expected pairs %+v, got %+v", expected, pairs)
+	}
+	if !pairs[0].IsMoved() || pairs[2].IsMoved() {
+		t.Errorf("Unexpected moves for %+v", pairs)
+	}
+}
+
+// TestParseDocumentSelection tests parsing the documents listed with --docs
+func TestParseDocumentSelection(t *testing.T) {
+	selection, err := parseDocumentSelection("2, 4-6,Deployment/web")
+	if err != nil {
+		t.Fatalf("parseDocumentSelection error: %v", err)
+	}
+	for _, position := range []int{2, 4, 5, 6} {
+		if !selection.selectsPosition(position) {
+			t.Errorf("Expected document %d to be selected", position)
+		}
+	}
+	if selection.selectsPosition(3) || selection.selectsPosition(7) {
+		t.Error("Expected documents 3 and 7 not to be selected")
+	}
+	if len(selection.names) != 1 || selection.names[0] != "Deployment/web" {
+		t.Errorf("names = %v, expected [Deployment/web]", selection.names)
+	}
+
+	// Ranges are kept as bounds, however large
+	if selection, err := parseDocumentSelection("1-2000000000"); err != nil || len(selection.ranges) != 1 || !selection.selectsPosition(1999999999) {
+		t.Errorf("Expected a single range up to 2000000000, got %+v, %v", selection, err)
+	}
+
+	for _, spec := range []string{"0", "3-1", "1,,2", "2-x"} {
+		if _, err := parseDocumentSelection(spec); err == nil {
+			t.Errorf("parseDocumentSelection(%q) expected an error", spec)
+		}
+	}
+}
+
+// TestSelectedDocuments tests that only the selected documents are compared
+func TestSelectedDocuments(t *testing.T) {
+	defer func(s *documentSelection) { selectedDocuments = s }(selectedDocuments)
+
+	object := func(kind, name string, replicas int) YAMLDocument {
+		return YAMLDocument{Data: map[interface{}]interface{}{
+			"kind":     kind,
+			"metadata": map[interface{}]interface{}{"name": name, "namespace": "prod"},
+			"replicas": replicas,
+		}}
+	}
+	old := func() []YAMLDocument {
+		return []YAMLDocument{object("Deployment", "web", 1), object("Service", "web", 1), object("Deployment", "api", 1)}
+	}
+	updated := func() []YAMLDocument {
+		return []YAMLDocument{object("Deployment", "web", 2), object("Service", "web", 2), object("Deployment", "api", 2)}
+	}
+
+	tests := []struct {
+		spec      string
+		documents int
+	}{
+		{"1", 1},
+		{"2-3", 2},
+		{"Deployment/web", 1},
+		{"Service/prod/web", 1},
+		{"web", 2},
+		{"api,1", 2},
+		{"9", 0},
+	}
+	for _, tt := range tests {
+		selection, err := parseDocumentSelection(tt.spec)
+		if err != nil {
+			t.Fatalf("parseDocumentSelection(%q) error: %v", tt.spec, err)
+		}
+		selectedDocuments = selection
+		result := compareDocuments(old(), updated())
+		if result.ChangedDocuments != tt.documents {
+			t.Errorf("--docs %s compared %d changed documents, expected %d", tt.spec, result.ChangedDocuments, tt.documents)
+		}
+	}
+}
+
+// TestRunSelf tests diffing two subtrees of the same file
+func TestRunSelf(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := "environments:\n  staging:\n    replicas: 1\n    debug: true\n  prod:\n    replicas: 3\n  qa:\n    replicas: 1\n    debug: true\n"
+	writeTestFile(t, file, content)
+
+	discardOutput(t)
+
+	defer func(patterns []string) { ignorePatterns = patterns }(ignorePatterns)
+	defer func(mappings []pathMapping) { pathMappings = mappings }(pathMappings)
+
+	tests := []struct {
+		from, to string
+		ignore   []string
+		mappings []pathMapping
+		changes  int
+	}{
+		{".environments.staging", ".environments.prod", nil, nil, 2},
+		{"environments.staging", "environments.qa", nil, nil, 0},
+		{".environments.staging", ".environments.prod", []string{".debug"}, nil, 1},
+		{".environments.staging", ".environments.prod", nil, []pathMapping{{From: ".debug", To: ".replicas"}}, 1},
+	}
+	for _, tt := range tests {
+		ignorePatterns, pathMappings = tt.ignore, tt.mappings
+		result, err := runSelf(file, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("runSelf(%q, %q) error: %v", tt.from, tt.to, err)
+		}
+		if changes := result.Counts.Total(); changes != tt.changes {
+			t.Errorf("runSelf(%q, %q) = %d changes, expected %d", tt.from, tt.to, changes, tt.changes)
+		}
+	}
+
+	if _, err := runSelf(file, ".environments.staging", ".environments.dev"); err == nil {
+		t.Error("Expected an error for a missing path")
+	}
+}
+
+// TestParseDocsSpec tests parsing the documents selected with --docs
+func TestParseDocsSpec(t *testing.T) {
+	tests := []struct {
+		spec     string
+		from, to int
+		valid    bool
+	}{
+		{"1:3", 0, 2, true},
+		{"2:1", 1, 0, true},
+		{"1", 0, 0, false},
+		{"0:2", 0, 0, false},
+		{"a:b", 0, 0, false},
+	}
+	for _, tt := range tests {
+		from, to, err := parseDocsSpec(tt.spec)
+		if (err == nil) != tt.valid {
+			t.Errorf("parseDocsSpec(%q) error = %v, expected valid %v", tt.spec, err, tt.valid)
+			continue
+		}
+		if tt.valid && (from != tt.from || to != tt.to) {
+			t.Errorf("parseDocsSpec(%q) = %d, %d, expected %d, %d", tt.spec, from, to, tt.from, tt.to)
+		}
+	}
+}
+
+// TestRunDocs tests diffing two documents of the same file
+func TestRunDocs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "variants.yaml")
+	content := "replicas: 1\ndebug: true\n---\nreplicas: 1\ndebug: true\n---\nreplicas: 5\n"
+	writeTestFile(t, file, content)
+
+	discardOutput(t)
+
+	defer func(patterns []string) { ignorePatterns = patterns }(ignorePatterns)
+
+	tests := []struct {
+		from, to int
+		ignore   []string
+		changes  int
+	}{
+		{0, 2, nil, 2},
+		{0, 1, nil, 0},
+		{0, 2, []string{".debug"}, 1},
+	}
+	for _, tt := range tests {
+		ignorePatterns = tt.ignore
+		result, err := runDocs(file, tt.from, tt.to)
+		if err != nil {
+			t.Fatalf("runDocs(%d, %d) error: %v", tt.from, tt.to, err)
+		}
+		if changes := result.Counts.Total(); changes != tt.changes {
+			t.Errorf("runDocs(%d, %d) = %d changes, expected %d", tt.from, tt.to, changes, tt.changes)
+		}
+	}
+
+	if _, err := runDocs(file, 0, 3); err == nil {
+		t.Error("Expected an error for a missing document")
+	}
+}
+
+// TestBuildMatrix tests collecting differing paths across environments
+func TestBuildMatrix(t *testing.T) {
+	doc := func(data map[interface{}]interface{}) []YAMLDocument {
+		return []YAMLDocument{{Data: data}}
+	}
+	base := doc(map[interface{}]interface{}{"replicas": 1, "image": "app:1", "log": "info"})
+	prod := doc(map[interface{}]interface{}{"replicas": 3, "image": "app:1", "log": "info"})
+	dev := doc(map[interface{}]interface{}{"replicas": 1, "image": "app:2", "debug": true})
+
+	rows, result := buildMatrix(base, [][]YAMLDocument{prod, dev})
+	if result.Counts.Total() != 4 {
+		t.Errorf("Expected 4 changes across environments, got %d", result.Counts.Total())
+	}
+
+	expected := []matrixRow{
+		{Path: ".debug", Base: "<absent>", Cells: []string{"", "true"}},
+		{Path: ".image", Base: "app:1", Cells: []string{"", "app:2"}},
+		{Path: ".log", Base: "info", Cells: []string{"", "<absent>"}},
+		{Path: ".replicas", Base: "1", Cells: []string{"3", ""}},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("Expected %d rows, got %d: %v", len(expected), len(rows), rows)
+	}
+	for i := range expected {
+		if rows[i].Path != expected[i].Path || rows[i].Base != expected[i].Base ||
+			strings.Join(rows[i].Cells, "|") != strings.Join(expected[i].Cells, "|") {
+			t.Errorf("Row %d: expected %v, got %v", i, expected[i], rows[i])
+		}
+	}
+
+	// Ignore patterns apply to every environment
+	defer func(patterns []string) { ignorePatterns = patterns }(ignorePatterns)
+	ignorePatterns = []string{".log", ".debug"}
+	rows, result = buildMatrix(base, [][]YAMLDocument{prod, dev})
+	if len(rows) != 2 || result.Counts.Total() != 2 {
+		t.Errorf("Expected 2 rows and changes with ignored paths, got %d rows and %d changes", len(rows), result.Counts.Total())
+	}
+}
+
+// TestGenerateMatrix tests the table layout of the matrix report
+func TestGenerateMatrix(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	rows := []matrixRow{
+		{Path: ".replicas", Base: "1", Cells: []string{"3", ""}},
+	}
+	output := generateMatrix(rows, []string{"base.yaml", "prod.yaml", "dev.yaml"})
+
+	expected := "PATH       base.yaml  prod.yaml  dev.yaml\n" +
+		".replicas  1          3          =\n"
+	if output != expected {
+		t.Errorf("Unexpected matrix output:\n%q\nexpected:\n%q", output, expected)
+	}
+
+	if output := generateMatrix(nil, []string{"a", "b"}); !strings.Contains(output, "No changes found") {
+		t.Errorf("Expected 'No changes found' for an empty matrix, got: %s", output)
+	}
+}
+
+// TestFormatMatrixValue tests single-line formatting of matrix cells
+func TestFormatMatrixValue(t *testing.T) {
+	if got := formatMatrixValue(map[interface{}]interface{}{"a": 1}); got != "<map, 1 key>" {
+		t.Errorf("Expected map summary, got %q", got)
+	}
+	if got := formatMatrixValue("line1\nline2"); got != `line1\nline2` {
+		t.Errorf("Expected escaped newline, got %q", got)
+	}
+	long := strings.Repeat("x", 100)
+	if got := formatMatrixValue(long); len([]rune(got)) != maxMatrixCellWidth {
+		t.Errorf("Expected value truncated to %d characters, got %d", maxMatrixCellWidth, len([]rune(got)))
+	}
+}
+
+// TestForEachParallel tests that every index is processed within the concurrency limit
+func TestForEachParallel(t *testing.T) {
+	originalJobs := jobs
+	defer func() { jobs = originalJobs }()
+	jobs = 2
+
+	var running, maxRunning int32
+	seen := make([]int32, 10)
+	forEachParallel(len(seen), func(i int) {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			previous := atomic.LoadInt32(&maxRunning)
+			if current <= previous || atomic.CompareAndSwapInt32(&maxRunning, previous, current) {
+				break
+			}
+		}
+		atomic.AddInt32(&seen[i], 1)
+		atomic.AddInt32(&running, -1)
+	})
+
+	for i, count := range seen {
+		if count != 1 {
+			t.Errorf("Index %d processed %d times", i, count)
+		}
+	}
+	if maxRunning > 2 {
+		t.Errorf("Expected at most 2 concurrent calls, got %d", maxRunning)
+	}
+}
+
+// TestWarnUnkeyedMaps tests that lists of maps without identifier fields are
+// reported once, with a suggested --id-key
+func TestWarnUnkeyedMaps(t *testing.T) {
+	originalOutput, originalWarnings := warningOutput, warnings
+	defer func() { warningOutput, warnings = originalOutput, originalWarnings }()
+	var output strings.Builder
+	warningOutput = &output
+	warnings = nil
+
+	oldData := normalizeValue(map[string]interface{}{
+		"hosts": []interface{}{
+			map[string]interface{}{"host": "a", "port": 1},
+			map[string]interface{}{"host": "b", "port": 1},
+		},
+		"ports": []interface{}{80, 443},
+	})
+	newData := normalizeValue(map[string]interface{}{
+		"hosts": []interface{}{
+			map[string]interface{}{"host": "a", "port": 1},
+			map[string]interface{}{"host": "b", "port": 2},
+		},
+		"ports": []interface{}{80, 8443},
+	})
+	diffValues(oldData, newData, "")
+	diffValues(oldData, newData, "")
+
+	expected := "Warning: .hosts: list items have no identifier field (name, key, id) and are matched by content similarity; try --id-key host\n"
+	if output.String() != expected {
+		t.Errorf("Expected warning %q, got %q", expected, output.String())
+	}
+	if len(warnings) != 1 || warnings[0].Path != ".hosts" {
+		t.Errorf("Expected one recorded warning for .hosts, got %+v", warnings)
+	}
+}
+
+// TestDebugTrace tests that matching and ignore decisions are traced
+func TestDebugTrace(t *testing.T) {
+	originalDebugMode, originalDebugOutput := debugMode, debugOutput
+	defer func() { debugMode, debugOutput = originalDebugMode, originalDebugOutput }()
+	var trace bytes.Buffer
+	debugMode, debugOutput = true, &trace
+
+	oldData := normalizeValue(map[string]interface{}{
+		"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app:1"}},
+		"ports":      []interface{}{80, 443},
+		"status":     "old",
+	})
+	newData := normalizeValue(map[string]interface{}{
+		"containers": []interface{}{map[string]interface{}{"name": "app", "image": "app:2"}},
+		"ports":      []interface{}{80, 8443},
+		"status":     "new",
+	})
+	filterIgnored(diffValues(oldData, newData, ""), []string{"status"})
+
+	for _, expected := range []string{
+		"debug: .containers: keyed list, items matched by name\n",
+		"debug: .ports: list without identifier fields, compared by position after sorting\n",
+		"debug: .status: ignored by \"status\"\n",
+	} {
+		if !strings.Contains(trace.String(), expected) {
+			t.Errorf("Expected trace to contain %q, got:\n%s", expected, trace.String())
+		}
+	}
+
+	// Nothing is traced without --debug
+	debugMode = false
+	trace.Reset()
+	diffValues(oldData, newData, "")
+	if trace.Len() != 0 {
+		t.Errorf("Expected no trace, got:\n%s", trace.String())
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// splitRevision splits an input of the form FILE@REV into the file and the
+// git revision to read it from. A file whose name contains @ is read as is.
+func splitRevision(input string) (string, string, bool) {
+	i := strings.LastIndex(input, "@")
+	if i <= 0 || i == len(input)-1 {
+		return input, "", false
+	}
+	if _, err := os.Stat(input); err == nil {
+		return input, "", false
+	}
+	return input[:i], input[i+1:], true
+}
+
+// missingRevisionMessages are parts of the messages git fails with when a
+// revision, or the file in it, doesn't exist
+var missingRevisionMessages = []string{"invalid object name", "unknown revision", "does not exist in", "exists on disk, but not in"}
+
+// missingRevisionError is a git error about a revision or a file in it that
+// doesn't exist, exiting as a file that isn't found
+type missingRevisionError struct {
+	err error
+}
+
+func (e missingRevisionError) Error() string { return e.err.Error() }
+func (e missingRevisionError) Unwrap() error { return fs.ErrNotExist }
+
+// readRevision reads the content of a file as it was in a git revision
+func readRevision(file, revision string) ([]byte, error) {
+	content, err := runGit(filepath.Dir(file), "show", revision+":./"+filepath.Base(file))
+	if err != nil {
+		for _, message := range missingRevisionMessages {
+			if strings.Contains(err.Error(), message) {
+				return nil, missingRevisionError{err}
+			}
+		}
+	}
+	return content, err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// rollupMode replaces the changes of each document with the paths above them
+// and the number of changes nested below each, set with --rollup
+var rollupMode bool
+
+// pathSegments splits a path into its keys and list items
+func pathSegments(path string) []string {
+	var segments []string
+	for path != "" {
+		parent, segment := splitLastSegment(path)
+		segments = append([]string{segment}, segments...)
+		path = parent
+	}
+	return segments
+}
+
+// formatRollup lists the document root and every path with changes nested
+// below it, indented by depth, with the counts of those changes by type
+func formatRollup(changes []Change) string {
+	counts := make(map[string]*changeCounts)
+	for _, change := range changes {
+		segments := pathSegments(change.Path)
+		for depth := 0; depth < len(segments) || depth == 0; depth++ {
+			path := strings.Join(segments[:depth], "")
+			if counts[path] == nil {
+				counts[path] = &changeCounts{}
+			}
+			counts[path].AddType(change.Type)
+		}
+	}
+
+	// Paths are ordered key by key, so nested paths follow their parent
+	paths := make([]string, 0, len(counts))
+	for path := range counts {
+		paths = append(paths, path)
+	}
+	segments := make(map[string][]string, len(paths))
+	for _, path := range paths {
+		segments[path] = pathSegments(path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := segments[paths[i]], segments[paths[j]]
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return len(a) < len(b)
+	})
+
+	var result strings.Builder
+	for _, path := range paths {
+		result.WriteString(strings.Repeat("  ", len(segments[path])))
+		result.WriteString(debugPath(path) + ": " + formatRollupCounts(*counts[path]) + "\n")
+	}
+	return result.String()
+}
+
+// formatRollupCounts formats the non-zero counts of each type of change, each
+// after the marker of its type
+func formatRollupCounts(counts changeCounts) string {
+	var parts []string
+	for _, entry := range []struct {
+		changeType ChangeType
+		count      int
+		theme      string
+	}{
+		{Addition, counts.Additions, theme.Addition},
+		{Deletion, counts.Deletions, theme.Deletion},
+		{Modification, counts.Modifications, theme.Modification},
+		{Move, counts.Moves, theme.Move},
+	} {
+		if entry.count > 0 {
+			parts = append(parts, themeColor(entry.theme).Sprint(fmt.Sprintf("%s%d", markerFor(entry.changeType), entry.count)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
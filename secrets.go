@@ -0,0 +1,75 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// redactSecrets masks values that look like secrets in the output, set with
+// --redact-secrets and on by default in CI
+var redactSecrets bool
+
+// machineReadableFormats are the output formats read by programs rather than
+// people. Redaction on by default in CI leaves them, and assert, as they are.
+var machineReadableFormats = []string{"json", "ndjson", "yaml", "porcelain", "gitlab", "template"}
+
+// secretKeyPattern matches the names of keys usually holding secrets
+var secretKeyPattern = regexp.MustCompile(`(?i)(passw(or)?d|passphrase|secret|token|api[-_]?key|access[-_]?key|private[-_]?key|credentials?)`)
+
+// secretReferencePattern matches the names of keys that refer to a secret
+// rather than hold it, such as secretName or tokenPath
+var secretReferencePattern = regexp.MustCompile(`(?i)(name|ref|path|file|url|type|ttl|length|expiry|expiration)$`)
+
+// secretTextPattern matches the characters of keys and tokens as generated:
+// letters, digits and the symbols of base64 and JWTs
+var secretTextPattern = regexp.MustCompile(`^[A-Za-z0-9+/=_.-]+$`)
+
+// minSecretLength and minSecretEntropy are the length and Shannon entropy,
+// in bits per character, from which a string looks randomly generated
+const (
+	minSecretLength  = 20
+	minSecretEntropy = 4.0
+)
+
+// looksSecret checks if a value is likely a secret: held by a key named like
+// password or apiKey, or a long random-looking string
+func looksSecret(path string, value interface{}) bool {
+	if _, segment := splitLastSegment(path); strings.HasPrefix(segment, ".") {
+		key := strings.TrimPrefix(segment, ".")
+		if secretKeyPattern.MatchString(key) && !secretReferencePattern.MatchString(key) {
+			return true
+		}
+	}
+	s, ok := value.(string)
+	return ok && looksRandom(s)
+}
+
+// looksRandom checks if a string looks like a generated key or token: long,
+// of letters and digits both, and with a high entropy
+func looksRandom(s string) bool {
+	if len(s) < minSecretLength || !secretTextPattern.MatchString(s) {
+		return false
+	}
+	if strings.IndexFunc(s, unicode.IsDigit) < 0 || strings.IndexFunc(s, unicode.IsLetter) < 0 {
+		return false
+	}
+	return shannonEntropy(s) >= minSecretEntropy
+}
+
+// shannonEntropy returns the Shannon entropy of a string in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// documentSelection restricts the comparison to some documents, set with --docs
+type documentSelection struct {
+	ranges []positionRange // positions in either file, from 1
+	names  []string        // kind/namespace/name, kind/name or name
+}
+
+// positionRange is a range of document positions, both ends included
+type positionRange struct {
+	First, Last int
+}
+
+// selectedDocuments is the active selection; nil compares all documents
+var selectedDocuments *documentSelection
+
+// parseDocumentSelection parses a comma-separated list of document numbers,
+// ranges such as 4-6 and Kubernetes object names
+func parseDocumentSelection(spec string) (*documentSelection, error) {
+	selection := &documentSelection{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			return nil, fmt.Errorf("invalid --docs %q, empty entry", spec)
+		}
+		if entry[0] < '0' || entry[0] > '9' {
+			selection.names = append(selection.names, entry)
+			continue
+		}
+		firstText, lastText, isRange := strings.Cut(entry, "-")
+		first, err := strconv.Atoi(firstText)
+		last := first
+		if err == nil && isRange {
+			last, err = strconv.Atoi(lastText)
+		}
+		if err != nil || first < 1 || last < first {
+			return nil, fmt.Errorf("invalid --docs entry %q, expected a document number, a range such as 4-6 or a name", entry)
+		}
+		selection.ranges = append(selection.ranges, positionRange{First: first, Last: last})
+	}
+	return selection, nil
+}
+
+// selects checks if a pair of documents is selected, by the position or the
+// name of either document
+func (s *documentSelection) selects(pair documentPair, documents1, documents2 []YAMLDocument) bool {
+	if s == nil {
+		return true
+	}
+	for _, side := range []struct {
+		index     int
+		documents []YAMLDocument
+	}{{pair.Old, documents1}, {pair.New, documents2}} {
+		if side.index < 0 {
+			continue
+		}
+		if s.selectsPosition(side.index+1) || s.matchesName(side.documents[side.index].Data) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectsPosition checks if a document position, from 1, is in one of the ranges
+func (s *documentSelection) selectsPosition(position int) bool {
+	for _, r := range s.ranges {
+		if position >= r.First && position <= r.Last {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesName checks if a document is a Kubernetes object named in the selection
+func (s *documentSelection) matchesName(doc interface{}) bool {
+	if len(s.names) == 0 {
+		return false
+	}
+	identity, ok := kubernetesIdentity(doc)
+	if !ok {
+		return false
+	}
+	parts := strings.SplitN(identity, "/", 3)
+	kind, name := parts[0], parts[2]
+	for _, selected := range s.names {
+		if selected == identity || selected == kind+"/"+name || selected == name {
+			return true
+		}
+	}
+	return false
+}
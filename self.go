@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// runSelf diffs two subtrees of the documents of the same file and prints the
+// changes turning the first subtree into the second. It returns the comparison
+// of every document having both subtrees.
+func runSelf(file, fromPath, toPath string) (Result, error) {
+	defer resetFormattedValues()
+	documents, err := parseInput(file, "")
+	if err != nil {
+		return Result{}, err
+	}
+	fromPath, toPath = normalizePathArgument(fromPath), normalizePathArgument(toPath)
+	normalizeDocuments(documents)
+
+	result := Result{TotalDocuments: len(documents)}
+	compared, shownChanges, omittedChanges := 0, 0, 0
+	for i, document := range documents {
+		from, hasFrom := lookupPath(document.Data, fromPath)
+		to, hasTo := lookupPath(document.Data, toPath)
+		if !hasFrom || !hasTo {
+			continue
+		}
+		compared++
+
+		// The subtrees are compared as the documents of two files are
+		oldDocuments, newDocuments := []YAMLDocument{{Data: from}}, []YAMLDocument{{Data: to}}
+		mapDocuments(oldDocuments)
+		subtrees := compareSingle(oldDocuments, newDocuments)
+		separator := fmt.Sprintf("--- # YAML Document: %d/%d, %s%s%s", i+1, len(documents), debugPath(fromPath), arrow(), debugPath(toPath))
+		shown, omitted := printSingle(subtrees, separator, shownChanges)
+		shownChanges += shown
+		omittedChanges += omitted
+		for _, subtree := range subtrees.Documents {
+			subtree.Index = i
+			result.Add(subtree)
+		}
+	}
+
+	if compared == 0 {
+		return Result{}, fmt.Errorf("no document of %s has both %s and %s", file, debugPath(fromPath), debugPath(toPath))
+	}
+	printSingleTotal(result, omittedChanges)
+	return result, nil
+}
+
+// parseDocsSpec parses a --docs argument of the form N:M into 0-based document indexes
+func parseDocsSpec(spec string) (int, int, error) {
+	fromText, toText, found := strings.Cut(spec, ":")
+	from, fromErr := strconv.Atoi(fromText)
+	to, toErr := strconv.Atoi(toText)
+	if !found || fromErr != nil || toErr != nil || from < 1 || to < 1 {
+		return 0, 0, fmt.Errorf("invalid --docs %q, expected two document numbers such as 1:3", spec)
+	}
+	return from - 1, to - 1, nil
+}
+
+// runDocs diffs two documents of the same file and prints the changes turning
+// the first document into the second. It returns their comparison.
+func runDocs(file string, from, to int) (Result, error) {
+	defer resetFormattedValues()
+	documents, err := parseInput(file, "")
+	if err != nil {
+		return Result{}, err
+	}
+	for _, index := range []int{from, to} {
+		if index >= len(documents) {
+			return Result{}, fmt.Errorf("%s has no document %d (it has %d)", file, index+1, len(documents))
+		}
+	}
+
+	normalizeDocuments(documents)
+	oldDocuments, newDocuments := []YAMLDocument{documents[from]}, []YAMLDocument{documents[to]}
+	mapDocuments(oldDocuments)
+	result := compareSingle(oldDocuments, newDocuments)
+	_, omitted := printSingle(result, fmt.Sprintf("--- # YAML Documents: %d%s%d of %d", from+1, arrow(), to+1, len(documents)), 0)
+	printSingleTotal(result.Result, omitted)
+	return result.Result, nil
+}
+
+// compareSingle compares the only document of each side
+func compareSingle(documents1, documents2 []YAMLDocument) comparison {
+	return comparePairs(documents1, documents2, []documentPair{{Old: 0, New: 0}})
+}
+
+// printSingle prints the changes of a comparison of single documents under
+// the given separator, after the given number of changes already shown. It
+// returns the numbers of changes shown and left out by --limit.
+func printSingle(result comparison, separator string, shown int) (int, int) {
+	if len(result.Documents) == 0 {
+		return 0, 0
+	}
+	changes := result.documentChanges(0)
+	if masking() {
+		changes = maskChanges(changes)
+	}
+	changes, omitted := limitChanges(changes, shown)
+	if len(changes) == 0 {
+		return 0, omitted
+	}
+
+	blue := color.New(color.FgBlue)
+	if noDocComment {
+		blue.Println("---")
+	} else {
+		blue.Println(separator)
+	}
+	fmt.Print(generateColoredDiff(changes))
+	fmt.Println()
+	return len(changes), omitted
+}
+
+// printSingleTotal ends the output of the comparisons of single documents,
+// noting the changes left out by --limit or that there were none
+func printSingleTotal(result Result, omitted int) {
+	if omitted > 0 {
+		color.New(color.FgBlue).Println(formatOmitted(omitted))
+	}
+	if result.Counts.Total() == 0 {
+		fmt.Print("No changes found.\n")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// semverPattern matches a semantic version, with an optional leading v
+var semverPattern = regexp.MustCompile(`^v?(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)\.(0|[1-9][0-9]*)(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// semver is a parsed semantic version
+type semver struct {
+	Major, Minor, Patch int
+	Prerelease          string
+	Build               string
+}
+
+// parseSemver reads a string holding a semantic version such as 1.2.3 or v2.0.0-rc.1
+func parseSemver(v interface{}) (semver, bool) {
+	s, ok := v.(string)
+	if !ok {
+		return semver{}, false
+	}
+	match := semverPattern.FindStringSubmatch(s)
+	if match == nil {
+		return semver{}, false
+	}
+	var version semver
+	var err error
+	for i, part := range []*int{&version.Major, &version.Minor, &version.Patch} {
+		if *part, err = strconv.Atoi(match[i+1]); err != nil {
+			return semver{}, false
+		}
+	}
+	version.Prerelease, version.Build = match[4], match[5]
+	return version, true
+}
+
+// compareSemver orders two versions by precedence: -1, 0 or 1. Build
+// metadata is left out, and a pre-release comes before its release.
+func compareSemver(a, b semver) int {
+	for _, pair := range [][2]int{{a.Major, b.Major}, {a.Minor, b.Minor}, {a.Patch, b.Patch}} {
+		if pair[0] != pair[1] {
+			return compareInts(pair[0], pair[1])
+		}
+	}
+	switch {
+	case a.Prerelease == b.Prerelease:
+		return 0
+	case a.Prerelease == "":
+		return 1
+	case b.Prerelease == "":
+		return -1
+	}
+
+	partsA, partsB := strings.Split(a.Prerelease, "."), strings.Split(b.Prerelease, ".")
+	for i := 0; i < len(partsA) && i < len(partsB); i++ {
+		if partsA[i] == partsB[i] {
+			continue
+		}
+		numberA, errA := strconv.Atoi(partsA[i])
+		numberB, errB := strconv.Atoi(partsB[i])
+		switch {
+		case errA == nil && errB == nil:
+			return compareInts(numberA, numberB)
+		case errA == nil:
+			return -1
+		case errB == nil:
+			return 1
+		default:
+			return strings.Compare(partsA[i], partsB[i])
+		}
+	}
+	return compareInts(len(partsA), len(partsB))
+}
+
+// compareInts orders two integers: -1, 0 or 1
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// semverBump classifies the change between two semantic versions by the
+// most significant part that differs: major, minor, patch, prerelease or
+// build. It reports whether the new version comes before the old one.
+func semverBump(oldVal, newVal interface{}) (string, bool, bool) {
+	oldVersion, okOld := parseSemver(oldVal)
+	newVersion, okNew := parseSemver(newVal)
+	if !okOld || !okNew {
+		return "", false, false
+	}
+	level := "build"
+	switch {
+	case oldVersion.Major != newVersion.Major:
+		level = "major"
+	case oldVersion.Minor != newVersion.Minor:
+		level = "minor"
+	case oldVersion.Patch != newVersion.Patch:
+		level = "patch"
+	case oldVersion.Prerelease != newVersion.Prerelease:
+		level = "prerelease"
+	}
+	return level, compareSemver(newVersion, oldVersion) < 0, true
+}
+
+// formatSemverBump describes the change between two semantic versions, e.g.
+// minor or major downgrade, or nothing if either isn't a semantic version
+func formatSemverBump(oldVal, newVal interface{}) string {
+	level, downgrade, ok := semverBump(oldVal, newVal)
+	if !ok {
+		return ""
+	}
+	if downgrade {
+		return level + " downgrade"
+	}
+	return level
+}
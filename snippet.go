@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// showSource prints the source lines of each change from both files, set with --show-source
+var showSource bool
+
+// sourceLines holds the lines of the old and new files shown with --show-source
+var sourceLines [2][]string
+
+// splitSourceLines splits file content into lines for --show-source
+func splitSourceLines(data []byte) []string {
+	return strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// lastLine returns the last line a node spans in its source. Block scalars
+// continue below their indicator for as many lines as their value has.
+func lastLine(node *yaml.Node) int {
+	if node == nil {
+		return 0
+	}
+	last := node.Line
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if node.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+			last += strings.Count(strings.TrimSuffix(node.Value, "\n"), "\n") + 1
+		}
+	case yaml.AliasNode:
+		// The anchored value is elsewhere in the file
+	default:
+		for _, child := range node.Content {
+			last = max(last, lastLine(child))
+		}
+	}
+	return last
+}
+
+// formatSourceSnippet formats the lines a change spans in the old and new
+// files, numbered as in the files, under the names of the files
+func formatSourceSnippet(change Change, indent string) string {
+	var result strings.Builder
+	sides := []struct {
+		line   int
+		node   *yaml.Node
+		header string
+		theme  string
+	}{
+		{change.OldLine, change.OldNode, "--- " + locationFiles[0], theme.Deletion},
+		{change.NewLine, change.NewNode, "+++ " + locationFiles[1], theme.Addition},
+	}
+	for i, side := range sides {
+		lines := sourceLines[i]
+		if side.line <= 0 || side.line > len(lines) {
+			continue
+		}
+		end := min(max(lastLine(side.node), side.line), len(lines))
+		width := len(fmt.Sprint(end))
+
+		sideColor := themeColor(side.theme)
+		result.WriteString(indent + sideColor.Sprint(side.header) + "\n")
+		for n := side.line; n <= end; n++ {
+			number := themeColor(theme.Comment).Sprintf("%*d |", width, n)
+			result.WriteString(indent + number + " " + sideColor.Sprint(lines[n-1]) + "\n")
+		}
+	}
+	return result.String()
+}
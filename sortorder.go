@@ -0,0 +1,80 @@
+package main
+
+import (
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sortOrders lists the orders changes can be listed in with --sort
+var sortOrders = []string{"path", "type", "source", "none"}
+
+// typeOrder ranks the types of changes when sorting by type
+var typeOrder = map[ChangeType]int{Deletion: 0, Addition: 1, Modification: 2, Move: 3}
+
+// sortOrder is the order changes are listed in, set with --sort
+var sortOrder = "path"
+
+// sortChanges orders changes alphabetically by path, or with --sort type by
+// path within deletions, additions, modifications and moves. Changes sorted by
+// source are already in order, and with --sort none they are left in the
+// order they were found in.
+func sortChanges(changes []Change) {
+	switch sortOrder {
+	case "path":
+		sort.SliceStable(changes, func(i, j int) bool {
+			return changes[i].Path < changes[j].Path
+		})
+	case "type":
+		sort.SliceStable(changes, func(i, j int) bool {
+			if a, b := typeOrder[changes[i].Type], typeOrder[changes[j].Type]; a != b {
+				return a < b
+			}
+			return changes[i].Path < changes[j].Path
+		})
+	}
+}
+
+// sortsParents checks if the parent headers of --group-by-parent are sorted
+// by path, rather than following the order of their first change
+func sortsParents() bool {
+	return sortOrder == "path" || sortOrder == "type"
+}
+
+// sortBySource orders changes by the line of their entry in the new file, and
+// by path on the same line. Removed entries are placed at the line of their
+// closest parent left in the new file.
+func sortBySource(changes []Change, newRoot *yaml.Node, newData interface{}) {
+	lines := make([]int, len(changes))
+	for i, change := range changes {
+		path := change.Path
+		if change.Type == Deletion {
+			path, _ = splitLastSegment(path)
+		}
+		for {
+			lines[i] = entryLine(newRoot, newData, path)
+			if lines[i] > 0 || path == "" {
+				break
+			}
+			path, _ = splitLastSegment(path)
+		}
+	}
+
+	order := make([]int, len(changes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := order[i], order[j]
+		if lines[a] != lines[b] {
+			return lines[a] < lines[b]
+		}
+		return changes[a].Path < changes[b].Path
+	})
+
+	sorted := make([]Change, len(changes))
+	for i, index := range order {
+		sorted[i] = changes[index]
+	}
+	copy(changes, sorted)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"ymldiff/pkg/ymldiff"
+)
+
+// locateNode finds the source node for a change path. The normalized value of
+// the node is walked alongside it, since list indices and identifiers in paths
+// refer to the normalized form rather than to the order in the source file.
+func locateNode(node *yaml.Node, value interface{}, path string) *yaml.Node {
+	_, valueNode := locateEntry(node, value, path, nil)
+	return valueNode
+}
+
+// locateEntry finds the source nodes for a change path like locateNode, also
+// returning the key node of the entry when the path ends in a mapping key
+func locateEntry(node *yaml.Node, value interface{}, path string, keyNode *yaml.Node) (*yaml.Node, *yaml.Node) {
+	node = resolveNode(node)
+	if node == nil {
+		return nil, nil
+	}
+	if path == "" {
+		return keyNode, node
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		m, ok := value.(map[interface{}]interface{})
+		if !ok || !strings.HasPrefix(path, ".") {
+			return nil, nil
+		}
+
+		// Pick the longest key matching the start of the path, since keys may contain dots
+		matchIndex, matchLen := -1, -1
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if len(key) > matchLen && isPathSegment(path[1:], key) {
+				matchIndex, matchLen = i, len(key)
+			}
+		}
+		if matchIndex < 0 {
+			return nil, nil
+		}
+
+		keyStr := node.Content[matchIndex].Value
+		for key, child := range m {
+			if fmt.Sprintf("%v", key) == keyStr {
+				return locateEntry(node.Content[matchIndex+1], child, path[1+matchLen:], node.Content[matchIndex])
+			}
+		}
+		return nil, nil
+
+	case yaml.SequenceNode:
+		slice, ok := value.([]interface{})
+		if !ok || !strings.HasPrefix(path, "[") || len(slice) != len(node.Content) {
+			return nil, nil
+		}
+
+		// Keyed lists keep their source order during normalization
+		if isSliceOfDictsWithIds(slice) {
+			for i, item := range slice {
+				if id, ok := itemIdentifier(item); ok && isPathSegment(path[1:], id+"]") {
+					return locateEntry(node.Content[i], item, path[len(id)+2:], nil)
+				}
+			}
+			return nil, nil
+		}
+
+		end := strings.Index(path, "]")
+		if end < 0 {
+			return nil, nil
+		}
+		var index int
+		if _, err := fmt.Sscanf(path[1:end], "%d", &index); err != nil || index < 0 || index >= len(slice) {
+			return nil, nil
+		}
+
+		// Sorted lists are mapped back to the source by comparing normalized values
+		for _, child := range node.Content {
+			var decoded interface{}
+			if err := child.Decode(&decoded); err != nil {
+				continue
+			}
+			if reflect.DeepEqual(normalizeValue(decoded), slice[index]) {
+				return locateEntry(child, slice[index], path[end+1:], nil)
+			}
+		}
+		return nil, nil
+	}
+
+	return nil, nil
+}
+
+// resolveNode unwraps document and alias nodes
+func resolveNode(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		switch node.Kind {
+		case yaml.DocumentNode:
+			if len(node.Content) == 0 {
+				return nil
+			}
+			node = node.Content[0]
+		case yaml.AliasNode:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+	return nil
+}
+
+// isPathSegment checks if path starts with the given segment followed by the end of the path or another segment
+func isPathSegment(path, segment string) bool {
+	return ymldiff.IsPathSegment(path, segment)
+}
+
+// attachSourceNodes records the source nodes of the old and new values of each
+// change. Values holding masked parts are left without, so they aren't printed
+// from their source.
+func attachSourceNodes(changes []Change, oldRoot, newRoot *yaml.Node, oldData, newData interface{}) {
+	for i := range changes {
+		if changes[i].OldValue != nil && oldRoot != nil && !holdsMask(changes[i].OldValue) {
+			changes[i].OldNode = locateNode(oldRoot, oldData, changes[i].Path)
+		}
+		if changes[i].NewValue != nil && newRoot != nil && !holdsMask(changes[i].NewValue) {
+			changes[i].NewNode = locateNode(newRoot, newData, changes[i].Path)
+		}
+	}
+}
+
+// stripComments returns a deep copy of a node without any comments
+func stripComments(node *yaml.Node) *yaml.Node {
+	clone := *node
+	clone.HeadComment = ""
+	clone.LineComment = ""
+	clone.FootComment = ""
+	if node.Content != nil {
+		clone.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			clone.Content[i] = stripComments(child)
+		}
+	}
+	return &clone
+}
+
+// formatSourceValue formats a value for display, keeping the key order of its
+// source node for complex values when --preserve-key-order is set
+func formatSourceValue(v interface{}, node *yaml.Node) string {
+	if !preserveKeyOrder || node == nil {
+		return formatValue(v)
+	}
+	if node.Kind != yaml.MappingNode && node.Kind != yaml.SequenceNode {
+		return formatValue(v)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(3) // 3-space indentation
+	if err := encoder.Encode(stripComments(node)); err != nil {
+		return formatValue(v)
+	}
+	encoder.Close()
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
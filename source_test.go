@@ -0,0 +1,361 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+// TestLocateNode tests finding source nodes for change paths
+func TestLocateNode(t *testing.T) {
+	content := `metadata:
+  annotations:
+    example.com/owner: team-a
+spec:
+  containers:
+    - name: app
+      image: app:1
+    - name: sidecar
+      image: proxy:1
+  args:
+    - zeta
+    - alpha
+`
+	file := createTempFile(t, "locate.yaml", content)
+	defer os.Remove(file)
+
+	docs, err := parseYAML(file)
+	if err != nil {
+		t.Fatalf("Failed to parse file: %v", err)
+	}
+
+	tests := []struct {
+		path     string
+		expected string
+		line     int
+	}{
+		{".metadata.annotations.example.com/owner", "team-a", 3},
+		{".spec.containers[sidecar].image", "proxy:1", 9},
+		{".spec.args[0]", "alpha", 12},
+		{".spec.args[1]", "zeta", 11},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			node := locateNode(docs[0].Node, docs[0].Data, tt.path)
+			if node == nil {
+				t.Fatal("Expected to locate node, got nil")
+			}
+			if node.Value != tt.expected || node.Line != tt.line {
+				t.Errorf("Expected %q at line %d, got %q at line %d", tt.expected, tt.line, node.Value, node.Line)
+			}
+		})
+	}
+
+	if node := locateNode(docs[0].Node, docs[0].Data, ".spec.missing"); node != nil {
+		t.Errorf("Expected nil for missing path, got %v", node.Value)
+	}
+}
+
+// TestPreserveKeyOrder tests that added values keep their source key order
+func TestPreserveKeyOrder(t *testing.T) {
+	originalPreserveKeyOrder := preserveKeyOrder
+	defer func() { preserveKeyOrder = originalPreserveKeyOrder }()
+
+	file1 := createTempFile(t, "order1.yaml", "name: app\n")
+	defer os.Remove(file1)
+	file2 := createTempFile(t, "order2.yaml", "name: app\nspec:\n  zeta: 1 # trailing\n  alpha: 2\n")
+	defer os.Remove(file2)
+
+	docs1, err := parseYAML(file1)
+	if err != nil {
+		t.Fatalf("Failed to parse file1: %v", err)
+	}
+	docs2, err := parseYAML(file2)
+	if err != nil {
+		t.Fatalf("Failed to parse file2: %v", err)
+	}
+
+	changes := diffValues(docs1[0].Data, docs2[0].Data, "")
+	attachSourceNodes(changes, docs1[0].Node, docs2[0].Node, docs1[0].Data, docs2[0].Data)
+
+	preserveKeyOrder = false
+	output := generateColoredDiff(changes)
+	if strings.Index(output, "alpha") > strings.Index(output, "zeta") {
+		t.Errorf("Expected sorted keys without --preserve-key-order, got:\n%s", output)
+	}
+
+	preserveKeyOrder = true
+	output = generateColoredDiff(changes)
+	if strings.Index(output, "zeta") > strings.Index(output, "alpha") {
+		t.Errorf("Expected source key order with --preserve-key-order, got:\n%s", output)
+	}
+	if strings.Contains(output, "trailing") {
+		t.Errorf("Expected comments to be stripped from displayed values, got:\n%s", output)
+	}
+}
+
+// parseNode parses a YAML document into its node and normalized data
+func parseNode(t *testing.T, source string) (*yaml.Node, interface{}) {
+	t.Helper()
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte(source), &node); err != nil {
+		t.Fatal(err)
+	}
+	var data interface{}
+	if err := node.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	return &node, normalizeValue(data)
+}
+
+// TestAttachLocations tests that changes are located in the files they exist in
+func TestAttachLocations(t *testing.T) {
+	defer func(files [2]string) { locationFiles = files }(locationFiles)
+	locationFiles = [2]string{"old.yaml", "new.yaml"}
+
+	oldNode, oldData := parseNode(t, "spec:\n  replicas: 3\n  legacy: on\n  template: {image: a}\n")
+	newNode, newData := parseNode(t, "spec:\n  replicas: 5\n  paused: true\n  template:\n    image: b\n")
+	changes := []Change{
+		{Type: Modification, Path: ".spec.replicas", OldValue: 3, NewValue: 5},
+		{Type: Addition, Path: ".spec.paused", NewValue: true},
+		{Type: Deletion, Path: ".spec.legacy", OldValue: "on"},
+		{Type: Modification, Path: ".spec.template.image", OldValue: "a", NewValue: "b"},
+	}
+	attachLocations(changes, oldNode, newNode, oldData, newData)
+
+	expected := []string{
+		"old.yaml:2:3, new.yaml:2:3",
+		"new.yaml:3:3",
+		"old.yaml:3:3",
+		"old.yaml:4:14, new.yaml:5:5",
+	}
+	for i, change := range changes {
+		if got := formatLocation(change); got != expected[i] {
+			t.Errorf("Change %s: expected location %q, got %q", change.Path, expected[i], got)
+		}
+	}
+}
+
+// TestParseBlame tests parsing git blame porcelain output
+func TestParseBlame(t *testing.T) {
+	output := "81d07be5b0c4a1d5f2e3a4b5c6d7e8f9a0b1c2d3 1 1 1\n" +
+		"author alice\n" +
+		"author-time 1718100000\n" +
+		"summary Scale up\n" +
+		"filename config.yaml\n" +
+		"\treplicas: 5\n" +
+		"0000000000000000000000000000000000000000 2 2 1\n" +
+		"author Not Committed Yet\n" +
+		"author-time 1718200000\n" +
+		"filename config.yaml\n" +
+		"\tdebug: true\n"
+
+	blame := parseBlame([]byte(output))
+	if len(blame) != 2 {
+		t.Fatalf("Expected 2 lines, got %+v", blame)
+	}
+	if got := blame[1].String(); got != "alice in 81d07be (2024-06-11)" {
+		t.Errorf("Unexpected attribution for line 1: %q", got)
+	}
+	if got := blame[2].String(); got != "not committed yet" {
+		t.Errorf("Unexpected attribution for line 2: %q", got)
+	}
+}
+
+// TestAttachBlame tests that added and modified values are attributed to their line
+func TestAttachBlame(t *testing.T) {
+	var node yaml.Node
+	if err := yaml.Unmarshal([]byte("replicas: 5\ndebug: true\n"), &node); err != nil {
+		t.Fatal(err)
+	}
+	var data interface{}
+	if err := node.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+	data = normalizeValue(data)
+
+	changes := []Change{
+		{Type: Modification, Path: ".replicas", OldValue: 3, NewValue: 5},
+		{Type: Addition, Path: ".debug", NewValue: true},
+		{Type: Deletion, Path: ".legacy", OldValue: "on"},
+	}
+	blame := map[int]blameLine{
+		1: {ShortHash: "81d07be", Author: "alice", Date: "2024-06-11"},
+		2: {ShortHash: "3f2a9c1", Author: "bob", Date: "2024-05-02"},
+	}
+	attachBlame(changes, &node, data, blame)
+
+	expected := []string{"alice in 81d07be (2024-06-11)", "bob in 3f2a9c1 (2024-05-02)", ""}
+	for i, change := range changes {
+		if change.Blame != expected[i] {
+			t.Errorf("Change %s: expected blame %q, got %q", change.Path, expected[i], change.Blame)
+		}
+	}
+}
+
+// TestFileBlameRevision tests blaming a FILE@REV input as the file was in that revision
+func TestFileBlameRevision(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+	dir := t.TempDir()
+	if _, err := runGit(dir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	commitFile(t, dir, "config.yaml", "replicas: 1\n", "alice")
+	commitFile(t, dir, "config.yaml", "replicas: 2\n", "bob")
+
+	file := filepath.Join(dir, "config.yaml")
+	blame, err := fileBlame(file + "@HEAD~1")
+	if err != nil {
+		t.Fatalf("fileBlame() error: %v", err)
+	}
+	if blame[1].Author != "alice" {
+		t.Errorf("Expected line 1 of HEAD~1 to be blamed on alice, got %+v", blame[1])
+	}
+
+	if blame, err = fileBlame(file); err != nil || blame[1].Author != "bob" {
+		t.Errorf("Expected line 1 to be blamed on bob, got %+v, %v", blame[1], err)
+	}
+}
+
+// TestGenerateAnnotated tests that change markers are placed on the lines of the new file
+func TestGenerateAnnotated(t *testing.T) {
+	originalNoColor := color.NoColor
+	defer func() { color.NoColor = originalNoColor }()
+	color.NoColor = true
+
+	oldSource := "spec:\n  replicas: 3 # keep\n  paused: false\n  containers:\n  - name: app\n    image: app:1\n"
+	newSource := "spec:\n  replicas: 5 # keep\n  containers:\n  - name: app\n    image: app:2\n    args: [--verbose]\n"
+
+	var oldData, newData interface{}
+	if err := yaml.Unmarshal([]byte(oldSource), &oldData); err != nil {
+		t.Fatal(err)
+	}
+	var newNode yaml.Node
+	if err := yaml.Unmarshal([]byte(newSource), &newNode); err != nil {
+		t.Fatal(err)
+	}
+	if err := newNode.Decode(&newData); err != nil {
+		t.Fatal(err)
+	}
+	oldData, newData = normalizeValue(oldData), normalizeValue(newData)
+
+	a := newAnnotations()
+	a.addChanges(diffValues(oldData, newData, ""), &newNode, newData)
+	a.addChanges([]Change{{Type: Deletion, Path: "", OldValue: map[interface{}]interface{}{"kind": "Service"}}}, nil, nil)
+
+	output := generateAnnotated([]byte(newSource), a)
+	expected := `spec:  # ymldiff: removed paused (was false)
+  replicas: 5 # keep  # ymldiff: changed from 3
+  containers:
+  - name: app
+    image: app:2  # ymldiff: changed from app:1
+    args: [--verbose]  # ymldiff: added
+# ymldiff: removed document (was <map, 1 key>)
+`
+	if output != expected {
+		t.Errorf("Unexpected annotated output:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+// TestMaskChanges tests masking the values at matching paths in changes
+func TestMaskChanges(t *testing.T) {
+	defer func(patterns []string) { maskPatterns = patterns }(maskPatterns)
+	color.NoColor = true
+
+	maskPatterns = []string{".**.password", ".users.*.token"}
+	changes := []Change{
+		{Type: Modification, Path: ".db.password", OldValue: "old", NewValue: "new"},
+		{Type: Addition, Path: ".users", NewValue: map[interface{}]interface{}{
+			"alice": map[interface{}]interface{}{"token": "abc", "role": "admin"},
+		}},
+		{Type: Modification, Path: ".db.host", OldValue: "a", NewValue: "b"},
+	}
+	original := changes
+	changes = maskChanges(changes)
+
+	if original[0].NewValue != "new" {
+		t.Errorf("Expected the changes to be kept as they are, got %v", original[0])
+	}
+	if changes[0].OldValue != maskText || changes[0].NewValue != maskText {
+		t.Errorf("Expected .db.password to be masked, got %v", changes[0])
+	}
+	user := changes[1].NewValue.(map[interface{}]interface{})["alice"].(map[interface{}]interface{})
+	if user["token"] != maskText || user["role"] != "admin" {
+		t.Errorf("Expected only the nested token to be masked, got %v", user)
+	}
+	if !holdsMask(changes[1].NewValue) || holdsMask(changes[2].NewValue) {
+		t.Error("Expected holdsMask to find only the masked values")
+	}
+	if output := generateColoredDiff(changes); strings.Contains(output, "abc") || strings.Contains(output, "old") {
+		t.Errorf("Expected masked values not to be shown, got:\n%s", output)
+	}
+}
+
+// TestMaskedUnified tests that masked values are compared before they are
+// masked in unified diffs
+func TestMaskedUnified(t *testing.T) {
+	defer func(patterns []string) { maskPatterns = patterns }(maskPatterns)
+	color.NoColor = true
+
+	maskPatterns = []string{"*.password"}
+	result := Result{Documents: []DocumentResult{{
+		OldData: map[interface{}]interface{}{"db": map[interface{}]interface{}{"password": "hunter2", "host": "a"}},
+		NewData: map[interface{}]interface{}{"db": map[interface{}]interface{}{"password": "swordfish", "host": "a"}},
+	}}}
+	expected := `--- old.yaml
++++ new.yaml
+@@ -1,3 +1,3 @@
+ db:
+   host: a
+-  password: *****
++  password: *****
+`
+	if got := formatUnified(result, "old.yaml", "new.yaml"); got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+}
+
+// TestMaskedAnnotated tests that masked values are masked in the annotated new file
+func TestMaskedAnnotated(t *testing.T) {
+	defer func(patterns []string) { maskPatterns = patterns }(maskPatterns)
+	color.NoColor = true
+
+	maskPatterns = []string{".**.password", ".tls", ".token"}
+	source := "password: hunter2 # root\ndb:\n  host: a\n  password: \"swordfish\"\ntls:\n  key: |\n    secret\n\n    key\n" +
+		"cache: {password: x, host: y}\ntoken: !vault 'ab''c'\n"
+	var node yaml.Node
+	var data interface{}
+	if err := yaml.Unmarshal([]byte(source), &node); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.Decode(&data); err != nil {
+		t.Fatal(err)
+	}
+
+	a := newAnnotations()
+	a.addChanges([]Change{{Type: Modification, Path: ".cache.host", OldValue: "z", NewValue: "y"}}, &node, normalizeValue(data))
+	a.addMasked(&node, normalizeValue(data))
+	expected := "password: \"*****\" # root\ndb:\n  host: a\n  password: \"*****\"\ntls:\n  key: \"*****\"\n" +
+		"cache: {password: \"*****\", host: y}  # ymldiff: changed from z\ntoken: !vault \"*****\"\n"
+	got := generateAnnotated([]byte(source), a)
+	if got != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, got)
+	}
+
+	// The annotated file still parses, with the values next to masked ones kept
+	var annotated map[string]interface{}
+	if err := yaml.Unmarshal([]byte(got), &annotated); err != nil {
+		t.Fatalf("Annotated output doesn't parse: %v", err)
+	}
+	if cache := annotated["cache"].(map[string]interface{}); cache["host"] != "y" || cache["password"] != maskText {
+		t.Errorf("Unexpected flow map after masking: %v", cache)
+	}
+}
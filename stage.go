@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// stagedChange is a detected change along with the patch operation making it
+type stagedChange struct {
+	Change    Change
+	Operation patchOperation
+}
+
+// newStagedChanges pairs the changes of a document, sorted by path, with
+// their patch operations against the given document of the first file.
+// Moves of list items cannot be staged and are left out.
+func newStagedChanges(changes []Change, document int) []stagedChange {
+	sorted := append([]Change{}, changes...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Path < sorted[j].Path
+	})
+
+	staged := make([]stagedChange, 0, len(sorted))
+	for _, change := range sorted {
+		if change.Type == Move {
+			continue
+		}
+		staged = append(staged, stagedChange{Change: change, Operation: newPatchOperations([]Change{change}, document)[0]})
+	}
+	return staged
+}
+
+// stageChanges steps through the changes, in the style of git add -p, and
+// returns the operations of the accepted ones
+func stageChanges(reader *bufio.Reader, output io.Writer, changes []stagedChange) ([]patchOperation, error) {
+	var accepted []patchOperation
+	for i, staged := range changes {
+		fmt.Fprintf(output, "(%d/%d) ", i+1, len(changes))
+		accept, stop, err := confirmChange(reader, output, staged.Change, "Stage this change")
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if stop {
+			break
+		}
+		if accept {
+			accepted = append(accepted, staged.Operation)
+		}
+	}
+	return accepted, nil
+}
+
+// mergeAccepted applies accepted operations to the first file and returns the merged YAML
+func mergeAccepted(file string, operations []patchOperation) ([]byte, error) {
+	data, err := readInput(file)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := parseYAMLData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved, err := resolveOperations(operations, documents)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*yaml.Node, len(documents))
+	for i := range documents {
+		nodes[i] = documents[i].Node
+	}
+	for _, r := range resolved {
+		if nodes, err = applyResolved(r, nodes); err != nil {
+			return nil, err
+		}
+	}
+	return encodeDocuments(nodes)
+}
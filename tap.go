@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// formatTAP renders the comparison as a Test Anything Protocol stream with a
+// test point per document pair: ok when it is unchanged, not ok with the
+// changes in a YAML diagnostic block otherwise. Documents left out by --docs
+// or empty on both sides are skipped.
+func formatTAP(result comparison) string {
+	byIndex := make(map[int]int, len(result.Documents))
+	for position, document := range result.Documents {
+		byIndex[document.Index] = position
+	}
+
+	var output strings.Builder
+	output.WriteString("TAP version 13\n")
+	fmt.Fprintf(&output, "1..%d\n", result.TotalDocuments)
+	for i := 0; i < result.TotalDocuments; i++ {
+		position, compared := byIndex[i]
+		if !compared {
+			fmt.Fprintf(&output, "ok %d - document %d # SKIP not compared\n", i+1, i+1)
+			continue
+		}
+		changes := result.documentChanges(position)
+		if len(changes) == 0 {
+			fmt.Fprintf(&output, "ok %d - document %d\n", i+1, i+1)
+			continue
+		}
+		if masking() {
+			changes = maskChanges(changes)
+		}
+		fmt.Fprintf(&output, "not ok %d - document %d: %d %s\n", i+1, i+1, len(changes), pluralize(len(changes), "change", "changes"))
+		output.WriteString(tapDiagnostics(changes))
+	}
+	return output.String()
+}
+
+// tapDiagnostics formats the changes of a document as an indented YAML block
+func tapDiagnostics(changes []Change) string {
+	sorted := append([]Change{}, changes...)
+	sortChanges(sorted)
+	lines := make([]string, 0, len(sorted))
+	for _, change := range sorted {
+		lines = append(lines, tapChangeLine(change))
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(map[string][]string{"changes": lines}); err != nil {
+		return ""
+	}
+	encoder.Close()
+
+	var block strings.Builder
+	block.WriteString("  ---\n")
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		block.WriteString("  " + line + "\n")
+	}
+	block.WriteString("  ...\n")
+	return block.String()
+}
+
+// tapChangeLine describes a change in one line with the active markers
+func tapChangeLine(change Change) string {
+	prefix := markerFor(change.Type) + debugPath(change.Path) + ": "
+	switch change.Type {
+	case Addition:
+		return prefix + formatSingleLine(change.NewValue, maxAnnotationValueWidth)
+	case Deletion:
+		return prefix + formatSingleLine(change.OldValue, maxAnnotationValueWidth)
+	case Move:
+		return prefix + fmt.Sprintf("position %v%s%v", change.OldValue, arrow(), change.NewValue)
+	default:
+		return prefix + formatSingleLine(change.OldValue, maxAnnotationValueWidth) + arrow() + formatSingleLine(change.NewValue, maxAnnotationValueWidth)
+	}
+}
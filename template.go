@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// changeTemplate is the output template of each change, set with --format or
+// --format-file
+var changeTemplate *template.Template
+
+// changeTemplateFuncs are the helper functions available to change templates:
+// those of rendered inputs, and toJson
+var changeTemplateFuncs = template.FuncMap{
+	"toJson": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	},
+}
+
+// parseChangeTemplate parses a change template. Each change ends with a
+// newline unless the template already does.
+func parseChangeTemplate(text string) (*template.Template, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	tmpl, err := template.New("format").Option("missingkey=error").Funcs(templateFuncs).Funcs(changeTemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --format template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// formatChangeTemplate executes a change template for every change. Changes
+// have the fields of the JSON report: Document, Path, Type, Old, New and Count.
+// The missing Old of an addition and New of a deletion are empty strings, as
+// templates print nil as "<no value>".
+func formatChangeTemplate(tmpl *template.Template, changes []jsonChange) (string, error) {
+	var output strings.Builder
+	for _, change := range changes {
+		if change.Old == nil {
+			change.Old = ""
+		}
+		if change.New == nil {
+			change.New = ""
+		}
+		if err := tmpl.Execute(&output, change); err != nil {
+			return "", err
+		}
+	}
+	return output.String(), nil
+}
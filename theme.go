@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+// Theme holds the colors of the parts of the diff output. Each color is a
+// space-separated list of attributes, e.g. "bold red"; "none" disables coloring.
+type Theme struct {
+	Addition     string `yaml:"addition"`
+	Deletion     string `yaml:"deletion"`
+	Modification string `yaml:"modification"`
+	Move         string `yaml:"move"`
+	Key          string `yaml:"key"`
+	Scalar       string `yaml:"scalar"`
+	Comment      string `yaml:"comment"`
+	Context      string `yaml:"context"`
+	// Characters changed within short strings
+	InlineAddition string `yaml:"inline-addition"`
+	InlineDeletion string `yaml:"inline-deletion"`
+}
+
+// defaultTheme holds the colors used unless the config file sets others
+var defaultTheme = Theme{
+	Addition:     "green",
+	Deletion:     "red",
+	Modification: "yellow",
+	Move:         "magenta",
+	Key:          "cyan",
+	Scalar:       "none",
+	Comment:      "blue",
+	Context:      "faint",
+
+	InlineAddition: "black on-green",
+	InlineDeletion: "black on-red",
+}
+
+// theme is the active theme
+var theme = defaultTheme
+
+// colorAttributes maps the color names of a theme to their terminal attributes
+var colorAttributes = map[string]color.Attribute{
+	"black":      color.FgBlack,
+	"red":        color.FgRed,
+	"green":      color.FgGreen,
+	"yellow":     color.FgYellow,
+	"blue":       color.FgBlue,
+	"magenta":    color.FgMagenta,
+	"cyan":       color.FgCyan,
+	"white":      color.FgWhite,
+	"hi-black":   color.FgHiBlack,
+	"hi-red":     color.FgHiRed,
+	"hi-green":   color.FgHiGreen,
+	"hi-yellow":  color.FgHiYellow,
+	"hi-blue":    color.FgHiBlue,
+	"hi-magenta": color.FgHiMagenta,
+	"hi-cyan":    color.FgHiCyan,
+	"hi-white":   color.FgHiWhite,
+	"on-black":   color.BgBlack,
+	"on-red":     color.BgRed,
+	"on-green":   color.BgGreen,
+	"on-yellow":  color.BgYellow,
+	"on-blue":    color.BgBlue,
+	"on-magenta": color.BgMagenta,
+	"on-cyan":    color.BgCyan,
+	"on-white":   color.BgWhite,
+	"bold":       color.Bold,
+	"faint":      color.Faint,
+	"italic":     color.Italic,
+	"underline":  color.Underline,
+}
+
+// themeColor returns the color for a theme entry
+func themeColor(name string) *color.Color {
+	attributes, _ := parseThemeColor(name)
+	c := color.New(attributes...)
+	if len(attributes) == 0 {
+		// Without attributes the text is printed as is
+		c.DisableColor()
+	}
+	return c
+}
+
+// parseThemeColor parses a theme entry into its attributes
+func parseThemeColor(name string) ([]color.Attribute, error) {
+	var attributes []color.Attribute
+	for _, field := range strings.Fields(name) {
+		if field == "none" {
+			continue
+		}
+		attribute, ok := colorAttributes[field]
+		if !ok {
+			return nil, fmt.Errorf("unknown color %q (available: none, %s)", field, strings.Join(themeColorNames(), ", "))
+		}
+		attributes = append(attributes, attribute)
+	}
+	return attributes, nil
+}
+
+// themeColorNames returns the names of all colors in sorted order
+func themeColorNames() []string {
+	names := make([]string, 0, len(colorAttributes))
+	for name := range colorAttributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// mergeTheme returns the theme with the entries set in override replaced,
+// failing on unknown colors
+func mergeTheme(base, override Theme) (Theme, error) {
+	for _, entry := range []struct {
+		name     string
+		base     *string
+		override string
+	}{
+		{"addition", &base.Addition, override.Addition},
+		{"deletion", &base.Deletion, override.Deletion},
+		{"modification", &base.Modification, override.Modification},
+		{"move", &base.Move, override.Move},
+		{"key", &base.Key, override.Key},
+		{"scalar", &base.Scalar, override.Scalar},
+		{"comment", &base.Comment, override.Comment},
+		{"context", &base.Context, override.Context},
+		{"inline-addition", &base.InlineAddition, override.InlineAddition},
+		{"inline-deletion", &base.InlineDeletion, override.InlineDeletion},
+	} {
+		if entry.override == "" {
+			continue
+		}
+		if _, err := parseThemeColor(entry.override); err != nil {
+			return base, fmt.Errorf("theme %s: %w", entry.name, err)
+		}
+		*entry.base = entry.override
+	}
+	return base, nil
+}
+
+// colorizeBlock colors the map keys and scalars of a value formatted as YAML,
+// leaving the text of block scalars (| and >) as is
+func colorizeBlock(formatted string) string {
+	key := themeColor(theme.Key)
+	scalar := themeColor(theme.Scalar)
+
+	lines := strings.Split(formatted, "\n")
+	blockIndent := -1 // indentation of the line opening a block scalar
+	for i, line := range lines {
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent >= 0 {
+			if strings.TrimSpace(line) == "" || indent > blockIndent {
+				continue
+			}
+			blockIndent = -1
+		}
+
+		// Skip the markers of list items
+		rest := line[indent:]
+		for strings.HasPrefix(rest, "- ") {
+			indent += 2
+			rest = rest[2:]
+		}
+		if rest == "-" || rest == "" {
+			continue
+		}
+
+		var colored strings.Builder
+		colored.WriteString(line[:indent])
+		value := rest
+		if k, v, ok := splitMappingEntry(rest); ok {
+			colored.WriteString(key.Sprint(k))
+			colored.WriteString(":")
+			value = v
+			if value != "" {
+				colored.WriteString(" ")
+			}
+		}
+		if isBlockScalarHeader(value) {
+			blockIndent = len(line) - len(strings.TrimLeft(line, " "))
+			colored.WriteString(value)
+		} else if value != "" {
+			colored.WriteString(scalar.Sprint(value))
+		}
+		lines[i] = colored.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// splitMappingEntry splits a line of YAML into the key and value of a mapping
+// entry, if it is one. Quoted keys may contain ": ".
+func splitMappingEntry(s string) (string, string, bool) {
+	start := 0
+	if s[0] == '"' || s[0] == '\'' {
+		end := strings.IndexByte(s[1:], s[0])
+		if end < 0 {
+			return "", "", false
+		}
+		start = end + 2
+	}
+	if strings.HasSuffix(s, ":") && !strings.ContainsAny(s[start:len(s)-1], ": ") {
+		return s[:len(s)-1], "", true
+	}
+	if i := strings.Index(s[start:], ": "); i >= 0 {
+		if s[0] == '"' || s[0] == '\'' || !strings.ContainsAny(s[:start+i], "\"'") {
+			return s[:start+i], s[start+i+2:], true
+		}
+	}
+	return "", "", false
+}
+
+// isBlockScalarHeader checks if a value opens a literal or folded block scalar
+func isBlockScalarHeader(value string) bool {
+	if value == "" || (value[0] != '|' && value[0] != '>') {
+		return false
+	}
+	return strings.Trim(value[1:], "+-0123456789") == ""
+}
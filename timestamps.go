@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// timestampPatterns match the dates and times tools generate: ISO 8601 and
+// RFC 3339 date-times with or without seconds, fractions and zone (also as
+// printed once parsed from an unquoted YAML timestamp), and the RFC 1123 dates
+// of HTTP headers
+var timestampPatterns = []string{
+	`^\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?\s*(Z|[A-Z]{2,5}|[+-]\d{2}(:?\d{2})?( [A-Z]{2,5})?)?$`,
+	`^[A-Z][a-z]{2}, \d{1,2} [A-Z][a-z]{2} \d{4} \d{2}:\d{2}:\d{2} ([A-Z]{2,4}|[+-]\d{4})$`,
+}
+
+// timestampRules returns equivalence rules making any two values matching the
+// built-in timestamp patterns, or one of the given extra patterns, equal
+func timestampRules(extra []string) ([]EquivalenceRule, error) {
+	var rules []EquivalenceRule
+	for _, pattern := range append(append([]string{}, timestampPatterns...), extra...) {
+		rules = append(rules, EquivalenceRule{Path: "**", Pattern: pattern})
+	}
+	if err := ymldiff.CompileEquivalences(rules); err != nil {
+		return nil, fmt.Errorf("invalid --timestamp-pattern: %w", err)
+	}
+	return rules, nil
+}
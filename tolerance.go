@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ymldiff/pkg/ymldiff"
+)
+
+// ToleranceRule makes the numbers at the paths matching a pattern compare
+// equal when they differ by at most its epsilon
+type ToleranceRule = ymldiff.ToleranceRule
+
+// epsilon is the difference up to which numbers compare equal, set with --epsilon
+var epsilon float64
+
+// toleranceRules are the active tolerance rules: those given with --epsilon
+// PATTERN=EPSILON first, then those set in the config file
+var toleranceRules []ToleranceRule
+
+// parseEpsilon parses an --epsilon value: a tolerance for all numbers, or
+// PATTERN=EPSILON for the numbers at matching paths. A tolerance for all
+// numbers is returned as a rule without a path.
+func parseEpsilon(spec string) (ToleranceRule, error) {
+	var rule ToleranceRule
+	value := spec
+	if i := strings.LastIndex(spec, "="); i >= 0 {
+		rule.Path = normalizePathArgument(strings.TrimSpace(spec[:i]))
+		value = spec[i+1:]
+		if rule.Path == "" {
+			return ToleranceRule{}, fmt.Errorf("invalid --epsilon %q, expected a path before =", spec)
+		}
+	}
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || parsed < 0 {
+		return ToleranceRule{}, fmt.Errorf("invalid --epsilon %q, expected a number such as 0.001 or PATTERN=0.001", spec)
+	}
+	rule.Epsilon = parsed
+	return rule, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// unifiedContext is the number of unchanged lines around each hunk of a unified diff
+const unifiedContext = 3
+
+// lineEdit is a line of a line-by-line diff: kept (' '), removed ('-') or
+// added ('+'), with the positions of both files before the line
+type lineEdit struct {
+	Op      byte
+	Line    string
+	OldLine int
+	NewLine int
+}
+
+// canonicalLines renders the documents of one side of a comparison as YAML
+// with sorted keys and 2-space indentation, without the ignored paths. Values
+// that should be masked are rendered marked, to be masked once diffed.
+func canonicalLines(documents []DocumentResult, old bool) []string {
+	var lines []string
+	for _, document := range documents {
+		data := document.NewData
+		if old {
+			data = document.OldData
+		}
+		if data == nil {
+			continue
+		}
+		data, _ = pruneIgnored(data, "", ignorePatterns)
+		if masking() {
+			data = markMasked(data, "")
+		}
+
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(data); err != nil {
+			continue
+		}
+		encoder.Close()
+
+		if len(documents) > 1 {
+			lines = append(lines, "---")
+		}
+		lines = append(lines, strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")...)
+	}
+	return lines
+}
+
+// diffLines computes a shortest edit script turning one list of lines into
+// another with Myers' algorithm. Each step only keeps the diagonals it can
+// reach, so the trace takes space in the square of the number of edits
+// rather than in the number of edits times the number of lines.
+func diffLines(a, b []string) []lineEdit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	var trace [][]int
+
+search:
+	for d := 0; d <= n+m; d++ {
+		// Walking back through step d reads diagonals -d-1 to d+1 of the
+		// previous step, stored from index 0
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	// Walk back from the end through the furthest points of each step
+	var edits []lineEdit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k] < v[d+k+2]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+1+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, lineEdit{Op: ' ', Line: a[x], OldLine: x, NewLine: y})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			y--
+			edits = append(edits, lineEdit{Op: '+', Line: b[y], OldLine: x, NewLine: y})
+		} else {
+			x--
+			edits = append(edits, lineEdit{Op: '-', Line: a[x], OldLine: x, NewLine: y})
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// formatUnifiedDiff formats the differences between two lists of lines as a
+// unified diff with hunk headers, or nothing if they are the same
+func formatUnifiedDiff(oldName, newName string, a, b []string) string {
+	edits := diffLines(a, b)
+
+	var result strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].Op == ' ' {
+			i++
+			continue
+		}
+
+		// A hunk runs until the unchanged lines between two changes no
+		// longer fit in the context of both
+		start := max(i-unifiedContext, 0)
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].Op != ' ' {
+				end = j
+			} else if j-end > 2*unifiedContext {
+				break
+			}
+		}
+		stop := min(end+unifiedContext+1, len(edits))
+
+		if result.Len() == 0 {
+			result.WriteString(themeColor(theme.Deletion).Sprintf("--- %s", oldName) + "\n")
+			result.WriteString(themeColor(theme.Addition).Sprintf("+++ %s", newName) + "\n")
+		}
+		result.WriteString(formatHunk(edits[start:stop]))
+		i = stop
+	}
+	return result.String()
+}
+
+// formatHunk formats the lines of a hunk under its @@ header
+func formatHunk(edits []lineEdit) string {
+	var oldCount, newCount int
+	for _, edit := range edits {
+		if edit.Op != '+' {
+			oldCount++
+		}
+		if edit.Op != '-' {
+			newCount++
+		}
+	}
+
+	var result strings.Builder
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(edits[0].OldLine, oldCount), hunkRange(edits[0].NewLine, newCount))
+	result.WriteString(themeColor(theme.Comment).Sprint(header) + "\n")
+	for _, edit := range edits {
+		line := string(edit.Op) + edit.Line
+		switch edit.Op {
+		case '-':
+			line = themeColor(theme.Deletion).Sprint(line)
+		case '+':
+			line = themeColor(theme.Addition).Sprint(line)
+		}
+		result.WriteString(line + "\n")
+	}
+	return result.String()
+}
+
+// hunkRange formats the start and length of a hunk in one file. As in GNU
+// diff, an empty range starts at the line before it and a length of 1 is left out.
+func hunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	default:
+		return fmt.Sprintf("%d,%d", start+1, count)
+	}
+}
+
+// formatUnified renders both sides of a comparison canonically and formats
+// their differences as a unified diff. Masked values are compared before
+// being masked, so that a changed one still shows as a changed line.
+func formatUnified(result Result, file1, file2 string) string {
+	diff := formatUnifiedDiff(inputName("old", file1), inputName("new", file2),
+		canonicalLines(result.Documents, true), canonicalLines(result.Documents, false))
+	if masking() {
+		diff = maskedRendering.ReplaceAllString(diff, maskText)
+	}
+	return diff
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// warningOutput receives warnings about questionable comparisons
+var warningOutput io.Writer = os.Stderr
+
+// warnings collects the warnings written so far, each once
+var warnings []warning
+
+// warningsMu guards warnings, since environments are diffed concurrently
+var warningsMu sync.Mutex
+
+// warnAt records a warning about a path and writes it to stderr, unless the
+// same warning was already given
+func warnAt(path, format string, args ...interface{}) {
+	w := warning{Path: path, Message: fmt.Sprintf(format, args...)}
+
+	warningsMu.Lock()
+	defer warningsMu.Unlock()
+	for _, existing := range warnings {
+		if existing == w {
+			return
+		}
+	}
+	warnings = append(warnings, w)
+	fmt.Fprintf(warningOutput, "Warning: %s: %s\n", debugPath(w.Path), w.Message)
+}
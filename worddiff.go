@@ -0,0 +1,175 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"ymldiff/internal/intmath"
+)
+
+// maxWordDiffTokens bounds the number of words of strings diffed word by
+// word; longer strings are colored as a whole
+const maxWordDiffTokens = 5000
+
+// wordClass tells words, runs of whitespace and other characters apart
+func wordClass(r rune) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return 1
+	default:
+		return 2
+	}
+}
+
+// splitWords splits a string into words, runs of whitespace and single
+// punctuation characters, which joined together give back the string
+func splitWords(s string) []string {
+	var tokens []string
+	for start := 0; start < len(s); {
+		r, size := utf8.DecodeRuneInString(s[start:])
+		class := wordClass(r)
+		end := start + size
+		for class != 2 && end < len(s) {
+			next, nextSize := utf8.DecodeRuneInString(s[end:])
+			if wordClass(next) != class {
+				break
+			}
+			end += nextSize
+		}
+		tokens = append(tokens, s[start:end])
+		start = end
+	}
+	return tokens
+}
+
+// colorStringDiff colors the removed and added words of two strings. Inside
+// a changed word replaced by one of similar length sharing most characters,
+// such as a host name or a version number, only the changed characters are
+// highlighted.
+func colorStringDiff(oldStr, newStr string) (string, string) {
+	red := themeColor(theme.Deletion)
+	green := themeColor(theme.Addition)
+
+	oldWords, newWords := splitWords(oldStr), splitWords(newStr)
+	if len(oldWords) > maxWordDiffTokens || len(newWords) > maxWordDiffTokens {
+		return red.Sprint(oldStr), green.Sprint(newStr)
+	}
+
+	var oldResult, newResult strings.Builder
+	for _, hunk := range diffTokens(oldWords, newWords) {
+		oldText, newText := strings.Join(hunk.Old, ""), strings.Join(hunk.New, "")
+		switch {
+		case hunk.Kept:
+			oldResult.WriteString(oldText)
+			newResult.WriteString(newText)
+		case isSimilarWordPair(hunk.Old, hunk.New) && sharesMostCharacters(characterHunks(oldText, newText)):
+			oldText, newText = colorHunks(characterHunks(oldText, newText),
+				red.Sprint, themeColor(theme.InlineDeletion).Sprint, green.Sprint, themeColor(theme.InlineAddition).Sprint)
+			oldResult.WriteString(oldText)
+			newResult.WriteString(newText)
+		default:
+			oldResult.WriteString(sprintNonEmpty(red.Sprint, oldText))
+			newResult.WriteString(sprintNonEmpty(green.Sprint, newText))
+		}
+	}
+	return oldResult.String(), newResult.String()
+}
+
+// isSimilarWordPair checks if a single word was replaced by a single word of
+// similar length, differing in at most half of its characters
+func isSimilarWordPair(oldTokens, newTokens []string) bool {
+	if len(oldTokens) != 1 || len(newTokens) != 1 {
+		return false
+	}
+	oldRune, _ := utf8.DecodeRuneInString(oldTokens[0])
+	newRune, _ := utf8.DecodeRuneInString(newTokens[0])
+	if wordClass(oldRune) != 1 || wordClass(newRune) != 1 {
+		return false
+	}
+	oldLen, newLen := utf8.RuneCountInString(oldTokens[0]), utf8.RuneCountInString(newTokens[0])
+	return 2*intmath.Abs(oldLen-newLen) <= max(oldLen, newLen)
+}
+
+// sharesMostCharacters checks if the hunks of a character diff keep at least
+// half of the characters of the longer string
+func sharesMostCharacters(hunks []tokenHunk) bool {
+	kept, oldLen, newLen := 0, 0, 0
+	for _, hunk := range hunks {
+		if hunk.Kept {
+			kept += len(hunk.Old)
+		}
+		oldLen += len(hunk.Old)
+		newLen += len(hunk.New)
+	}
+	return 2*kept >= max(oldLen, newLen)
+}
+
+// characterHunks diffs two strings character by character
+func characterHunks(oldStr, newStr string) []tokenHunk {
+	return diffTokens(splitCharacters(oldStr), splitCharacters(newStr))
+}
+
+// splitCharacters splits a string into its characters
+func splitCharacters(s string) []string {
+	characters := make([]string, 0, len(s))
+	for _, r := range s {
+		characters = append(characters, string(r))
+	}
+	return characters
+}
+
+// tokenHunk is a run of tokens both strings keep, or the tokens removed from
+// the old string together with those the new one adds in their place
+type tokenHunk struct {
+	Kept     bool
+	Old, New []string
+}
+
+// diffTokens diffs two lists of tokens into alternating hunks of kept and
+// changed tokens
+func diffTokens(oldTokens, newTokens []string) []tokenHunk {
+	var hunks []tokenHunk
+	for _, edit := range diffLines(oldTokens, newTokens) {
+		kept := edit.Op != '-' && edit.Op != '+'
+		if len(hunks) == 0 || hunks[len(hunks)-1].Kept != kept {
+			hunks = append(hunks, tokenHunk{Kept: kept})
+		}
+		hunk := &hunks[len(hunks)-1]
+		if edit.Op != '+' {
+			hunk.Old = append(hunk.Old, edit.Line)
+		}
+		if edit.Op != '-' {
+			hunk.New = append(hunk.New, edit.Line)
+		}
+	}
+	return hunks
+}
+
+// colorHunks joins the hunks of a diff back into both strings, formatting kept
+// and changed runs of tokens with the given functions
+func colorHunks(hunks []tokenHunk, oldKept, oldChanged, newKept, newChanged func(...interface{}) string) (string, string) {
+	var oldResult, newResult strings.Builder
+	for _, hunk := range hunks {
+		oldText, newText := strings.Join(hunk.Old, ""), strings.Join(hunk.New, "")
+		if hunk.Kept {
+			oldResult.WriteString(oldKept(oldText))
+			newResult.WriteString(newKept(newText))
+			continue
+		}
+		oldResult.WriteString(sprintNonEmpty(oldChanged, oldText))
+		newResult.WriteString(sprintNonEmpty(newChanged, newText))
+	}
+	return oldResult.String(), newResult.String()
+}
+
+// sprintNonEmpty formats a text with sprint, leaving empty texts without the
+// escape codes of their color
+func sprintNonEmpty(sprint func(...interface{}) string, text string) string {
+	if text == "" {
+		return ""
+	}
+	return sprint(text)
+}